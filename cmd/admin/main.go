@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  issue   -name <runner> [-platforms linux-amd64,...] [-scopes report,...]
+          issue an API key and its signing secret, printed once
+  revoke  -id <key> | -prefix <key prefix> | -name <runner> -all
+          revoke one key, or with -all every active key of a runner;
+          to rotate a key issue a new one first, then revoke the old one
+  list    list all keys
+  verify  -id <check result>
+          verify a stored check result against the runner's signature
+`
+
+// admin manages the API keys runners use to write to the server. It connects
+// to the database configured for the server and needs its
+// SECRET_ENCRYPTION_KEY to issue keys and verify results.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "issue":
+		err = issue(ctx, args)
+	case "revoke":
+		err = revoke(ctx, args)
+	case "list":
+		err = list(ctx)
+	case "verify":
+		err = verify(ctx, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func openDatabase(ctx context.Context) (*database.DB, error) {
+	cfg := config.Load()
+	db, err := database.New(database.Config{
+		Host:      cfg.MySQL.Host,
+		Port:      cfg.MySQL.Port,
+		User:      cfg.MySQL.User,
+		Password:  cfg.MySQL.Password,
+		Database:  cfg.MySQL.Database,
+		SecretKey: cfg.Server.SecretKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.InitSchema(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func issue(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	name := fs.String("name", "", "runner the key is issued to")
+	platforms := fs.String("platforms", auth.AllPlatforms, "comma separated platforms the key may report, * for all")
+	scopes := fs.String("scopes", auth.ScopeReport, "comma separated scopes: "+strings.Join(auth.Scopes, ", "))
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	key := &auth.Key{
+		Name:      *name,
+		Platforms: auth.ParseList(*platforms),
+		Scopes:    auth.ParseList(*scopes),
+	}
+	if len(key.Platforms) == 0 {
+		return fmt.Errorf("-platforms must not be empty")
+	}
+	if len(key.Scopes) == 0 {
+		return fmt.Errorf("-scopes must not be empty")
+	}
+	for _, scope := range key.Scopes {
+		if !auth.ValidScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	token, hash, prefix, err := auth.Generate()
+	if err != nil {
+		return err
+	}
+	key.Hash = hash
+	key.Prefix = prefix
+	if key.Secret, err = auth.GenerateSecret(); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	id, err := db.CreateAPIKey(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Issued key %d for %s (platforms: %s, scopes: %s)\n",
+		id, key.Name, strings.Join(key.Platforms, ","), strings.Join(key.Scopes, ","))
+	fmt.Println("Set these on the runner, they are not shown again:")
+	fmt.Println("API_KEY=" + token)
+	fmt.Println("SIGNING_SECRET=" + key.Secret)
+	return nil
+}
+
+func revoke(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	id := fs.Int64("id", 0, "key to revoke")
+	prefix := fs.String("prefix", "", "prefix of the key to revoke, as shown by list")
+	name := fs.String("name", "", "runner whose keys are revoked, requires -all")
+	all := fs.Bool("all", false, "revoke every active key of the -name runner")
+	fs.Parse(args)
+
+	selectors := 0
+	for _, set := range []bool{*id != 0, *prefix != "", *name != ""} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("exactly one of -id, -prefix and -name is required")
+	}
+	if *name != "" && !*all {
+		return fmt.Errorf("-name revokes every active key of %s, confirm with -all", *name)
+	}
+
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *name == "" {
+		key, err := findKey(ctx, db, *id, *prefix)
+		if err != nil {
+			return err
+		}
+		if err := db.RevokeAPIKey(ctx, key.ID); errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("key %d of %s is already revoked", key.ID, key.Name)
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %d (%s) of %s\n", key.ID, key.Prefix, key.Name)
+		return nil
+	}
+
+	n, err := db.RevokeAPIKeys(ctx, *name)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no active keys for %s", *name)
+	}
+	fmt.Printf("Revoked %d keys of %s\n", n, *name)
+	return nil
+}
+
+// findKey returns the key with the id, or the one key with the prefix
+func findKey(ctx context.Context, db *database.DB, id int64, prefix string) (*auth.Key, error) {
+	if id != 0 {
+		key, err := db.GetAPIKey(ctx, id)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("no key %d", id)
+		}
+		return key, err
+	}
+
+	keys, err := db.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := keysWithPrefix(keys, prefix)
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no key with prefix %s", prefix)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("%d keys have prefix %s, use -id", len(found), prefix)
+	}
+}
+
+// keysWithPrefix returns the keys matching prefix, which may be shorter than
+// a key's prefix or a whole key
+func keysWithPrefix(keys []auth.Key, prefix string) []auth.Key {
+	var found []auth.Key
+	for _, k := range keys {
+		if strings.HasPrefix(k.Prefix, prefix) || strings.HasPrefix(prefix, k.Prefix) {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+func list(ctx context.Context) error {
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	keys, err := db.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tPLATFORMS\tSCOPES\tSIGNING\tCREATED\tLAST USED\tSTATUS")
+	for _, k := range keys {
+		lastUsed := "never"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format(time.DateTime)
+		}
+		// keys without a secret can't sign reports and need reissuing
+		signing := "yes"
+		if k.Secret == "" {
+			signing = "no"
+		}
+		status := "active"
+		if k.Revoked() {
+			status = "revoked " + k.RevokedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix,
+			strings.Join(k.Platforms, ","), strings.Join(k.Scopes, ","), signing,
+			k.CreatedAt.Format(time.DateTime), lastUsed, status)
+	}
+	return w.Flush()
+}
+
+func verify(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	id := fs.Int64("id", 0, "check result to verify")
+	fs.Parse(args)
+
+	if *id <= 0 {
+		return fmt.Errorf("-id is required")
+	}
+
+	db, err := openDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sig, err := db.GetReportSignature(ctx, *id)
+	if errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("check result %d was not signed", *id)
+	}
+	if err != nil {
+		return err
+	}
+	key, err := db.GetAPIKey(ctx, sig.KeyID)
+	if err != nil {
+		return fmt.Errorf("failed to get signing key %d: %w", sig.KeyID, err)
+	}
+	if err := auth.CheckSignature(key.Secret, sig.Timestamp, sig.Value, sig.Payload); err != nil {
+		return fmt.Errorf("stored report does not match the signature of %s: %w", key.Name, err)
+	}
+
+	// the payload is authentic, now check the stored result still says the same
+	var signed checker.CheckReport
+	if err := json.Unmarshal(sig.Payload, &signed); err != nil {
+		return fmt.Errorf("failed to decode signed report: %w", err)
+	}
+	stored, err := db.GetRun(ctx, signed.RunID)
+	if err != nil {
+		return fmt.Errorf("failed to get stored run %s: %w", signed.RunID, err)
+	}
+	if stored.ID != *id {
+		return fmt.Errorf("signed report belongs to run %s, stored as result %d", signed.RunID, stored.ID)
+	}
+	if diff := reportDiff(&signed, stored); len(diff) > 0 {
+		return fmt.Errorf("stored result %d differs from the signed report: %s", stored.ID, strings.Join(diff, ", "))
+	}
+
+	fmt.Printf("Check result %d is authentic, signed by %s (key %d) at %s\n",
+		*id, key.Name, key.ID, sig.Timestamp.Format(time.DateTime))
+	return nil
+}
+
+// reportDiff names the fields a release decision is based on that differ
+// between a signed report and its stored result
+func reportDiff(signed, stored *checker.CheckReport) []string {
+	var diff []string
+	check := func(field string, a, b interface{}) {
+		if a != b {
+			diff = append(diff, fmt.Sprintf("%s %v != %v", field, a, b))
+		}
+	}
+	check("status", signed.Status, stored.Status)
+	check("platform", signed.Platform, stored.Platform)
+	check("mirror", signed.Mirror, stored.Mirror)
+	check("channel", signed.Channel, stored.Channel)
+	check("tiup version", signed.Version.TiUP, stored.Version.TiUP)
+	check("errors", len(signed.Errors), len(stored.Errors))
+	// the database keeps whole seconds
+	if d := signed.Timestamp.Sub(stored.Timestamp); d <= -time.Second || d >= time.Second {
+		diff = append(diff, fmt.Sprintf("timestamp %s != %s", signed.Timestamp, stored.Timestamp))
+	}
+	for name, c := range signed.Version.Components {
+		check(name+" hash", c.GitHash, stored.Version.Components[name].GitHash)
+	}
+	return diff
+}
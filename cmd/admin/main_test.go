@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+func TestKeysWithPrefix(t *testing.T) {
+	keys := []auth.Key{
+		{ID: 1, Prefix: "tck_0a1b2c3d"},
+		{ID: 2, Prefix: "tck_0a1bffff"},
+		{ID: 3, Prefix: "tck_9999aaaa"},
+	}
+	tests := []struct {
+		prefix string
+		want   []int64
+	}{
+		{prefix: "tck_0a1b2c3d", want: []int64{1}},
+		{prefix: "tck_0a1b", want: []int64{1, 2}},
+		// a whole key as printed by issue
+		{prefix: "tck_9999aaaa0123456789", want: []int64{3}},
+		{prefix: "tck_7", want: nil},
+	}
+	for _, tt := range tests {
+		var got []int64
+		for _, k := range keysWithPrefix(keys, tt.prefix) {
+			got = append(got, k.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("keysWithPrefix(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+// the flag checks run before the database is opened
+func TestFlagValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, args []string) error
+		args []string
+		want string
+	}{
+		{name: "issue", run: issue, want: "-name is required"},
+		{name: "issue", run: issue, args: []string{"-name", "runner-1", "-platforms", " , "}, want: "-platforms must not be empty"},
+		{name: "issue", run: issue, args: []string{"-name", "runner-1", "-scopes", ""}, want: "-scopes must not be empty"},
+		{name: "issue", run: issue, args: []string{"-name", "runner-1", "-scopes", "report,admin"}, want: `unknown scope "admin"`},
+		{name: "revoke", run: revoke, want: "exactly one of -id, -prefix and -name is required"},
+		{name: "revoke", run: revoke, args: []string{"-id", "3", "-prefix", "tck_0a1b"}, want: "exactly one of"},
+		{name: "revoke", run: revoke, args: []string{"-name", "runner-1"}, want: "confirm with -all"},
+		{name: "verify", run: verify, args: []string{"-id", "0"}, want: "-id is required"},
+	}
+	for _, tt := range tests {
+		err := tt.run(context.Background(), tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v = %v, want %q", tt.name, tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestReportDiff(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 500000000, time.UTC)
+	report := func() *checker.CheckReport {
+		return &checker.CheckReport{
+			Timestamp: at,
+			Status:    checker.StatusSuccess,
+			Platform:  "linux-amd64",
+			Mirror:    "https://tiup.example.com",
+			Channel:   "nightly",
+			Version: checker.Versions{
+				TiUP:       "v1.16.0",
+				Components: map[string]checker.ComponentVersion{"tidb": {GitHash: "abc"}},
+			},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(stored *checker.CheckReport)
+		want   []string
+	}{
+		{name: "same", change: func(*checker.CheckReport) {}},
+		{name: "stored in whole seconds", change: func(r *checker.CheckReport) { r.Timestamp = at.Truncate(time.Second) }},
+		{name: "status", change: func(r *checker.CheckReport) { r.Status = checker.StatusFailed }, want: []string{"status success != failed"}},
+		{name: "platform", change: func(r *checker.CheckReport) { r.Platform = "darwin-arm64" }, want: []string{"platform linux-amd64 != darwin-arm64"}},
+		{name: "target", change: func(r *checker.CheckReport) { r.Mirror, r.Channel = "", "v8.5.0" },
+			want: []string{"mirror https://tiup.example.com != ", "channel nightly != v8.5.0"}},
+		{name: "errors dropped", change: func(r *checker.CheckReport) { r.Errors = []checker.Error{{Stage: "smoke"}} }, want: []string{"errors 0 != 1"}},
+		{name: "timestamp", change: func(r *checker.CheckReport) { r.Timestamp = at.Add(time.Hour) },
+			want: []string{"timestamp 2024-05-01 12:00:00.5 +0000 UTC != 2024-05-01 13:00:00.5 +0000 UTC"}},
+		{name: "component hash", change: func(r *checker.CheckReport) { r.Version.Components = nil }, want: []string{"tidb hash abc != "}},
+	}
+	for _, tt := range tests {
+		stored := report()
+		tt.change(stored)
+		if got := reportDiff(report(), stored); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: reportDiff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,29 +3,65 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
 	"github.com/purelind/check-tiup-nightly/internal/config"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 )
 
-func main() {
-	timeout := flag.Duration("timeout", 10*time.Minute, "checker timeout duration")
-	flag.Parse()
+// set through the Makefile LD_FLAGS
+var (
+	Version    = "dev"
+	CommitHash = "unknown"
+	BuildTime  = "unknown"
+)
 
+// usage: checker [flags] [flush-spool]
+//
+// flush-spool only resends the reports earlier runs could not deliver.
+func main() {
 	cfg := config.Load()
 
+	timeout := flag.Duration("timeout", cfg.TargetTimeout, "timeout of each target's check, TARGET_TIMEOUT by default")
+	flag.Parse()
+	cfg.TargetTimeout = *timeout
+
 	if err := logger.Init(cfg.LogPath); err != nil {
 		panic("failed to initialize logger: " + err.Error())
 	}
-	// create context with timeout control
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	components, err := component.Load(cfg.ComponentsConfig)
+	if err != nil {
+		logger.Error("Failed to load component registry:", err)
+		os.Exit(1)
+	}
+
+	// targets are bounded one by one, see Checker.Run
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// create and run checker
-	c := checker.NewChecker(cfg)
+	c := checker.NewChecker(cfg, components, checker.BuildInfo{
+		Version:   Version,
+		Commit:    CommitHash,
+		BuildTime: BuildTime,
+	})
+
+	if flag.Arg(0) == "flush-spool" {
+		flushCtx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		sent, remaining := c.FlushSpool(flushCtx)
+		logger.Info(fmt.Sprintf("Resent %d spooled reports, %d still spooled", sent, remaining))
+		if remaining > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	success := c.Run(ctx)
 
 	if !success {
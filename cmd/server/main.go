@@ -3,14 +3,17 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/purelind/check-tiup-nightly/internal/component"
 	"github.com/purelind/check-tiup-nightly/internal/config"
 	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/internal/github"
 	"github.com/purelind/check-tiup-nightly/internal/server"
 	"github.com/purelind/check-tiup-nightly/internal/updater"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
@@ -48,14 +51,21 @@ func initApp() (*App, error) {
 		return nil, err
 	}
 
+	components, err := component.Load(cfg.ComponentsConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := initDatabase(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	srv := server.New(db, cfg.Server.Port)
+	gh := github.FromConfig(cfg)
 
-	updater := service.NewUpdater(db)
+	srv := server.New(db, cfg, components, gh)
+
+	updater := service.NewUpdater(db, gh, components, cfg)
 
 	app := &App{
 		cfg:    cfg,
@@ -76,13 +86,19 @@ func initApp() (*App, error) {
 }
 
 func initDatabase(cfg *config.Config) (*database.DB, error) {
+	// signed reports can't be checked without the runners' secrets
+	if !cfg.Server.AuthDisabled && cfg.Server.SecretKey == "" {
+		return nil, fmt.Errorf("SECRET_ENCRYPTION_KEY is required unless AUTH_DISABLED is set")
+	}
+
 	db, err := database.New(database.Config{
 		Host: cfg.MySQL.Host,
 		Port: cfg.MySQL.Port,
 		User: cfg.MySQL.User,
 
-		Password: cfg.MySQL.Password,
-		Database: cfg.MySQL.Database,
+		Password:  cfg.MySQL.Password,
+		Database:  cfg.MySQL.Database,
+		SecretKey: cfg.Server.SecretKey,
 	})
 	if err != nil {
 		return nil, err
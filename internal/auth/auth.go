@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes grant access to the write endpoints.
+const (
+	// ScopeReport allows posting check reports and their artifacts
+	ScopeReport = "report"
+	// ScopeCommits allows updating branch heads
+	ScopeCommits = "commits"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopeReport, ScopeCommits}
+
+// AllPlatforms in a key's platforms allows reports of any platform.
+const AllPlatforms = "*"
+
+// keyPrefix marks a string as an API key of this service
+const keyPrefix = "tck_"
+
+// Key is an API key issued to a runner. Only the hash of the key is stored,
+// Prefix is kept so that admins can recognize it. Secret signs the runner's
+// reports, the server needs it to check signatures so it is stored encrypted
+// with a Cipher instead.
+type Key struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Secret     string     `json:"-"`
+	Platforms  []string   `json:"platforms"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Generate returns a new random API key together with its hash and prefix.
+func Generate() (key, hash, prefix string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	key = keyPrefix + hex.EncodeToString(b[:])
+	return key, Hash(key), key[:len(keyPrefix)+8], nil
+}
+
+// Hash returns the stored form of an API key. Keys are random, so a plain
+// SHA-256 is enough and allows looking them up by hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Revoked reports whether the key was revoked.
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Allows reports whether the key grants scope.
+func (k *Key) Allows(scope string) bool {
+	return contains(k.Scopes, scope)
+}
+
+// AllowsPlatform reports whether the key may report results of platform.
+func (k *Key) AllowsPlatform(platform string) bool {
+	return contains(k.Platforms, AllPlatforms) || contains(k.Platforms, platform)
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	return contains(Scopes, scope)
+}
+
+// ParseList splits a comma separated list, dropping empty items.
+func ParseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	key, hash, prefix, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.HasPrefix(key, keyPrefix) || len(key) != len(keyPrefix)+64 {
+		t.Errorf("key = %q, want %s and 64 hex digits", key, keyPrefix)
+	}
+	if hash != Hash(key) || len(hash) != 64 {
+		t.Errorf("hash = %q, want the SHA-256 of the key", hash)
+	}
+	if prefix != key[:12] {
+		t.Errorf("prefix = %q, want the first 12 characters of %q", prefix, key)
+	}
+
+	other, _, _, err := Generate()
+	if err != nil || other == key {
+		t.Errorf("second Generate = %q, %v, want a different key", other, err)
+	}
+}
+
+func TestKeyPermissions(t *testing.T) {
+	reporter := &Key{Platforms: []string{"linux-amd64", "linux-arm64"}, Scopes: []string{ScopeReport}}
+	updater := &Key{Platforms: []string{AllPlatforms}, Scopes: []string{ScopeCommits}}
+	tests := []struct {
+		name     string
+		key      *Key
+		scope    string
+		platform string
+		allows   bool
+		platOK   bool
+	}{
+		{name: "reporter", key: reporter, scope: ScopeReport, platform: "linux-arm64", allows: true, platOK: true},
+		{name: "reporter of another platform", key: reporter, scope: ScopeReport, platform: "darwin-arm64", allows: true},
+		{name: "reporter updating commits", key: reporter, scope: ScopeCommits, platform: "linux-amd64", platOK: true},
+		{name: "updater", key: updater, scope: ScopeCommits, platform: "darwin-amd64", allows: true, platOK: true},
+		{name: "updater reporting", key: updater, scope: ScopeReport, platform: "darwin-amd64", platOK: true},
+	}
+	for _, tt := range tests {
+		if got := tt.key.Allows(tt.scope); got != tt.allows {
+			t.Errorf("%s: Allows(%s) = %v, want %v", tt.name, tt.scope, got, tt.allows)
+		}
+		if got := tt.key.AllowsPlatform(tt.platform); got != tt.platOK {
+			t.Errorf("%s: AllowsPlatform(%s) = %v, want %v", tt.name, tt.platform, got, tt.platOK)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{ScopeReport, true},
+		{ScopeCommits, true},
+		{"admin", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidScope(tt.scope); got != tt.want {
+			t.Errorf("ValidScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"*", []string{"*"}},
+		{" linux-amd64, ,linux-arm64 ", []string{"linux-amd64", "linux-arm64"}},
+	}
+	for _, tt := range tests {
+		if got := ParseList(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseList(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
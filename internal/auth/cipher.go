@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a value encrypted by a Cipher
+const sealedPrefix = "v1:"
+
+// ErrNoCipher is a sealed value read without an encryption key configured
+var ErrNoCipher = errors.New("no secret encryption key configured")
+
+// Cipher encrypts signing secrets at rest. Unlike API keys they can't be
+// hashed, the server needs them to check signatures, so a database dump
+// alone must not be enough to forge reports.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher for a hex encoded 32 byte key, as printed by
+// `openssl rand -hex 32`.
+func NewCipher(key string) (*Cipher, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("secret encryption key must be 32 hex encoded bytes")
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts a secret.
+func (c *Cipher) Seal(secret string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed by Seal.
+func (c *Cipher) Open(sealed string) (string, error) {
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil || !IsSealed(sealed) || len(data) < c.aead.NonceSize() {
+		return "", fmt.Errorf("malformed sealed secret")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret, wrong encryption key?")
+	}
+	return string(secret), nil
+}
+
+// IsSealed reports whether a stored value was encrypted by a Cipher.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestNewCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "hex key", key: testEncryptionKey},
+		{name: "trailing newline", key: testEncryptionKey + "\n"},
+		{name: "empty", key: "", wantErr: true},
+		{name: "too short", key: testEncryptionKey[:32], wantErr: true},
+		{name: "not hex", key: strings.Repeat("zz", 32), wantErr: true},
+	}
+	for _, tt := range tests {
+		if _, err := NewCipher(tt.key); (err != nil) != tt.wantErr {
+			t.Errorf("NewCipher(%s) error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	c, err := NewCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCipher(strings.Repeat("ff", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := c.Seal("tcs_secret")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "tcs_secret") {
+		t.Errorf("Seal = %q, want an encrypted value", sealed)
+	}
+	again, _ := c.Seal("tcs_secret")
+	if again == sealed {
+		t.Errorf("sealing twice gave the same value, the nonce is not random")
+	}
+
+	// one character of the tag changed
+	tampered := []byte(sealed)
+	if i := len(tampered) - 5; tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		cipher  *Cipher
+		sealed  string
+		want    string
+		wantErr bool
+	}{
+		{name: "sealed", cipher: c, sealed: sealed, want: "tcs_secret"},
+		{name: "sealed again", cipher: c, sealed: again, want: "tcs_secret"},
+		{name: "wrong key", cipher: other, sealed: sealed, wantErr: true},
+		{name: "tampered", cipher: c, sealed: string(tampered), wantErr: true},
+		{name: "plaintext", cipher: c, sealed: "tcs_secret", wantErr: true},
+		{name: "not base64", cipher: c, sealed: sealedPrefix + "!!!", wantErr: true},
+		{name: "too short", cipher: c, sealed: sealedPrefix + "AAAA", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.cipher.Open(tt.sealed)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Open(%s) = %q, %v, want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsSealed(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"v1:AAAA", true},
+		{"tcs_secret", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsSealed(tt.value); got != tt.want {
+			t.Errorf("IsSealed(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
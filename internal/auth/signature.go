@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying the signature of a report.
+const (
+	// TimestampHeader holds the unix time the report was signed at
+	TimestampHeader = "X-Report-Timestamp"
+	// SignatureHeader holds "sha256=" and the hex HMAC of the report
+	SignatureHeader = "X-Report-Signature"
+)
+
+const (
+	secretPrefix    = "tcs_"
+	signaturePrefix = "sha256="
+)
+
+var (
+	// ErrMissingSignature is a request without signature headers
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrStaleSignature is a signature outside the accepted window, a replay
+	// or a runner with a skewed clock
+	ErrStaleSignature = errors.New("signature timestamp outside the accepted window")
+	// ErrBadSignature is a signature not matching the body
+	ErrBadSignature = errors.New("signature mismatch")
+	// ErrInvalidPayload is a body that is not a JSON document
+	ErrInvalidPayload = errors.New("invalid payload")
+)
+
+// GenerateSecret returns a new random signing secret for a runner.
+func GenerateSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %v", err)
+	}
+	return secretPrefix + hex.EncodeToString(b[:]), nil
+}
+
+// Canonicalize returns the canonical form of a JSON document: object keys
+// sorted, no insignificant whitespace, no HTML escaping and numbers kept as
+// written. Reports are signed in this form, so re-encoding them on the way,
+// or later from storage, does not break the signature.
+func Canonicalize(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid JSON: trailing data")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	// maps are encoded with sorted keys
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %v", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Sign returns the signature of the canonical form of a report sent at ts.
+func Sign(secret string, ts time.Time, body []byte) (string, error) {
+	canonical, err := Canonicalize(body)
+	if err != nil {
+		return "", err
+	}
+	return signaturePrefix + hex.EncodeToString(mac(secret, strconv.FormatInt(ts.Unix(), 10), canonical)), nil
+}
+
+// Verify checks the signature headers of a report body and returns the
+// canonical report that was signed. Timestamps further than window from now
+// are refused, so a captured request can't be replayed later.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time, window time.Duration) ([]byte, error) {
+	if timestamp == "" || signature == "" {
+		return nil, ErrMissingSignature
+	}
+
+	ts, err := ParseTimestamp(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if d := now.Sub(ts); d > window || d < -window {
+		return nil, ErrStaleSignature
+	}
+
+	canonical, err := Canonicalize(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if err := CheckSignature(secret, ts, signature, canonical); err != nil {
+		return nil, err
+	}
+	return canonical, nil
+}
+
+// CheckSignature checks the signature of a canonical report signed at ts,
+// whenever that was. Stored reports are verified again with it.
+func CheckSignature(secret string, ts time.Time, signature string, canonical []byte) error {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil || !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrBadSignature
+	}
+	if !hmac.Equal(got, mac(secret, strconv.FormatInt(ts.Unix(), 10), canonical)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// ParseTimestamp parses the value of TimestampHeader.
+func ParseTimestamp(timestamp string) (time.Time, error) {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// mac covers the timestamp too, so it can't be changed to pass the window
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
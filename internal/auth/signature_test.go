@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || len(secret) != len(secretPrefix)+64 {
+		t.Errorf("secret = %q, want %s and 64 hex digits", secret, secretPrefix)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Errorf("GenerateSecret returned %q twice", secret)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "compact", body: `{"a":1}`, want: `{"a":1}`},
+		{name: "keys sorted", body: `{"status": "success", "platform": "linux-amd64", "run": {"trigger": "ci", "hostname": "h"}}`,
+			want: `{"platform":"linux-amd64","run":{"hostname":"h","trigger":"ci"},"status":"success"}`},
+		{name: "numbers as written", body: `{"id": 12345678901234567890, "ratio": 1.50, "n": 1e3}`, want: `{"id":12345678901234567890,"n":1e3,"ratio":1.50}`},
+		{name: "no html escaping", body: `{"error": "a < b && c > d"}`, want: `{"error":"a < b && c > d"}`},
+		{name: "arrays keep order", body: "[3, 1,\n 2]", want: `[3,1,2]`},
+		{name: "trailing data", body: `{"a":1} {"b":2}`, wantErr: true},
+		{name: "not json", body: `status=success`, wantErr: true},
+		{name: "empty", body: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Canonicalize([]byte(tt.body))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Canonicalize(%s) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Canonicalize(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "tcs_secret"
+	body := []byte(`{"status": "success", "platform": "linux-amd64"}`)
+	// the same report after a re-encoding on the way
+	reencoded := []byte(`{"platform":"linux-amd64","status":"success"}`)
+	signedAt := time.Unix(1714521600, 0)
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	signature, err := Sign(secret, signedAt, body)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	window := 5 * time.Minute
+
+	// request is what the server gets, changed by every case
+	type request struct {
+		secret, timestamp, signature string
+		body                         []byte
+	}
+	tests := []struct {
+		name    string
+		change  func(r *request)
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", now: signedAt},
+		{name: "re-encoded body", change: func(r *request) { r.body = reencoded }, now: signedAt},
+		{name: "late inside the window", now: signedAt.Add(window)},
+		{name: "runner clock ahead", now: signedAt.Add(-window)},
+		{name: "replayed after the window", now: signedAt.Add(window + time.Second), wantErr: ErrStaleSignature},
+		{name: "runner clock too far ahead", now: signedAt.Add(-window - time.Second), wantErr: ErrStaleSignature},
+		// moving the timestamp into the window breaks the signature
+		{
+			name:    "timestamp changed",
+			change:  func(r *request) { r.timestamp = strconv.FormatInt(signedAt.Unix()+60, 10) },
+			now:     signedAt,
+			wantErr: ErrBadSignature,
+		},
+		{
+			name:    "body changed",
+			change:  func(r *request) { r.body = []byte(`{"status": "failed", "platform": "linux-amd64"}`) },
+			now:     signedAt,
+			wantErr: ErrBadSignature,
+		},
+		{name: "other secret", change: func(r *request) { r.secret = "tcs_other" }, now: signedAt, wantErr: ErrBadSignature},
+		{
+			name:    "no prefix",
+			change:  func(r *request) { r.signature = strings.TrimPrefix(r.signature, signaturePrefix) },
+			now:     signedAt,
+			wantErr: ErrBadSignature,
+		},
+		{name: "not hex", change: func(r *request) { r.signature = signaturePrefix + "zz" }, now: signedAt, wantErr: ErrBadSignature},
+		{name: "missing timestamp", change: func(r *request) { r.timestamp = "" }, now: signedAt, wantErr: ErrMissingSignature},
+		{name: "missing signature", change: func(r *request) { r.signature = "" }, now: signedAt, wantErr: ErrMissingSignature},
+		{name: "invalid body", change: func(r *request) { r.body = []byte(`{"status":`) }, now: signedAt, wantErr: ErrInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := request{secret: secret, timestamp: ts, signature: signature, body: body}
+			if tt.change != nil {
+				tt.change(&r)
+			}
+
+			canonical, err := Verify(r.secret, r.timestamp, r.signature, r.body, tt.now, window)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if string(canonical) != string(reencoded) {
+				t.Errorf("Verify returned %s, want the canonical report %s", canonical, reencoded)
+			}
+			// the stored canonical report can be checked again later
+			if err := CheckSignature(r.secret, signedAt, r.signature, canonical); err != nil {
+				t.Errorf("CheckSignature of the stored report: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1714521600", want: 1714521600},
+		{in: "2024-05-01T00:00:00Z", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr || !tt.wantErr && got.Unix() != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
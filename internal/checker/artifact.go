@@ -0,0 +1,181 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	playgroundLogName = "playground.log"
+
+	// errorTailSize is how much playground output is attached to an error
+	errorTailSize = 4 << 10
+	// maxArtifactSize matches the limit enforced by the server
+	maxArtifactSize = 5 << 20
+)
+
+// artifact is a file produced during the run that is uploaded with the report.
+type artifact struct {
+	name        string
+	path        string
+	contentType string
+}
+
+// createPlaygroundLog creates the log of a playground attempt. The log of an
+// earlier, retried attempt is kept as playground.attempt-N.log.
+func (c *Checker) createPlaygroundLog() (*os.File, error) {
+	if err := os.MkdirAll(c.runDir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(c.runDir, playgroundLogName)
+	if err := c.keepAttemptLog(path); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("Playground output is written to %s", path))
+	c.artifacts = append(c.artifacts, artifact{
+		name:        playgroundLogName,
+		path:        path,
+		contentType: "text/plain; charset=utf-8",
+	})
+	return f, nil
+}
+
+// keepAttemptLog renames the playground log of the previous attempt, if any,
+// and its artifact with it.
+func (c *Checker) keepAttemptLog(path string) error {
+	current := -1
+	attempt := 1
+	for i, a := range c.artifacts {
+		switch {
+		case a.name == playgroundLogName:
+			current = i
+		case strings.HasPrefix(a.name, "playground.attempt-"):
+			attempt++
+		}
+	}
+	if current < 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf("playground.attempt-%d.log", attempt)
+	kept := filepath.Join(c.runDir, name)
+	if err := os.Rename(path, kept); err != nil {
+		return fmt.Errorf("failed to keep log of playground attempt %d: %v", attempt, err)
+	}
+	c.artifacts[current].name = name
+	c.artifacts[current].path = kept
+	return nil
+}
+
+// playgroundError records a playground failure together with the tail of
+// the playground output and returns it as an error for the stage result.
+func (c *Checker) playgroundError(msg string) error {
+	tail, err := readTail(filepath.Join(c.runDir, playgroundLogName), errorTailSize)
+	if err == nil && len(tail) > 0 {
+		msg = fmt.Sprintf("%s\n--- playground output (last %d bytes) ---\n%s", msg, len(tail), tail)
+	}
+	c.recordError("playground", msg)
+	return fmt.Errorf("%s", msg)
+}
+
+// readArtifact returns the content to upload. Oversized text files keep
+// their end, that is where failures show up; anything else would be corrupt
+// when cut, so it must fit.
+func readArtifact(a artifact) ([]byte, error) {
+	if strings.HasPrefix(a.contentType, "text/") {
+		return readTail(a.path, maxArtifactSize)
+	}
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxArtifactSize {
+		return nil, fmt.Errorf("%s is %d bytes, over the %d byte limit", a.name, info.Size(), maxArtifactSize)
+	}
+	return os.ReadFile(a.path)
+}
+
+// readTail returns at most n bytes from the end of the file.
+func readTail(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > n {
+		if _, err := f.Seek(-n, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
+
+// apiURL builds a server URL from the configured report endpoint,
+// which points at .../api/v1/status.
+func (c *Checker) apiURL(path string) string {
+	return strings.TrimSuffix(c.apiEndpoint, "/status") + path
+}
+
+// authorize adds the configured API key to a request to the server.
+func (c *Checker) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// uploadArtifacts sends artifacts to the server, attached to the stored
+// report. Failures are logged only, the report itself is already saved.
+func (c *Checker) uploadArtifacts(ctx context.Context, reportID int64, artifacts []artifact) {
+	for _, a := range artifacts {
+		if err := c.uploadArtifact(ctx, reportID, a); err != nil {
+			logger.Error(fmt.Sprintf("Failed to upload %s: %v", a.name, err))
+			continue
+		}
+		logger.Info(fmt.Sprintf("Uploaded %s", a.name))
+	}
+}
+
+func (c *Checker) uploadArtifact(ctx context.Context, reportID int64, a artifact) error {
+	data, err := readArtifact(a)
+	if err != nil {
+		return err
+	}
+
+	url := c.apiURL(fmt.Sprintf("/results/%d/artifacts/%s", reportID, a.name))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", a.contentType)
+	c.authorize(req)
+
+	client := &http.Client{Timeout: reportTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
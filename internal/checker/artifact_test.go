@@ -0,0 +1,219 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	writeFile(t, path, []byte("0123456789"))
+
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{n: 4, want: "6789"},
+		{n: 10, want: "0123456789"},
+		{n: 100, want: "0123456789"},
+	}
+	for _, tt := range tests {
+		got, err := readTail(path, tt.n)
+		if err != nil {
+			t.Fatalf("readTail(%d): %v", tt.n, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("readTail(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+
+	if _, err := readTail(filepath.Join(t.TempDir(), "missing"), 4); err == nil {
+		t.Errorf("readTail of a missing file succeeded")
+	}
+}
+
+func TestReadArtifact(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small")
+	writeFile(t, small, []byte("small"))
+	big := filepath.Join(dir, "big")
+	content := bytes.Repeat([]byte("x"), maxArtifactSize)
+	writeFile(t, big, append([]byte("head"), content...))
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		wantSize    int
+		wantErr     bool
+	}{
+		{name: "small text", path: small, contentType: "text/plain; charset=utf-8", wantSize: 5},
+		{name: "small archive", path: small, contentType: "application/gzip", wantSize: 5},
+		{name: "big text keeps the tail", path: big, contentType: "text/plain", wantSize: maxArtifactSize},
+		{name: "big archive", path: big, contentType: "application/gzip", wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing"), contentType: "application/gzip", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readArtifact(artifact{name: tt.name, path: tt.path, contentType: tt.contentType})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readArtifact succeeded with %d bytes, want error", len(data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readArtifact: %v", err)
+			}
+			if len(data) != tt.wantSize {
+				t.Errorf("readArtifact returned %d bytes, want %d", len(data), tt.wantSize)
+			}
+			if bytes.HasPrefix(data, []byte("head")) {
+				t.Errorf("readArtifact kept the head of an oversized file")
+			}
+		})
+	}
+}
+
+// upload is an artifact received by artifactServer
+type upload struct {
+	path, contentType, auth, body string
+}
+
+// artifactServer records artifact uploads, names in fail are refused
+func artifactServer(t *testing.T, fail ...string) (*httptest.Server, func() []upload) {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		uploads []upload
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range fail {
+			if strings.HasSuffix(r.URL.Path, "/"+name) {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads = append(uploads, upload{r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization"), string(body)})
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []upload {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]upload(nil), uploads...)
+	}
+}
+
+func TestUploadArtifacts(t *testing.T) {
+	srv, uploads := artifactServer(t, "broken.log")
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "playground.log"), []byte("started"))
+	writeFile(t, filepath.Join(dir, "broken.log"), []byte("broken"))
+
+	c := &Checker{apiEndpoint: srv.URL + "/api/v1/status", apiKey: "tck_test"}
+	c.uploadArtifacts(context.Background(), 42, []artifact{
+		{name: "playground.log", path: filepath.Join(dir, "playground.log"), contentType: "text/plain; charset=utf-8"},
+		{name: "missing.log", path: filepath.Join(dir, "missing.log"), contentType: "text/plain"},
+		{name: "broken.log", path: filepath.Join(dir, "broken.log"), contentType: "text/plain"},
+	})
+
+	// a missing or refused artifact does not stop the others
+	want := []upload{{
+		path:        "/api/v1/results/42/artifacts/playground.log",
+		contentType: "text/plain; charset=utf-8",
+		auth:        "Bearer tck_test",
+		body:        "started",
+	}}
+	if got := uploads(); len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("uploads = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreatePlaygroundLog(t *testing.T) {
+	c := &Checker{runDir: filepath.Join(t.TempDir(), "run")}
+	f, err := c.createPlaygroundLog()
+	if err != nil {
+		t.Fatalf("createPlaygroundLog: %v", err)
+	}
+	f.WriteString(strings.Repeat("x", errorTailSize) + "panic: boom\n")
+	f.Close()
+
+	if len(c.artifacts) != 1 || c.artifacts[0].name != playgroundLogName || c.artifacts[0].path != filepath.Join(c.runDir, playgroundLogName) {
+		t.Fatalf("artifacts = %+v, want the playground log", c.artifacts)
+	}
+
+	err = c.playgroundError("playground exited")
+	if err == nil || !strings.HasPrefix(err.Error(), "playground exited\n") || !strings.HasSuffix(err.Error(), "panic: boom\n") {
+		t.Errorf("playgroundError = %v, want the message and the end of the log", err)
+	}
+	if len(err.Error()) > errorTailSize+200 {
+		t.Errorf("playgroundError is %d bytes, want at most the %d byte tail", len(err.Error()), errorTailSize)
+	}
+	if len(c.errors) != 1 || c.errors[0].Error != err.Error() {
+		t.Errorf("recorded errors = %+v", c.errors)
+	}
+}
+
+func TestCreatePlaygroundLogPerAttempt(t *testing.T) {
+	c := &Checker{runDir: filepath.Join(t.TempDir(), "run")}
+	c.artifacts = []artifact{{name: "components.tar.gz", path: "/elsewhere"}}
+	for attempt := 1; attempt <= 3; attempt++ {
+		f, err := c.createPlaygroundLog()
+		if err != nil {
+			t.Fatalf("createPlaygroundLog of attempt %d: %v", attempt, err)
+		}
+		fmt.Fprintf(f, "attempt %d\n", attempt)
+		f.Close()
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "components.tar.gz"},
+		{name: "playground.attempt-1.log", data: "attempt 1\n"},
+		{name: "playground.attempt-2.log", data: "attempt 2\n"},
+		{name: playgroundLogName, data: "attempt 3\n"},
+	}
+	if len(c.artifacts) != len(tests) {
+		t.Fatalf("artifacts = %+v, want %d", c.artifacts, len(tests))
+	}
+	for i, tt := range tests {
+		a := c.artifacts[i]
+		if a.name != tt.name {
+			t.Errorf("artifact %d = %s, want %s", i, a.name, tt.name)
+			continue
+		}
+		if tt.data == "" {
+			continue
+		}
+		if a.path != filepath.Join(c.runDir, tt.name) {
+			t.Errorf("%s is at %s", a.name, a.path)
+		}
+		if data, err := os.ReadFile(a.path); err != nil || string(data) != tt.data {
+			t.Errorf("%s = %q, %v, want %q", a.name, data, err, tt.data)
+		}
+	}
+}
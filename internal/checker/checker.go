@@ -1,24 +1,19 @@
 package checker
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net"
-	"net/http"
-	"os"
-	"os/exec"
 	"runtime"
 	"strings"
-	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
+	"github.com/purelind/check-tiup-nightly/internal/component"
 	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/internal/github"
 	"github.com/purelind/check-tiup-nightly/internal/notify"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 )
@@ -28,21 +23,139 @@ type Checker struct {
 	errors       []Error
 	versions     Versions
 	apiEndpoint  string
-	githubToken  string
+	apiKey       string // sent as a bearer token with writes to the server
+	github       *github.Client
+	registry     *component.Registry
 	notifier     *notify.Notifier
+	pipeline     *Pipeline
+	stages       []StageResult
+	// attemptStart is the index of the first error of the running stage attempt
+	attemptStart int
+	channel      string
+	targets      []Target
+	target       Target
+	// targetTimeout bounds the check of each target
+	targetTimeout time.Duration
+	// home is the target's isolated TIUP_HOME, empty for the operator's own
+	home        string
+	targetsHome string
+	// isolate gives every run a temporary TIUP_HOME, see prepareHome
+	isolate           bool
+	warmCacheDir      string
+	keepHomeOnFailure bool
+	homeUsage         HomeUsage
+
+	components []string
+	installs   []ComponentInstall
+	topology   topology
+	runName    string
+	runsDir    string
+	runDir     string
+	artifacts  []artifact
+	// playgroundTag names the playground data directory under $TIUP_HOME/data
+	playgroundTag    string
+	readinessTimeout time.Duration
+	smokeTestDir     string
+	smokeTests       []SmokeTestResult
+	consistency      []InstanceVersion
+
+	tiflashReplicaTimeout time.Duration
+	preflight             config.PreflightConfig
+
+	// runID identifies the report of the current target run
+	runID   string
+	runInfo RunInfo
+	// signingSecret signs reports, see auth.Sign
+	signingSecret string
+	// spoolDir keeps reports until the server stored them
+	spoolDir       string
+	reportAttempts int
+	reportBackoff  time.Duration
 }
 
-func NewChecker(cfg *config.Config) *Checker {
-	return &Checker{
+// Report statuses. A run failing only for environment reasons is not a
+// regression of the nightly build.
+const (
+	StatusSuccess          = "success"
+	StatusFailed           = "failed"
+	StatusEnvironmentError = "environment_error"
+	// StatusFlaky is a run that passed only after retrying a stage.
+	StatusFlaky = "flaky"
+)
+
+func NewChecker(cfg *config.Config, registry *component.Registry, build BuildInfo) *Checker {
+	c := &Checker{
 		platformInfo: getPlatformInfo(),
 		errors:       make([]Error, 0),
 		versions: Versions{
 			Components: make(map[string]ComponentVersion),
 		},
 		apiEndpoint: cfg.APIEndpoint,
-		githubToken: cfg.GitHubToken,
+		apiKey:      cfg.APIKey,
+		github:      github.FromConfig(cfg),
+		registry:    registry,
 		notifier:    notify.NewNotifier(),
+		targetsHome: cfg.TargetsHome,
+		components:  cfg.TiUPComponents,
+		topology:    topology{cfg.Playground},
+
+		targetTimeout: cfg.TargetTimeout,
+
+		isolate:           cfg.Isolate,
+		warmCacheDir:      cfg.WarmCacheDir,
+		keepHomeOnFailure: cfg.KeepHomeOnFailure,
+
+		readinessTimeout: cfg.ReadinessTimeout,
+		smokeTestDir:     cfg.SmokeTestDir,
+
+		tiflashReplicaTimeout: cfg.TiFlashReplicaTimeout,
+		preflight:             cfg.Preflight,
+
+		signingSecret:  cfg.SigningSecret,
+		spoolDir:       cfg.SpoolDir,
+		reportAttempts: cfg.ReportAttempts,
+		reportBackoff:  cfg.ReportBackoff,
+	}
+	c.runInfo = newRunInfo(cfg, build)
+	c.runName = fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102-150405"), c.platformInfo.Platform)
+	c.runsDir = cfg.RunDir
+
+	for _, s := range cfg.TiUPTargets {
+		target, err := ParseTarget(s)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Ignoring target: %v", err))
+			continue
+		}
+		c.targets = append(c.targets, target)
 	}
+	if len(c.targets) == 0 {
+		c.targets = []Target{{Channel: cfg.TiUPChannel}}
+	}
+
+	c.pipeline = NewPipeline(
+		&preflightStage{c: c},
+		&downloadStage{c: c},
+		&playgroundStage{c: c},
+		&smokeTestStage{c: c},
+		&tiflashStage{c: c},
+	)
+	c.pipeline.Skip(cfg.SkipStages...)
+	for stage, rc := range cfg.Retries {
+		c.pipeline.Retry(stage, RetryPolicy{
+			MaxAttempts: rc.MaxAttempts,
+			Backoff:     rc.Backoff,
+			Categories:  rc.Categories,
+		})
+	}
+	c.pipeline.attempts = c
+
+	return c
+}
+
+// Pipeline returns the stage pipeline so callers can register extra stages
+// or skip default ones before calling Run.
+func (c *Checker) Pipeline() *Pipeline {
+	return c.pipeline
 }
 
 func getPlatformInfo() PlatformInfo {
@@ -60,358 +173,253 @@ func getPlatformInfo() PlatformInfo {
 	}
 }
 
+// recordError records an error, categorized by Classify.
 func (c *Checker) recordError(stage, errMsg string) {
+	c.recordCategorizedError(stage, Classify(stage, errMsg), errMsg)
+}
+
+func (c *Checker) recordCategorizedError(stage, category, errMsg string) {
 	err := Error{
 		Stage:     stage,
 		Error:     errMsg,
+		Category:  category,
 		Timestamp: time.Now(),
 	}
 	c.errors = append(c.errors, err)
-	logger.Error(fmt.Sprintf("[%s] %s", stage, errMsg))
+	logger.Error(fmt.Sprintf("[%s] (%s) %s", stage, category, errMsg))
 }
 
 func (c *Checker) checkTiUPDownload(ctx context.Context) error {
 	logger.Info("Starting TiUP download check")
 
-	if err := c.runCommand(ctx, "tiup", "update", "--self"); err != nil {
-		c.recordError("download", fmt.Sprintf("Failed to update TiUP: %v", err))
-		return err
-	}
-
-	// check nightly components
-	components := []string{
-		"tidb:nightly",
-		"tikv:nightly",
-		"pd:nightly",
-		"tiflash:nightly",
-		"prometheus:nightly",
-		"grafana:nightly",
-	}
-
-	for _, comp := range components {
-		if err := c.runCommand(ctx, "tiup", "install", comp); err != nil {
-			c.recordError("download", fmt.Sprintf("Failed to install %s: %v", comp, err))
-			return err
+	// installs of an earlier attempt are superseded by this one
+	c.installs = nil
+	before := c.cacheSize()
+	defer func() {
+		if delta := c.cacheSize() - before; delta > 0 {
+			c.homeUsage.DownloadedBytes += delta
 		}
-		logger.Info(fmt.Sprintf("Successfully installed %s", comp))
-	}
-
-	return nil
-}
-
-func (c *Checker) startPlayground(ctx context.Context) (*exec.Cmd, error) {
-	logger.Info("Starting TiUP playground")
-
-	cmd := exec.CommandContext(ctx, "tiup", "playground", "nightly",
-		"--db", "1",
-		"--kv", "1",
-		"--pd", "1",
-		"--tiflash", "1",
-		"--tiflash.timeout", "240",
-	)
-
-	_, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-
-	_, err = cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stderr pipe: %v", err)
-	}
+	}()
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start playground: %v", err)
+	if err := c.setupMirror(ctx); err != nil {
+		c.recordError("download", fmt.Sprintf("Failed to set mirror: %v", err))
+		return err
 	}
 
-	// wait for initialization
-	time.Sleep(10 * time.Second)
-
-	// check database connection
-	db, err := sql.Open("mysql", fmt.Sprintf("root@tcp(127.0.0.1:4000)/"))
-	if err != nil {
-		c.recordError("playground", "Failed to connect to TiDB")
-		return nil, err
+	if err := c.runCommand(ctx, "tiup", "update", "--self"); err != nil {
+		c.recordError("download", fmt.Sprintf("Failed to update TiUP: %v", err))
+		return err
 	}
-	defer db.Close()
 
-	// try to connect to TiDB 12 times, with 10 seconds interval
-	for i := 0; i < 12; i++ {
-		if err := db.Ping(); err == nil {
-			logger.Info("Successfully connected to TiDB")
-			break
-		}
-
-		// check if the process has exited
-		if cmd.ProcessState != nil {
-			c.recordError("playground", "Playground process exited unexpectedly")
-			return nil, fmt.Errorf("playground process exited")
+	// install every component even if some fail, so one broken package
+	// does not hide the state of the others
+	var failed []string
+	for _, comp := range c.components {
+		install := c.installComponent(ctx, comp)
+		c.installs = append(c.installs, install)
+		if !install.Success {
+			c.recordError("download", fmt.Sprintf("Failed to install %s: %s", install.Package, install.Error))
+			failed = append(failed, install.Package)
+			continue
 		}
-
-		time.Sleep(10 * time.Second)
+		logger.Info(fmt.Sprintf("Successfully installed %s in %s (%d bytes)",
+			install.Package, install.Duration, install.Bytes))
 	}
 
-	// Add TiFlash readiness check
-	logger.Info("Waiting for TiFlash to be ready...")
-	for i := 0; i < 12; i++ {
-		conn, err := net.DialTimeout("tcp", "127.0.0.1:3930", 5*time.Second)
-		if err == nil {
-			conn.Close()
-			logger.Info("Successfully connected to TiFlash")
-			return cmd, nil
-		}
-
-		// check if the process has exited
-		if cmd.ProcessState != nil {
-			c.recordError("playground", "Playground process exited unexpectedly while waiting for TiFlash")
-			return nil, fmt.Errorf("playground process exited")
-		}
-
-		logger.Info("TiFlash not ready yet, waiting...")
-		time.Sleep(5 * time.Second)
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %s", strings.Join(failed, ", "))
 	}
-
-	c.recordError("playground", "Timeout waiting for TiFlash to be ready")
-	return nil, fmt.Errorf("timeout waiting for TiFlash to be ready")
+	return nil
 }
 
 func (c *Checker) runSmokeTest(ctx context.Context) error {
 	logger.Info("==================== Starting smoke tests ====================")
 
-	db, err := sql.Open("mysql", "root@tcp(127.0.0.1:4000)/")
+	// results of an earlier attempt are superseded by this one
+	c.smokeTests = nil
+	c.consistency = nil
+
+	db, err := sql.Open("mysql", c.tidbDSN())
 	if err != nil {
 		c.recordError("smoke_test", fmt.Sprintf("Failed to connect: %v", err))
 		return err
 	}
 	defer db.Close()
 
-	// basic SQL tests
-	tests := []struct {
-		name string
-		sql  string
-	}{
-		{"Create database", "CREATE DATABASE IF NOT EXISTS test"},
-		{"Select database", "USE test"},
-		{"Create table", "CREATE TABLE IF NOT EXISTS smoke_test (id INT PRIMARY KEY, value VARCHAR(255))"},
-		{"Insert data", "INSERT INTO smoke_test VALUES (1, 'test')"},
-		{"Query data", "SELECT * FROM smoke_test"},
-	}
-
-	for _, test := range tests {
-		logger.Info(fmt.Sprintf("Running test: %s", test.name))
-		if _, err := db.ExecContext(ctx, test.sql); err != nil {
-			c.recordError("smoke_test", fmt.Sprintf("%s failed: %v", test.name, err))
-			return err
-		}
-		logger.Info(fmt.Sprintf("✓ Passed: %s", test.name))
+	cases, err := loadSmokeCases(c.smokeTestDir)
+	if err != nil {
+		c.recordError("smoke_test", fmt.Sprintf("Failed to load smoke tests: %v", err))
+		return err
 	}
 
+	failed := c.runSmokeCases(ctx, db, cases)
+
 	logger.Info("Running version consistency check...")
 	if err := c.checkVersionConsistency(ctx, db); err != nil {
 		logger.Error(fmt.Sprintf("Version consistency check failed: %v", err))
 		return err
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d smoke tests failed", failed, len(cases))
+	}
+
 	logger.Info("==================== Smoke tests completed successfully ====================")
 	return nil
 }
 
-func (c *Checker) checkVersionConsistency(ctx context.Context, db *sql.DB) error {
-	logger.Info("Checking version consistency...")
-	logger.Info("Querying information_schema.cluster_info...")
-	rows, err := db.QueryContext(ctx, "SELECT * FROM information_schema.cluster_info")
-	if err != nil {
-		c.recordError("version_check", fmt.Sprintf("Failed to query cluster_info: %v", err))
-		return err
+// Run checks every target in turn and reports whether all of them passed.
+// Each target gets its own timeout, ctx only cancels the whole run.
+func (c *Checker) Run(ctx context.Context) bool {
+	if sent, remaining := c.FlushSpool(ctx); sent > 0 || remaining > 0 {
+		logger.Info(fmt.Sprintf("Resent %d spooled reports, %d still spooled", sent, remaining))
 	}
-	defer rows.Close()
-
-	var referenceVersion string
-	var componentCount int
-	logger.Info("Scanning component versions...")
-
-	for rows.Next() {
-		componentCount++
-		var (
-			componentType, instance, statusAddr string
-			version, gitHash, startTime, uptime string
-			serverId                            int
-		)
-
-		if err := rows.Scan(&componentType, &instance, &statusAddr, &version, &gitHash,
-			&startTime, &uptime, &serverId); err != nil {
-			logger.Error(fmt.Sprintf("Failed to scan row: %v", err))
-			continue
-		}
-
-		logger.Info(fmt.Sprintf("Found component in cluster_info - Type: %s, Instance: %s, Status: %s",
-			componentType, instance, statusAddr))
-
-		if !isValidComponent(componentType) {
-			logger.Info(fmt.Sprintf("Skipping invalid component: %s (not in allowed list: tidb, pd, tikv, tiflash)", componentType))
-			continue
-		}
-
-		commitTime, err := c.getGitHubCommitTime(ctx, componentType, gitHash)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Failed to get commit time for %s: %v", componentType, err))
-			commitTime = time.Time{} // if failed, use zero value
-		}
-
-		logger.Info(fmt.Sprintf("Component: %s, Version: %s, GitHash: %s, CommitTime: %s",
-			componentType, version, gitHash, commitTime))
 
-		// validate git hash
-		if len(gitHash) != 40 {
-			c.recordError("version_check", fmt.Sprintf("Invalid git hash for %s: %s", componentType, gitHash))
-			return fmt.Errorf("invalid git hash")
-		}
-
-		// extract base version
-		baseVersion := extractBaseVersion(version)
-
-		c.versions.Components[componentType] = ComponentVersion{
-			FullVersion: version,
-			BaseVersion: baseVersion,
-			GitHash:     gitHash,
-			CommitTime:  commitTime,
-		}
-
-		if referenceVersion == "" {
-			referenceVersion = baseVersion
-		} else if baseVersion != referenceVersion {
-			c.recordError("version_check", fmt.Sprintf("Version mismatch: %s has version %s, expected %s",
-				componentType, baseVersion, referenceVersion))
-			return fmt.Errorf("version mismatch")
+	success := true
+	for _, target := range c.targets {
+		c.startTarget(target)
+		if !c.runTarget(ctx) {
+			success = false
 		}
 	}
-
-	logger.Info(fmt.Sprintf("Version consistency check completed. Total components found: %d", componentCount))
-	logger.Info(fmt.Sprintf("Components registered: %v", getMapKeys(c.versions.Components)))
-	return nil
+	return success
 }
 
-func (c *Checker) Run(ctx context.Context) bool {
+func (c *Checker) runTarget(ctx context.Context) bool {
+	if c.targetTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.targetTimeout)
+		defer cancel()
+	}
+
 	logger.Info("==================== Starting TiUP checker ====================")
-	logger.Info(fmt.Sprintf("Platform: %s, OS: %s, Arch: %s",
-		c.platformInfo.Platform, c.platformInfo.OS, c.platformInfo.Arch))
+	logger.Info(fmt.Sprintf("Platform: %s, OS: %s, Arch: %s, Target: %s",
+		c.platformInfo.Platform, c.platformInfo.OS, c.platformInfo.Arch, c.target))
+	logger.Info(fmt.Sprintf("Run ID: %s, Trigger: %s, Checker: %s (%s)",
+		c.runID, c.runInfo.Trigger, c.runInfo.Version, c.runInfo.Commit))
 
-	status := "success"
-	var playground *exec.Cmd
+	status := StatusSuccess
 
-	// clean up tiup playground process
-	defer func() {
-		if playground != nil && playground.Process != nil {
-			logger.Info("Cleaning up: Gracefully stopping playground process")
-			// first send SIGTERM
-			if err := playground.Process.Signal(syscall.SIGTERM); err != nil {
-				logger.Error(fmt.Sprintf("Failed to send SIGTERM: %v", err))
-			}
-
-			// give the process up to 10 seconds to clean up
-			done := make(chan error, 1)
-			go func() {
-				done <- playground.Wait()
-			}()
-
-			select {
-			case <-time.After(10 * time.Second):
-				logger.Info("Process didn't exit in time, forcing kill")
-				if err := playground.Process.Kill(); err != nil {
-					logger.Error(fmt.Sprintf("Failed to kill playground: %v", err))
-				}
-			case err := <-done:
-				if err != nil {
-					logger.Error(fmt.Sprintf("Process exited with error: %v", err))
-				} else {
-					logger.Info("Process exited gracefully")
-				}
-			}
-		}
-	}()
-
-	// Run all checks in sequence, stop if any fails
-	if success := c.runChecks(ctx, &playground); !success {
-		status = "failed"
+	if err := c.prepareHome(); err != nil {
+		c.recordCategorizedError("setup", CategoryEnvironment, err.Error())
+		c.releaseHome(true)
+		return c.sendResults(StatusEnvironmentError)
 	}
 
-	return c.sendResults(status)
-}
+	stages, err := c.pipeline.Run(ctx)
+	if err != nil {
+		c.recordError("pipeline", fmt.Sprintf("Invalid stage pipeline: %v", err))
+	}
+	c.stages = stages
+	// measured before cleanup removes the playground data
+	c.homeUsage.DiskUsage = dirSize(c.tiupHome())
 
-func (c *Checker) runChecks(ctx context.Context, playground **exec.Cmd) bool {
-	// Step 1: Download check
-	logger.Info("Step 1: Checking TiUP downloads...")
-	if err := c.checkTiUPDownload(ctx); err != nil {
-		logger.Error(fmt.Sprintf("Download check failed: %v", err))
-		return false
+	// grab the component logs before cleanup stops the playground
+	if needsComponentLogs(c.stages) {
+		c.collectComponentLogs()
 	}
-	logger.Info("Download check completed successfully")
 
-	// Step 2: Start playground
-	logger.Info("Step 2: Starting playground...")
-	var err error
-	*playground, err = c.startPlayground(ctx)
-	if err != nil {
-		logger.Error(fmt.Sprintf("Playground startup failed: %v", err))
-		return false
+	// clean up whatever the stages left running, e.g. the playground process
+	for _, err := range c.pipeline.Cleanup(context.Background()) {
+		c.recordError("cleanup", err.Error())
 	}
 
-	// Step 3: Run smoke tests
-	logger.Info("Step 3: Running smoke tests...")
-	if err := c.runSmokeTest(ctx); err != nil {
-		logger.Error(fmt.Sprintf("Smoke tests failed: %v", err))
-		return false
+	for _, stage := range c.stages {
+		if stage.Status == StageFailed {
+			status = StatusFailed
+		}
+	}
+	if len(c.failures()) > 0 {
+		status = StatusFailed
+	}
+	if status == StatusFailed && c.onlyEnvironmentErrors() {
+		status = StatusEnvironmentError
+	}
+	if status == StatusSuccess && c.retried() {
+		status = StatusFlaky
 	}
-	logger.Info("Smoke tests completed successfully")
 
-	return true
+	// Get TiUP version before sending report, while TIUP_HOME still exists
+	c.versions.TiUP = c.getTiUPVersion()
+	c.releaseHome(status != StatusSuccess && status != StatusFlaky)
+
+	return c.sendResults(status)
 }
 
 func (c *Checker) sendResults(status string) bool {
-	// Get TiUP version before sending report
-	c.versions.TiUP = c.getTiUPVersion()
-
 	// Send report
-	logger.Info("Step 4: Sending report...")
-	if err := c.sendReport(context.Background(), status); err != nil {
+	logger.Info("Sending report...")
+	reported := true
+	if id, err := c.sendReport(context.Background(), status); errors.Is(err, errReportSpooled) {
+		logger.Warn(fmt.Sprintf("Failed to send report, resent on next start: %v", err))
+	} else if err != nil {
 		logger.Error(fmt.Sprintf("Failed to send report: %v", err))
+		reported = false
 	} else {
-		logger.Info("Report sent successfully")
+		logger.Info(fmt.Sprintf("Report sent successfully, id: %d", id))
+		c.uploadArtifacts(context.Background(), id, c.artifacts)
 	}
 
 	// send notification after sending report
-	if len(c.errors) > 0 {
-		errors := make([]notify.ErrorDetail, 0, len(c.errors))
-		for _, err := range c.errors {
-			errors = append(errors, notify.ErrorDetail{
-				Stage:     err.Stage,
-				Error:     err.Error,
-				Timestamp: err.Timestamp,
-			})
+	if status == StatusFlaky {
+		if err := c.notifier.SendFlakyNotification(c.notifyLabel(), c.versions.TiUP, errorDetails(c.retriedErrors()), c.runDetail()); err != nil {
+			logger.Error(fmt.Sprintf("Failed to send flaky notification: %v", err))
+		}
+		return reported
+	}
+	if status != StatusSuccess {
+		send := c.notifier.SendFailureNotification
+		if status == StatusEnvironmentError {
+			send = c.notifier.SendEnvironmentNotification
 		}
-		if err := c.notifier.SendFailureNotification(c.platformInfo.Platform, c.versions.TiUP, errors); err != nil {
+		if err := send(c.notifyLabel(), c.versions.TiUP, errorDetails(c.failures()), c.runDetail()); err != nil {
 			logger.Error(fmt.Sprintf("Failed to send failure notification: %v", err))
 		}
 		return false
 	}
 
-	if err := c.notifier.SendSuccessNotification(c.platformInfo.Platform, c.versions.TiUP); err != nil {
+	if err := c.notifier.SendSuccessNotification(c.notifyLabel(), c.versions.TiUP, c.runDetail()); err != nil {
 		logger.Error(fmt.Sprintf("Failed to send success notification: %v", err))
 	}
+	return reported
+}
+
+func errorDetails(errs []Error) []notify.ErrorDetail {
+	details := make([]notify.ErrorDetail, 0, len(errs))
+	for _, err := range errs {
+		details = append(details, notify.ErrorDetail{
+			Stage:     err.Stage,
+			Error:     err.Error,
+			Category:  err.Category,
+			Attempt:   err.Attempt,
+			Timestamp: err.Timestamp,
+		})
+	}
+	return details
+}
+
+func (c *Checker) onlyEnvironmentErrors() bool {
+	failures := c.failures()
+	if len(failures) == 0 {
+		return false
+	}
+	for _, err := range failures {
+		if err.Category != CategoryEnvironment {
+			return false
+		}
+	}
 	return true
 }
 
-// helper functions
-func isValidComponent(component string) bool {
-	validComponents := map[string]bool{
-		"tidb":    true,
-		"pd":      true,
-		"tikv":    true,
-		"tiflash": true,
-	}
-	return validComponents[component]
+// notifyLabel names the platform, and the target when several are checked.
+func (c *Checker) notifyLabel() string {
+	if len(c.targets) == 1 && c.target.Mirror == "" {
+		return c.platformInfo.Platform
+	}
+	return fmt.Sprintf("%s (%s)", c.platformInfo.Platform, c.target)
 }
 
+// helper functions
 func extractBaseVersion(version string) string {
 	// First split by dash to get parts like ["9.0.0", "beta.1.pre", "394", "g1feea49553"]
 	parts := strings.Split(version, "-")
@@ -437,7 +445,7 @@ func extractBaseVersion(version string) string {
 }
 
 func (c *Checker) runCommand(ctx context.Context, name string, args ...string) error {
-	cmd := exec.CommandContext(ctx, name, args...)
+	cmd := c.command(ctx, name, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("command failed: %v, output: %s", err, string(output))
@@ -445,48 +453,36 @@ func (c *Checker) runCommand(ctx context.Context, name string, args ...string) e
 	return nil
 }
 
-func (c *Checker) sendReport(ctx context.Context, status string) error {
+// sendReport spools and posts the report and returns the id the server stored
+// it under.
+func (c *Checker) sendReport(ctx context.Context, status string) (int64, error) {
 	report := CheckReport{
-		Timestamp: time.Now().UTC(),
-		Status:    status,
-		Platform:  c.platformInfo.Platform,
-		OS:        c.platformInfo.OS,
-		Arch:      c.platformInfo.Arch,
-		Errors:    c.errors,
+		RunID:       c.runID,
+		Run:         &c.runInfo,
+		Timestamp:   time.Now().UTC(),
+		Status:      status,
+		Platform:    c.platformInfo.Platform,
+		OS:          c.platformInfo.OS,
+		Arch:        c.platformInfo.Arch,
+		Mirror:      c.target.Mirror,
+		Channel:     c.target.Channel,
+		Errors:      c.errors,
+		Stages:      c.stages,
+		Installs:    c.installs,
+		SmokeTests:  c.smokeTests,
+		Consistency: c.consistency,
+		Home:        &c.homeUsage,
 		Version: Versions{
 			TiUP:       c.versions.TiUP,
 			Components: c.versions.Components,
 		},
 	}
 
-	jsonData, err := json.Marshal(report)
-	logger.Info(fmt.Sprintf("Sending report: %s", string(jsonData)))
-	if err != nil {
-		return fmt.Errorf("failed to marshal report: %v", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", c.apiEndpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send report: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	return nil
+	return c.deliverReport(ctx, &report)
 }
 
 func (c *Checker) getTiUPVersion() string {
-	cmd := exec.Command("tiup", "--version")
+	cmd := c.command(context.Background(), "tiup", "--version")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.Error(fmt.Sprintf("Failed to get TiUP version: %v", err))
@@ -495,122 +491,27 @@ func (c *Checker) getTiUPVersion() string {
 	return strings.TrimSpace(string(output))
 }
 
-func (c *Checker) getGitHubCommitTime(ctx context.Context, component, hash string) (time.Time, error) {
-	// component to repository mapping
-	repoMap := map[string]string{
-		"tidb":    "pingcap/tidb",
-		"tikv":    "tikv/tikv",
-		"pd":      "tikv/pd",
-		"tiflash": "pingcap/tiflash",
-	}
-
-	repo, ok := repoMap[component]
+func (c *Checker) getGitHubCommitTime(ctx context.Context, name, hash string) (time.Time, error) {
+	comp, ok := c.registry.Get(name)
 	if !ok {
-		return time.Time{}, fmt.Errorf("unknown component: %s", component)
-	}
-
-	url := fmt.Sprintf("https://api.github.com/repos/%s/commits/%s", repo, hash)
-	logger.Info(fmt.Sprintf("Requesting GitHub API: %s", url))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return time.Time{}, err
-	}
-
-	if c.githubToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.githubToken))
-	} else {
-		logger.Error("GitHub token is empty")
-	}
-
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return time.Time{}, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		logger.Error(fmt.Sprintf("GitHub API error - Status: %d, Response: %s", resp.StatusCode, string(body)))
-		return time.Time{}, fmt.Errorf("GitHub API returned status: %d, body: %s", resp.StatusCode, string(body))
+		return time.Time{}, fmt.Errorf("unknown component: %s", name)
 	}
 
-	var result struct {
-		Commit struct {
-			Committer struct {
-				Date time.Time `json:"date"`
-			} `json:"committer"`
-		} `json:"commit"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return time.Time{}, err
-	}
-
-	return result.Commit.Committer.Date, nil
+	logger.Info(fmt.Sprintf("Looking up commit time of %s@%s", comp.Repo, hash))
+	return c.github.CommitTime(ctx, comp.Repo, hash)
 }
 
-func getMapKeys(m map[string]ComponentVersion) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
-	}
-	return keys
-}
-
-func FetchLatestCommitInfo(ctx context.Context, component, branch string) (*BranchCommitInfo, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/pingcap/%s/commits/%s", component, branch)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Add GitHub token if needed
-	// get token from environment variable
-	token := os.Getenv("GH_TOKEN")
-	if token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	} else {
-		logger.Error("GitHub token is empty")
-	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch commit info: %s", resp.Status)
-	}
-
-	var result struct {
-		SHA    string `json:"sha"`
-		Commit struct {
-			Committer struct {
-				Date string `json:"date"`
-			} `json:"committer"`
-		} `json:"commit"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	commitTime, err := time.Parse(time.RFC3339, result.Commit.Committer.Date)
+// FetchLatestCommitInfo returns the head of branch in the component's repository.
+func FetchLatestCommitInfo(ctx context.Context, gh *github.Client, comp component.Component, branch string) (*BranchCommitInfo, error) {
+	commit, err := gh.GetCommit(ctx, comp.Repo, branch)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to fetch commit info: %w", err)
 	}
 
 	return &BranchCommitInfo{
-		Component:  component,
+		Component:  comp.Name,
 		Branch:     branch,
-		GitHash:    result.SHA,
-		CommitTime: commitTime,
+		GitHash:    commit.SHA,
+		CommitTime: commit.Time,
 	}, nil
 }
@@ -0,0 +1,83 @@
+package checker
+
+import "regexp"
+
+// Error categories, so that product bugs can be told apart from flaky
+// infrastructure when reviewing failures.
+const (
+	CategoryNetwork         = "network"
+	CategoryPackageMissing  = "package_missing"
+	CategoryBinaryCrash     = "binary_crash"
+	CategoryStartupTimeout  = "startup_timeout"
+	CategorySQL             = "sql_failure"
+	CategoryVersionMismatch = "version_mismatch"
+	// CategoryEnvironment marks errors caused by the host running the checker
+	// rather than by the nightly build.
+	CategoryEnvironment = "environment"
+	CategoryInfra       = "infra"
+	CategoryUnknown     = "unknown"
+)
+
+// Categories lists every category Classify can return.
+var Categories = []string{
+	CategoryNetwork,
+	CategoryPackageMissing,
+	CategoryBinaryCrash,
+	CategoryStartupTimeout,
+	CategorySQL,
+	CategoryVersionMismatch,
+	CategoryEnvironment,
+	CategoryInfra,
+	CategoryUnknown,
+}
+
+// classifyRule matches an error by stage and message. Empty stages match any
+// stage, a nil pattern any message.
+type classifyRule struct {
+	category string
+	stages   []string
+	pattern  *regexp.Regexp
+}
+
+// classifyRules are tried in order, the first match wins. Host problems come
+// first because they surface in every stage, a crash or startup timeout before
+// network errors because the failing readiness probe is quoted in its message.
+var classifyRules = []classifyRule{
+	{CategoryEnvironment, []string{"preflight", "setup"}, nil},
+	{CategoryEnvironment, nil, regexp.MustCompile(`(?i)no space left on device|too many open files|address already in use|permission denied|disk quota exceeded`)},
+	{CategoryBinaryCrash, nil, regexp.MustCompile(`(?i)signal: (segmentation fault|aborted|bus error)|panic:|fatal error:|SIGSEGV|SIGABRT|core dumped|process exited`)},
+	// 128+n is a command killed by signal n: SIGABRT, SIGBUS, SIGSEGV
+	{CategoryBinaryCrash, nil, regexp.MustCompile(`exit status 13[459]\b`)},
+	{CategoryStartupTimeout, []string{"playground"}, regexp.MustCompile(`(?i)not ready|timed out|timeout|deadline exceeded`)},
+	{CategoryNetwork, nil, regexp.MustCompile(`(?i)no such host|i/o timeout|connection reset|TLS handshake|tls: |server misbehaving|network is unreachable|unexpected EOF|Client\.Timeout`)},
+	{CategoryPackageMissing, []string{"download"}, regexp.MustCompile(`(?i)not found|unknown component|no such version|not available|cannot find|doesn't exist|404`)},
+	{CategoryNetwork, []string{"download"}, regexp.MustCompile(`(?i)connection refused|timeout|timed out|status: 5\d\d|checksum|signature`)},
+	{CategoryVersionMismatch, []string{"version_check"}, nil},
+	{CategorySQL, []string{"smoke_test", "tiflash"}, nil},
+	{CategoryInfra, []string{"cleanup", "pipeline", "report"}, nil},
+	{CategoryInfra, nil, regexp.MustCompile(`(?i)rate limit`)},
+}
+
+// Classify maps an error to a category using its stage, the exit code of a
+// failed command and patterns in its output.
+func Classify(stage, message string) string {
+	for _, rule := range classifyRules {
+		if len(rule.stages) > 0 && !containsString(rule.stages, stage) {
+			continue
+		}
+		if rule.pattern != nil && !rule.pattern.MatchString(message) {
+			continue
+		}
+		return rule.category
+	}
+	return CategoryUnknown
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package checker
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		stage   string
+		message string
+		want    string
+	}{
+		// host problems, whatever the stage
+		{"preflight", "port 127.0.0.1:4000 is not available", CategoryEnvironment},
+		{"setup", "failed to create temporary TIUP_HOME", CategoryEnvironment},
+		{"download", "write /root/.tiup/components: no space left on device", CategoryEnvironment},
+		{"playground", "accept: Too many open files", CategoryEnvironment},
+		{"playground", "listen tcp 127.0.0.1:2379: bind: address already in use", CategoryEnvironment},
+
+		// crashes win over the timeouts and network errors they cause
+		{"playground", "tidb not ready: process exited", CategoryBinaryCrash},
+		{"smoke_test", "panic: runtime error: index out of range", CategoryBinaryCrash},
+		{"playground", "signal: segmentation fault (core dumped)", CategoryBinaryCrash},
+		{"version_check", "command failed: exit status 134", CategoryBinaryCrash},
+		{"version_check", "command failed: exit status 139, output: ", CategoryBinaryCrash},
+		{"version_check", "command failed: exit status 1345", CategoryVersionMismatch},
+
+		{"playground", "tikv not ready after 5m0s", CategoryStartupTimeout},
+		{"playground", "context deadline exceeded", CategoryStartupTimeout},
+		{"smoke_test", "context deadline exceeded", CategorySQL},
+
+		{"playground", "dial tcp: lookup pd: no such host", CategoryNetwork},
+		{"smoke_test", "read tcp 127.0.0.1:4000: connection reset by peer", CategoryNetwork},
+		{"report", "Post \"https://example.com\": net/http: TLS handshake timeout", CategoryNetwork},
+
+		{"download", "component tiflash doesn't exist", CategoryPackageMissing},
+		{"download", "unknown component `tidb-dashboard`", CategoryPackageMissing},
+		{"download", "fetch /nightly/tidb.json: status: 404", CategoryPackageMissing},
+		{"download", "dial tcp 1.2.3.4:443: connection refused", CategoryNetwork},
+		{"download", "fetch tidb: status: 503", CategoryNetwork},
+		{"download", "checksum mismatch", CategoryNetwork},
+		// connection refused outside download is a server that went away
+		{"smoke_test", "dial tcp 127.0.0.1:4000: connection refused", CategorySQL},
+
+		{"version_check", "tidb reports 8.4.0, want 8.5.0", CategoryVersionMismatch},
+		{"smoke_test", "Error 1146: Table 'test.t' doesn't exist", CategorySQL},
+		{"tiflash", "replica not available after 2m0s", CategorySQL},
+		{"cleanup", "failed to kill playground process group", CategoryInfra},
+		{"report", "failed to upload artifact", CategoryInfra},
+		{"github", "API rate limit exceeded", CategoryInfra},
+		{"github", "something odd", CategoryUnknown},
+		{"", "", CategoryUnknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.stage, tt.message); got != tt.want {
+			t.Errorf("Classify(%q, %q) = %s, want %s", tt.stage, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyRulesUseKnownCategories(t *testing.T) {
+	for i, rule := range classifyRules {
+		if !containsString(Categories, rule.category) {
+			t.Errorf("rule %d returns %s, which is not in Categories", i, rule.category)
+		}
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	c := &Checker{}
+	c.recordError("download", "component tiflash doesn't exist")
+	c.recordCategorizedError("download", CategoryEnvironment, "component tiflash doesn't exist")
+
+	want := []string{CategoryPackageMissing, CategoryEnvironment}
+	if len(c.errors) != len(want) {
+		t.Fatalf("recorded %d errors, want %d", len(c.errors), len(want))
+	}
+	for i, e := range c.errors {
+		if e.Category != want[i] || e.Stage != "download" || e.Timestamp.IsZero() {
+			t.Errorf("error %d = %+v, want category %s", i, e, want[i])
+		}
+	}
+}
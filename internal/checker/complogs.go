@@ -0,0 +1,217 @@
+package checker
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	componentLogsName = "component-logs.tar.gz"
+
+	// maxLogFileSize caps a single log file in the archive, larger files keep their tail
+	maxLogFileSize = 4 << 20
+	// maxLogsTotalSize caps the uncompressed size of all archived logs
+	maxLogsTotalSize = 48 << 20
+	// maxArchiveSize keeps the compressed archive within maxArtifactSize, the
+	// rest is room for the gzip and tar trailers
+	maxArchiveSize = maxArtifactSize - 64<<10
+	// tarEntryOverhead bounds the header and padding tar adds per file
+	tarEntryOverhead = 2 << 10
+)
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// needsComponentLogs reports whether a failed stage warrants collecting the
+// playground instance logs.
+func needsComponentLogs(stages []StageResult) bool {
+	for _, s := range stages {
+		if s.Status != StageFailed {
+			continue
+		}
+		switch s.Name {
+		case "playground", "smoke_test", "tiflash":
+			return true
+		}
+	}
+	return false
+}
+
+// collectComponentLogs archives the instance logs of the playground and
+// registers the archive as an artifact of the run.
+func (c *Checker) collectComponentLogs() {
+	dataDir := c.playgroundDataDir()
+	files, err := findLogFiles(dataDir)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to find component logs in %s: %v", dataDir, err))
+		return
+	}
+	if len(files) == 0 {
+		logger.Info(fmt.Sprintf("No component logs found in %s", dataDir))
+		return
+	}
+
+	if err := os.MkdirAll(c.runDir, 0755); err != nil {
+		logger.Error(fmt.Sprintf("Failed to create run directory: %v", err))
+		return
+	}
+	path := filepath.Join(c.runDir, componentLogsName)
+	if err := writeLogArchive(path, dataDir, files); err != nil {
+		logger.Error(fmt.Sprintf("Failed to archive component logs: %v", err))
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Archived %d component log files to %s", len(files), path))
+	c.artifacts = append(c.artifacts, artifact{
+		name:        componentLogsName,
+		path:        path,
+		contentType: "application/gzip",
+	})
+}
+
+// findLogFiles returns the log files below dir, smallest first so that a
+// single huge log cannot push all the others out of the archive.
+func findLogFiles(dir string) ([]string, error) {
+	type logFile struct {
+		path string
+		size int64
+	}
+	var found []logFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !strings.Contains(d.Name(), ".log") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		found = append(found, logFile{path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].size < found[j].size })
+	files := make([]string, 0, len(found))
+	for _, f := range found {
+		files = append(files, f.path)
+	}
+	return files, nil
+}
+
+// writeLogArchive archives the tails of files. The archive is never tailed
+// on upload, that would corrupt it, so it is kept within maxArchiveSize here:
+// compressed data is at most slightly larger than its input, so no file may
+// add more than the compressed budget left.
+func writeLogArchive(path, baseDir string, files []string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	counter := &countingWriter{w: out}
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+
+	var total int64
+	for _, file := range files {
+		// flushed so that the count covers everything archived so far
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if err := gz.Flush(); err != nil {
+			return err
+		}
+		compressedLeft := maxArchiveSize - counter.n - tarEntryOverhead
+		if total >= maxLogsTotalSize || compressedLeft <= 0 {
+			logger.Info(fmt.Sprintf("Log archive size limit reached, skipping %s", file))
+			continue
+		}
+		limit := int64(maxLogFileSize)
+		if remaining := maxLogsTotalSize - total; remaining < limit {
+			limit = remaining
+		}
+		if compressedLeft < limit {
+			limit = compressedLeft
+		}
+
+		written, err := addLogFile(tw, baseDir, file, limit)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to archive %s: %v", file, err))
+			continue
+		}
+		total += written
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// addLogFile writes at most limit bytes from the end of file into the archive.
+func addLogFile(tw *tar.Writer, baseDir, file string, limit int64) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	name, err := filepath.Rel(baseDir, file)
+	if err != nil {
+		name = filepath.Base(file)
+	}
+
+	size := info.Size()
+	if size > limit {
+		// keep the tail, that is where the failure is
+		if _, err := f.Seek(-limit, io.SeekEnd); err != nil {
+			return 0, err
+		}
+		name += ".truncated"
+		size = limit
+	}
+
+	hdr := &tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    0644,
+		Size:    size,
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	// the file may still be growing, never write more than announced
+	return io.CopyN(tw, f, size)
+}
@@ -0,0 +1,170 @@
+package checker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeedsComponentLogs(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []StageResult
+		want   bool
+	}{
+		{name: "all passed", stages: []StageResult{{Name: "playground", Status: StagePassed}}},
+		{name: "download failed", stages: []StageResult{{Name: "download", Status: StageFailed}}},
+		{name: "playground skipped", stages: []StageResult{{Name: "playground", Status: StageSkipped}}},
+		{name: "playground failed", stages: []StageResult{{Name: "playground", Status: StageFailed}}, want: true},
+		{
+			name:   "smoke test failed",
+			stages: []StageResult{{Name: "playground", Status: StagePassed}, {Name: "smoke_test", Status: StageFailed}},
+			want:   true,
+		},
+		{name: "tiflash failed", stages: []StageResult{{Name: "tiflash", Status: StageFailed}}, want: true},
+	}
+	for _, tt := range tests {
+		if got := needsComponentLogs(tt.stages); got != tt.want {
+			t.Errorf("%s: needsComponentLogs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tidb-0", "tidb.log"), make([]byte, 30))
+	writeFile(t, filepath.Join(dir, "tikv-0", "tikv.log"), make([]byte, 10))
+	writeFile(t, filepath.Join(dir, "tikv-0", "tikv_stderr.log.1"), make([]byte, 20))
+	writeFile(t, filepath.Join(dir, "tikv-0", "data", "db"), make([]byte, 5))
+
+	files, err := findLogFiles(dir)
+	if err != nil {
+		t.Fatalf("findLogFiles: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "tikv-0", "tikv.log"),
+		filepath.Join(dir, "tikv-0", "tikv_stderr.log.1"),
+		filepath.Join(dir, "tidb-0", "tidb.log"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("findLogFiles = %v, want %v", files, want)
+	}
+
+	if _, err := findLogFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("findLogFiles of a missing directory succeeded")
+	}
+}
+
+// readArchive returns the size of every file in a tar.gz archive
+func readArchive(t *testing.T, path string) map[string]int64 {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("archive is not gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	sizes := make(map[string]int64)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return sizes
+		}
+		if err != nil {
+			t.Fatalf("corrupt archive: %v", err)
+		}
+		n, err := io.Copy(io.Discard, tr)
+		if err != nil {
+			t.Fatalf("corrupt archive entry %s: %v", hdr.Name, err)
+		}
+		sizes[hdr.Name] = n
+	}
+}
+
+func TestWriteLogArchive(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "pd-0", "pd.log")
+	writeFile(t, small, []byte("pd started\n"))
+	big := filepath.Join(dir, "tidb-0", "tidb.log")
+	writeFile(t, big, append(bytes.Repeat([]byte("x"), maxLogFileSize), []byte("panic: boom\n")...))
+
+	path := filepath.Join(t.TempDir(), componentLogsName)
+	if err := writeLogArchive(path, dir, []string{small, big, filepath.Join(dir, "missing.log")}); err != nil {
+		t.Fatalf("writeLogArchive: %v", err)
+	}
+
+	want := map[string]int64{
+		"pd-0/pd.log":               11,
+		"tidb-0/tidb.log.truncated": maxLogFileSize,
+	}
+	if got := readArchive(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive = %v, want %v", got, want)
+	}
+}
+
+func TestWriteLogArchiveCap(t *testing.T) {
+	// random data does not compress, the compressed cap is what limits it
+	dir := t.TempDir()
+	rng := rand.New(rand.NewSource(1))
+	var files []string
+	for _, name := range []string{"a.log", "b.log", "c.log", "d.log"} {
+		data := make([]byte, 2<<20)
+		rng.Read(data)
+		path := filepath.Join(dir, name)
+		writeFile(t, path, data)
+		files = append(files, path)
+	}
+
+	path := filepath.Join(t.TempDir(), componentLogsName)
+	if err := writeLogArchive(path, dir, files); err != nil {
+		t.Fatalf("writeLogArchive: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > maxArtifactSize {
+		t.Errorf("archive is %d bytes, over the %d byte artifact limit", info.Size(), maxArtifactSize)
+	}
+
+	sizes := readArchive(t, path)
+	var total int64
+	for name, size := range sizes {
+		total += size
+		if strings.HasSuffix(name, ".truncated") && size >= 2<<20 {
+			t.Errorf("%s is marked truncated with %d bytes", name, size)
+		}
+	}
+	if sizes["a.log"] != 2<<20 || total < maxArchiveSize/2 {
+		t.Errorf("archive = %v, want the first files whole and most of the budget used", sizes)
+	}
+}
+
+func TestCollectComponentLogs(t *testing.T) {
+	c := &Checker{home: t.TempDir(), playgroundTag: "check", runDir: filepath.Join(t.TempDir(), "run")}
+	c.collectComponentLogs()
+	if len(c.artifacts) != 0 {
+		t.Fatalf("artifacts without logs = %+v", c.artifacts)
+	}
+
+	writeFile(t, filepath.Join(c.playgroundDataDir(), "tikv-0", "tikv.log"), []byte("tikv started\n"))
+	c.collectComponentLogs()
+	want := []artifact{{name: componentLogsName, path: filepath.Join(c.runDir, componentLogsName), contentType: "application/gzip"}}
+	if !reflect.DeepEqual(c.artifacts, want) {
+		t.Fatalf("artifacts = %+v, want %+v", c.artifacts, want)
+	}
+	if got := readArchive(t, want[0].path); got["tikv-0/tikv.log"] != 13 {
+		t.Errorf("archive = %v, want tikv-0/tikv.log", got)
+	}
+}
@@ -0,0 +1,197 @@
+package checker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	HashMatch    = "match"
+	HashMismatch = "mismatch"
+	HashUnknown  = "unknown"
+)
+
+var gitHashPattern = regexp.MustCompile(`Git Commit Hash:\s*([0-9a-fA-F]{40})`)
+
+func (c *Checker) checkVersionConsistency(ctx context.Context, db *sql.DB) error {
+	logger.Info("Checking version consistency...")
+	logger.Info("Querying information_schema.cluster_info...")
+	rows, err := db.QueryContext(ctx,
+		"SELECT TYPE, INSTANCE, STATUS_ADDRESS, VERSION, GIT_HASH FROM information_schema.cluster_info")
+	if err != nil {
+		c.recordError("version_check", fmt.Sprintf("Failed to query cluster_info: %v", err))
+		return err
+	}
+	defer rows.Close()
+
+	var instances []InstanceVersion
+	var problems []string
+	for rows.Next() {
+		var iv InstanceVersion
+		var statusAddr string
+		if err := rows.Scan(&iv.Component, &iv.Instance, &statusAddr, &iv.Version, &iv.GitHash); err != nil {
+			problems = append(problems, fmt.Sprintf("failed to scan cluster_info row: %v", err))
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Found component in cluster_info - Type: %s, Instance: %s, Status: %s",
+			iv.Component, iv.Instance, statusAddr))
+
+		// cluster_info reports roles, reports and branch heads use component names
+		comp, ok := c.registry.ByRole(iv.Component)
+		if !ok {
+			logger.Info(fmt.Sprintf("Skipping unknown component: %s (no registered component has this role)", iv.Component))
+			continue
+		}
+		iv.Component = comp.Name
+		iv.BaseVersion = extractBaseVersion(iv.Version)
+		instances = append(instances, iv)
+	}
+	if err := rows.Err(); err != nil {
+		problems = append(problems, fmt.Sprintf("failed to read cluster_info: %v", err))
+	}
+
+	expectedHashes := c.installedHashes(ctx, instances)
+	reference := majorityBaseVersion(instances)
+
+	for i := range instances {
+		iv := &instances[i]
+
+		iv.VersionMatch = iv.BaseVersion == reference
+		if !iv.VersionMatch {
+			problems = append(problems, fmt.Sprintf("Version mismatch: %s %s has version %s, expected %s",
+				iv.Component, iv.Instance, iv.BaseVersion, reference))
+		}
+
+		if len(iv.GitHash) != 40 {
+			problems = append(problems, fmt.Sprintf("Invalid git hash for %s %s: %s", iv.Component, iv.Instance, iv.GitHash))
+		}
+
+		iv.ExpectedHash = expectedHashes[iv.Component]
+		switch {
+		case iv.ExpectedHash == "":
+			iv.HashStatus = HashUnknown
+		case strings.EqualFold(iv.ExpectedHash, iv.GitHash):
+			iv.HashStatus = HashMatch
+		default:
+			iv.HashStatus = HashMismatch
+			problems = append(problems, fmt.Sprintf("Git hash mismatch: %s %s runs %s, tiup installed %s",
+				iv.Component, iv.Instance, iv.GitHash, iv.ExpectedHash))
+		}
+
+		logger.Info(fmt.Sprintf("Component: %s, Instance: %s, Version: %s, GitHash: %s, Expected: %s",
+			iv.Component, iv.Instance, iv.Version, iv.GitHash, iv.ExpectedHash))
+
+		// one entry per component, the instances are compared above
+		if _, ok := c.versions.Components[iv.Component]; !ok {
+			commitTime, err := c.getGitHubCommitTime(ctx, iv.Component, iv.GitHash)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to get commit time for %s: %v", iv.Component, err))
+				commitTime = time.Time{} // if failed, use zero value
+			}
+			c.versions.Components[iv.Component] = ComponentVersion{
+				FullVersion: iv.Version,
+				BaseVersion: iv.BaseVersion,
+				GitHash:     iv.GitHash,
+				CommitTime:  commitTime,
+			}
+		}
+	}
+	c.consistency = instances
+
+	for _, p := range problems {
+		c.recordError("version_check", p)
+	}
+
+	logger.Info(fmt.Sprintf("Version consistency check completed. Total instances checked: %d", len(instances)))
+	if len(problems) > 0 {
+		return fmt.Errorf("%d version consistency problems", len(problems))
+	}
+	if len(instances) == 0 {
+		c.recordError("version_check", "No components found in cluster_info")
+		return fmt.Errorf("no components found in cluster_info")
+	}
+	return nil
+}
+
+// majorityBaseVersion returns the base version most instances run, so that a
+// single outdated instance is the one reported instead of all others.
+func majorityBaseVersion(instances []InstanceVersion) string {
+	counts := make(map[string]int)
+	for _, iv := range instances {
+		counts[iv.BaseVersion]++
+	}
+	versions := make([]string, 0, len(counts))
+	for v := range counts {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if counts[versions[i]] != counts[versions[j]] {
+			return counts[versions[i]] > counts[versions[j]]
+		}
+		return versions[i] < versions[j]
+	})
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[0]
+}
+
+// installedHashes asks the binaries tiup installed for their git hash, keyed by component.
+func (c *Checker) installedHashes(ctx context.Context, instances []InstanceVersion) map[string]string {
+	hashes := make(map[string]string)
+	for _, iv := range instances {
+		if _, done := hashes[iv.Component]; done {
+			continue
+		}
+		hash, err := c.installedHash(ctx, iv.Component)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to get installed git hash of %s: %v", iv.Component, err))
+		}
+		hashes[iv.Component] = hash
+	}
+	return hashes
+}
+
+func (c *Checker) installedHash(ctx context.Context, name string) (string, error) {
+	comp, ok := c.registry.Get(name)
+	if !ok || len(comp.VersionArgs) == 0 {
+		return "", fmt.Errorf("no version command for %s", name)
+	}
+
+	out, err := c.command(ctx, "tiup", "--binary", c.componentPackage(comp.Package)).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate binary: %v", err)
+	}
+	binary := strings.TrimSpace(string(out))
+
+	out, err = exec.CommandContext(ctx, binary, comp.VersionArgs...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %v", binary, err)
+	}
+
+	m := gitHashPattern.FindSubmatch(out)
+	if m == nil {
+		return "", fmt.Errorf("no git hash in output of %s", binary)
+	}
+	return strings.ToLower(string(m[1])), nil
+}
+
+// componentPackage returns "name:version" for a tiup package as the download stage installs it.
+func (c *Checker) componentPackage(pkg string) string {
+	for _, comp := range c.components {
+		name, version, ok := strings.Cut(comp, ":")
+		if name == pkg && ok && version != "" {
+			return comp
+		}
+	}
+	return pkg + ":" + c.channel
+}
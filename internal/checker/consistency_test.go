@@ -0,0 +1,133 @@
+package checker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/component"
+)
+
+func TestMajorityBaseVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		want     string
+	}{
+		{name: "none", want: ""},
+		{name: "one", versions: []string{"9.0.0-beta"}, want: "9.0.0-beta"},
+		{name: "one outdated instance", versions: []string{"9.0.0-beta", "8.5.0-alpha", "9.0.0-beta"}, want: "9.0.0-beta"},
+		{name: "tie picks the lowest", versions: []string{"9.0.0-beta", "8.5.0-alpha"}, want: "8.5.0-alpha"},
+	}
+	for _, tt := range tests {
+		var instances []InstanceVersion
+		for _, v := range tt.versions {
+			instances = append(instances, InstanceVersion{BaseVersion: v})
+		}
+		if got := majorityBaseVersion(instances); got != tt.want {
+			t.Errorf("%s: majorityBaseVersion = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractBaseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "9.0.0-beta.1.pre-394-g1feea49553", want: "9.0.0-beta"},
+		{version: "8.5.0-alpha-52-g0123456", want: "8.5.0-alpha"},
+		{version: "8.5.0", want: "8.5.0"},
+		{version: "v8.5.0-nightly", want: "v8.5.0-nightly"},
+	}
+	for _, tt := range tests {
+		if got := extractBaseVersion(tt.version); got != tt.want {
+			t.Errorf("extractBaseVersion(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestGitHashPattern(t *testing.T) {
+	hash := "1feea49553ec8fd2e7a8c2e2c4f3b6d0a9e1f2c3"
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "Release Version: v9.0.0\nGit Commit Hash: " + hash + "\nGit Branch: master\n", want: hash},
+		{output: "Git Commit Hash:   " + strings.ToUpper(hash), want: strings.ToUpper(hash)},
+		{output: "Git Commit Hash: 1feea49553"},
+		{output: "TiFlash 9.0.0"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if m := gitHashPattern.FindStringSubmatch(tt.output); m != nil {
+			got = m[1]
+		}
+		if got != tt.want {
+			t.Errorf("gitHashPattern in %q = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestComponentPackage(t *testing.T) {
+	c := &Checker{channel: "nightly", components: []string{"tidb", "tikv:v8.5.0", "pd:"}}
+	tests := map[string]string{
+		"tidb":    "tidb:nightly",
+		"tikv":    "tikv:v8.5.0",
+		"pd":      "pd:nightly",
+		"tiflash": "tiflash:nightly",
+	}
+	for pkg, want := range tests {
+		if got := c.componentPackage(pkg); got != want {
+			t.Errorf("componentPackage(%s) = %s, want %s", pkg, got, want)
+		}
+	}
+}
+
+func TestInstalledHash(t *testing.T) {
+	hash := "1feea49553ec8fd2e7a8c2e2c4f3b6d0a9e1f2c3"
+	bin := t.TempDir()
+	writeFile(t, filepath.Join(bin, "tidb-server"), []byte("#!/bin/sh\necho 'Git Commit Hash: "+strings.ToUpper(hash)+"'\n"))
+	writeFile(t, filepath.Join(bin, "pd-server"), []byte("#!/bin/sh\necho 'no hash here'\n"))
+	for _, name := range []string{"tidb-server", "pd-server"} {
+		if err := os.Chmod(filepath.Join(bin, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// tiup --binary tidb:nightly prints the path of the tidb binary
+	fakeTiUP(t, `
+[ "$1" = --binary ] || exit 2
+case "$2" in
+tidb:nightly) echo "`+bin+`/tidb-server" ;;
+pd:nightly) echo "`+bin+`/pd-server" ;;
+*) echo "component not installed" >&2; exit 1 ;;
+esac
+`)
+
+	registry := component.Default()
+	c := &Checker{channel: "nightly", registry: registry}
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{name: "tidb", want: hash},
+		{name: "pd", wantErr: "no git hash"},
+		{name: "tikv", wantErr: "failed to locate binary"},
+		{name: "cdc", wantErr: "no version command"},
+	}
+	for _, tt := range tests {
+		got, err := c.installedHash(context.Background(), tt.name)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("installedHash(%s) = %q, %v, want error %q", tt.name, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("installedHash(%s) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package checker
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// installComponent installs one component and measures how long it took and
+// how many bytes it added to the tiup components directory. comp is either a
+// bare component name, which is installed from the configured channel, or a
+// "name:version" pair.
+func (c *Checker) installComponent(ctx context.Context, comp string) ComponentInstall {
+	name, version, ok := strings.Cut(comp, ":")
+	if !ok || version == "" {
+		version = c.channel
+	}
+	pkg := name + ":" + version
+
+	dir := filepath.Join(c.tiupHome(), "components", name)
+	before := dirSize(dir)
+
+	start := time.Now()
+	err := c.runCommand(ctx, "tiup", "install", pkg)
+	install := ComponentInstall{
+		Component: name,
+		Package:   pkg,
+		Success:   err == nil,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		install.Error = err.Error()
+		return install
+	}
+
+	if delta := dirSize(dir) - before; delta > 0 {
+		install.Bytes = delta
+	}
+	return install
+}
+
+// dirSize returns the total size of the regular files below dir, 0 if it does not exist.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
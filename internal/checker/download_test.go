@@ -0,0 +1,87 @@
+package checker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTiUP puts a tiup shell script first on PATH for the test
+func fakeTiUP(t *testing.T, script string) {
+	t.Helper()
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "tiup"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestInstallComponent(t *testing.T) {
+	// installs 1000 bytes per component, "broken" fails
+	fakeTiUP(t, `
+[ "$1" = install ] || exit 2
+name=${2%%:*}
+[ "$name" = broken ] && { echo "no such component" >&2; exit 1; }
+mkdir -p "$TIUP_HOME/components/$name/${2#*:}"
+head -c 1000 /dev/zero > "$TIUP_HOME/components/$name/${2#*:}/bin"
+`)
+	c := &Checker{home: t.TempDir(), channel: "nightly"}
+
+	tests := []struct {
+		comp      string
+		component string
+		pkg       string
+		success   bool
+		bytes     int64
+	}{
+		{comp: "tidb", component: "tidb", pkg: "tidb:nightly", success: true, bytes: 1000},
+		{comp: "pd:v8.5.0", component: "pd", pkg: "pd:v8.5.0", success: true, bytes: 1000},
+		{comp: "cdc:", component: "cdc", pkg: "cdc:nightly", success: true, bytes: 1000},
+		// already installed, nothing added
+		{comp: "tidb", component: "tidb", pkg: "tidb:nightly", success: true, bytes: 0},
+		{comp: "broken", component: "broken", pkg: "broken:nightly"},
+	}
+	for _, tt := range tests {
+		install := c.installComponent(context.Background(), tt.comp)
+		if install.Component != tt.component || install.Package != tt.pkg || install.Success != tt.success || install.Bytes != tt.bytes {
+			t.Errorf("installComponent(%q) = %+v, want %s %s success %v bytes %d",
+				tt.comp, install, tt.component, tt.pkg, tt.success, tt.bytes)
+		}
+		if tt.success != (install.Error == "") {
+			t.Errorf("installComponent(%q) error = %q", tt.comp, install.Error)
+		}
+		if !tt.success && !strings.Contains(install.Error, "no such component") {
+			t.Errorf("installComponent(%q) error = %q, want the command output", tt.comp, install.Error)
+		}
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{"a": 10, "sub/b": 20, "sub/deeper/c": 30}
+	for name, size := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir  string
+		want int64
+	}{
+		{dir: dir, want: 60},
+		{dir: filepath.Join(dir, "sub"), want: 50},
+		{dir: filepath.Join(dir, "missing"), want: 0},
+	}
+	for _, tt := range tests {
+		if got := dirSize(tt.dir); got != tt.want {
+			t.Errorf("dirSize(%s) = %d, want %d", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// prepareHome creates the temporary TIUP_HOME of an isolated run and seeds it
+// from the warm cache. It does nothing when isolation is off.
+func (c *Checker) prepareHome() error {
+	c.homeUsage = HomeUsage{Isolated: c.isolate}
+	if !c.isolate {
+		return nil
+	}
+
+	home, err := os.MkdirTemp("", "tiup-home-"+c.target.dirName()+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary TIUP_HOME: %v", err)
+	}
+	c.home = home
+
+	if c.warmCacheDir != "" {
+		logger.Info(fmt.Sprintf("Seeding %s from warm cache %s", home, c.warmCacheDir))
+		if err := copyTree(c.warmCacheDir, home); err != nil {
+			return fmt.Errorf("failed to seed TIUP_HOME from %s: %v", c.warmCacheDir, err)
+		}
+		c.homeUsage.SeedBytes = dirSize(home)
+	}
+	logger.Info(fmt.Sprintf("Using temporary TIUP_HOME %s", home))
+	return nil
+}
+
+// releaseHome removes the temporary TIUP_HOME, or keeps it when the run
+// failed and keeping was requested.
+func (c *Checker) releaseHome(failed bool) {
+	if !c.isolate || c.home == "" {
+		return
+	}
+	if failed && c.keepHomeOnFailure {
+		c.homeUsage.KeptPath = c.home
+		logger.Info(fmt.Sprintf("Keeping TIUP_HOME of failed run at %s", c.home))
+		return
+	}
+	if err := os.RemoveAll(c.home); err != nil {
+		c.recordError("cleanup", fmt.Sprintf("Failed to remove temporary TIUP_HOME: %v", err))
+	}
+}
+
+// cacheSize is the size of TIUP_HOME without playground data, i.e. what
+// tiup downloaded or was seeded with.
+func (c *Checker) cacheSize() int64 {
+	home := c.tiupHome()
+	return dirSize(home) - dirSize(filepath.Join(home, "data"))
+}
+
+// copyTree copies the warm cache into an empty TIUP_HOME. Playground data is
+// skipped, it belongs to the run that created it.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && rel == "data" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
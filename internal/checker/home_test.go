@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(src, "components/tidb/v8.5.0/tidb-server"), []byte("binary"))
+	writeFile(t, filepath.Join(src, "bin/root.json"), []byte("{}"))
+	writeFile(t, filepath.Join(src, "data/run-1/tidb.log"), []byte("playground data"))
+	if err := os.Chmod(filepath.Join(src, "components/tidb/v8.5.0/tidb-server"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("v8.5.0", filepath.Join(src, "components/tidb/latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		data string
+	}{
+		{"components/tidb/v8.5.0/tidb-server", "binary"},
+		{"bin/root.json", "{}"},
+		{"components/tidb/latest/tidb-server", "binary"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dst, tt.path))
+		if err != nil || string(data) != tt.data {
+			t.Errorf("copied %s = %q, %v, want %q", tt.path, data, err, tt.data)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dst, "components/tidb/v8.5.0/tidb-server")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("binary mode = %v, %v, want 0755", info.Mode(), err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "components/tidb/latest")); err != nil || link != "v8.5.0" {
+		t.Errorf("symlink = %q, %v, want v8.5.0", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "data")); !os.IsNotExist(err) {
+		t.Errorf("playground data was copied: %v", err)
+	}
+}
+
+func TestPrepareHome(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	cache := t.TempDir()
+	writeFile(t, filepath.Join(cache, "components/pd/bin"), []byte(strings.Repeat("x", 100)))
+	writeFile(t, filepath.Join(cache, "data/old/pd.log"), []byte(strings.Repeat("x", 50)))
+
+	tests := []struct {
+		name      string
+		isolate   bool
+		cache     string
+		wantHome  bool
+		seedBytes int64
+		wantErr   bool
+	}{
+		{name: "shared home", cache: cache},
+		{name: "isolated", isolate: true, wantHome: true},
+		{name: "isolated with warm cache", isolate: true, cache: cache, wantHome: true, seedBytes: 100},
+		{name: "missing warm cache", isolate: true, cache: filepath.Join(cache, "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{isolate: tt.isolate, warmCacheDir: tt.cache, target: Target{Channel: "nightly"}}
+			err := c.prepareHome()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("prepareHome succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prepareHome: %v", err)
+			}
+			if !tt.wantHome {
+				if c.home != "" || c.homeUsage.Isolated {
+					t.Errorf("home = %q, usage = %+v, want the shared home", c.home, c.homeUsage)
+				}
+				return
+			}
+			defer os.RemoveAll(c.home)
+			if info, err := os.Stat(c.home); err != nil || !info.IsDir() || !strings.Contains(filepath.Base(c.home), "nightly") {
+				t.Errorf("home = %q, %v, want a temporary directory named after the target", c.home, err)
+			}
+			if !c.homeUsage.Isolated || c.homeUsage.SeedBytes != tt.seedBytes {
+				t.Errorf("usage = %+v, want isolated with %d seed bytes", c.homeUsage, tt.seedBytes)
+			}
+		})
+	}
+}
+
+func TestReleaseHome(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed bool
+		keep   bool
+		kept   bool
+	}{
+		{name: "passed", keep: true},
+		{name: "failed", failed: true},
+		{name: "failed and kept", failed: true, keep: true, kept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			c := &Checker{isolate: true, home: home, keepHomeOnFailure: tt.keep}
+			c.releaseHome(tt.failed)
+
+			_, err := os.Stat(home)
+			if exists := err == nil; exists != tt.kept {
+				t.Errorf("home exists = %v, want %v", exists, tt.kept)
+			}
+			if kept := c.homeUsage.KeptPath == home; kept != tt.kept {
+				t.Errorf("kept path = %q, want kept %v", c.homeUsage.KeptPath, tt.kept)
+			}
+		})
+	}
+
+	// a shared home is never removed
+	home := t.TempDir()
+	(&Checker{home: home}).releaseHome(false)
+	if _, err := os.Stat(home); err != nil {
+		t.Errorf("shared home was removed: %v", err)
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	home := t.TempDir()
+	writeFile(t, filepath.Join(home, "components/tikv/bin"), []byte(strings.Repeat("x", 300)))
+	writeFile(t, filepath.Join(home, "data/run/tikv.log"), []byte(strings.Repeat("x", 200)))
+	c := &Checker{home: home}
+	if got := c.cacheSize(); got != 300 {
+		t.Errorf("cacheSize = %d, want 300 without playground data", got)
+	}
+}
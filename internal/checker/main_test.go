@@ -0,0 +1,22 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "checker-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(filepath.Join(dir, "test.log")); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
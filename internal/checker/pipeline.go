@@ -0,0 +1,312 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// Stage is a single step of a checker run. Stages are executed by a Pipeline
+// in dependency order; a stage only runs when every stage it depends on passed.
+type Stage interface {
+	// Name identifies the stage in logs, reports and DependsOn lists.
+	Name() string
+	// DependsOn returns the names of the stages that must pass first.
+	DependsOn() []string
+	// Run executes the stage. A non-nil error marks the stage as failed.
+	Run(ctx context.Context) error
+	// Cleanup releases whatever Run acquired. It is called once for every
+	// stage that was started, in reverse order, even if Run failed, and
+	// before every retry of a failed stage.
+	Cleanup(ctx context.Context) error
+}
+
+const (
+	StagePassed  = "passed"
+	StageFailed  = "failed"
+	StageSkipped = "skipped"
+)
+
+// ErrSkipStage is returned by Stage.Run when the stage does not apply to this
+// run, e.g. a TiFlash check without TiFlash in the topology.
+var ErrSkipStage = errors.New("stage skipped")
+
+// RetryPolicy lets a failed stage run again.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of runs, retries are off below 2.
+	MaxAttempts int
+	// Backoff is the wait before the second attempt, doubled for every further one.
+	Backoff time.Duration
+	// Categories are the error categories worth another attempt; an attempt
+	// is retried only if all its errors have one of them. Empty retries any failure.
+	Categories []string
+}
+
+// attemptTracker is told about the attempts of a stage, so that the errors an
+// attempt recorded can be classified and set aside when it is retried.
+type attemptTracker interface {
+	beginAttempt()
+	attemptCategories() []string
+	retryAttempt(attempt int)
+}
+
+type Pipeline struct {
+	stages   []Stage
+	skipped  map[string]bool
+	retries  map[string]RetryPolicy
+	started  []Stage
+	attempts attemptTracker
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	p := &Pipeline{
+		skipped: make(map[string]bool),
+		retries: make(map[string]RetryPolicy),
+	}
+	for _, s := range stages {
+		p.Register(s)
+	}
+	return p
+}
+
+// Register appends a stage to the pipeline. A stage registered under an
+// existing name replaces the previous one in place.
+func (p *Pipeline) Register(stage Stage) {
+	for i, s := range p.stages {
+		if s.Name() == stage.Name() {
+			p.stages[i] = stage
+			return
+		}
+	}
+	p.stages = append(p.stages, stage)
+}
+
+// Skip marks stages that must not run. Stages depending on them are skipped too.
+func (p *Pipeline) Skip(names ...string) {
+	for _, name := range names {
+		p.skipped[name] = true
+	}
+}
+
+// Retry sets the retry policy of a stage.
+func (p *Pipeline) Retry(name string, policy RetryPolicy) {
+	p.retries[name] = policy
+}
+
+// Names returns the stage names in execution order.
+func (p *Pipeline) Names() ([]string, error) {
+	ordered, err := p.order()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(ordered))
+	for _, s := range ordered {
+		names = append(names, s.Name())
+	}
+	return names, nil
+}
+
+// order sorts the stages topologically, keeping registration order among
+// stages whose dependencies are already satisfied.
+func (p *Pipeline) order() ([]Stage, error) {
+	byName := make(map[string]Stage, len(p.stages))
+	for _, s := range p.stages {
+		byName[s.Name()] = s
+	}
+	for _, s := range p.stages {
+		for _, dep := range s.DependsOn() {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("stage %s depends on unknown stage %s", s.Name(), dep)
+			}
+		}
+	}
+
+	ordered := make([]Stage, 0, len(p.stages))
+	placed := make(map[string]bool, len(p.stages))
+	for len(ordered) < len(p.stages) {
+		progress := false
+		for _, s := range p.stages {
+			if placed[s.Name()] {
+				continue
+			}
+			ready := true
+			for _, dep := range s.DependsOn() {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, s)
+				placed[s.Name()] = true
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, fmt.Errorf("dependency cycle between stages")
+		}
+	}
+	return ordered, nil
+}
+
+// Run executes all stages and returns one result per registered stage.
+// A failed stage does not stop the pipeline; only its dependents are skipped.
+func (p *Pipeline) Run(ctx context.Context) ([]StageResult, error) {
+	ordered, err := p.order()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]StageResult, 0, len(ordered))
+	status := make(map[string]string, len(ordered))
+	for i, s := range ordered {
+		name := s.Name()
+
+		if reason := p.skipReason(s, status); reason != "" {
+			logger.Info(fmt.Sprintf("Step %d: Skipping %s (%s)", i+1, name, reason))
+			status[name] = StageSkipped
+			results = append(results, StageResult{Name: name, Status: StageSkipped})
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Step %d: Running %s...", i+1, name))
+		p.started = append(p.started, s)
+		result := p.runStage(ctx, s)
+		status[name] = result.Status
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// runStage runs a stage, and again after a failure as long as its retry
+// policy allows. The result covers all attempts.
+func (p *Pipeline) runStage(ctx context.Context, s Stage) StageResult {
+	name := s.Name()
+	policy := p.retries[name]
+	backoff := policy.Backoff
+
+	var result StageResult
+	for attempt := 1; ; attempt++ {
+		if p.attempts != nil {
+			p.attempts.beginAttempt()
+		}
+		start := time.Now()
+		err := s.Run(ctx)
+		end := time.Now()
+
+		a := StageAttempt{
+			Attempt:  attempt,
+			Status:   StagePassed,
+			Start:    start.UTC(),
+			End:      end.UTC(),
+			Duration: end.Sub(start),
+		}
+		if errors.Is(err, ErrSkipStage) {
+			a.Status = StageSkipped
+			a.Error = err.Error()
+		} else if err != nil {
+			a.Status = StageFailed
+			a.Error = err.Error()
+		}
+		if attempt == 1 {
+			result = StageResult{Name: name, Start: a.Start}
+		}
+		result.Status = a.Status
+		result.End = a.End
+		result.Duration = result.End.Sub(result.Start)
+		result.Attempts = attempt
+		result.Error = a.Error
+		result.History = append(result.History, a)
+
+		if a.Status != StageFailed || attempt >= policy.MaxAttempts || !p.retryable(policy, name, err) {
+			break
+		}
+		logger.Warn(fmt.Sprintf("Stage %s attempt %d failed after %s, retrying in %s: %v",
+			name, attempt, a.Duration, backoff, err))
+		if err := s.Cleanup(ctx); err != nil {
+			logger.Error(fmt.Sprintf("Not retrying %s, cleanup failed: %v", name, err))
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if p.attempts != nil {
+			p.attempts.retryAttempt(attempt)
+		}
+		backoff *= 2
+	}
+
+	// a stage run once needs no history
+	if result.Attempts == 1 {
+		result.History = nil
+	}
+
+	switch result.Status {
+	case StageSkipped:
+		logger.Info(fmt.Sprintf("Stage %s skipped: %s", name, result.Error))
+	case StageFailed:
+		logger.Error(fmt.Sprintf("Stage %s failed after %s: %s", name, result.Duration, result.Error))
+	default:
+		if result.Attempts > 1 {
+			logger.Warn(fmt.Sprintf("Stage %s passed in %s after %d attempts", name, result.Duration, result.Attempts))
+		} else {
+			logger.Info(fmt.Sprintf("Stage %s passed in %s", name, result.Duration))
+		}
+	}
+	return result
+}
+
+// retryable reports whether the errors of a failed attempt are all of the
+// policy's categories. The stage error is classified if nothing was recorded.
+func (p *Pipeline) retryable(policy RetryPolicy, name string, err error) bool {
+	if len(policy.Categories) == 0 {
+		return true
+	}
+	var categories []string
+	if p.attempts != nil {
+		categories = p.attempts.attemptCategories()
+	}
+	if len(categories) == 0 {
+		categories = []string{Classify(name, err.Error())}
+	}
+	for _, category := range categories {
+		if !containsString(policy.Categories, category) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pipeline) skipReason(s Stage, status map[string]string) string {
+	if p.skipped[s.Name()] {
+		return "disabled"
+	}
+	for _, dep := range s.DependsOn() {
+		if status[dep] != StagePassed {
+			return fmt.Sprintf("dependency %s %s", dep, status[dep])
+		}
+	}
+	return ""
+}
+
+// Cleanup calls Cleanup on every started stage in reverse order and returns
+// the errors it collected.
+func (p *Pipeline) Cleanup(ctx context.Context) []error {
+	var errs []error
+	for i := len(p.started) - 1; i >= 0; i-- {
+		s := p.started[i]
+		if err := s.Cleanup(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", s.Name(), err))
+		}
+	}
+	p.started = nil
+	return errs
+}
@@ -0,0 +1,414 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStage fails or skips as told and records its calls in log
+type fakeStage struct {
+	name    string
+	deps    []string
+	errs    []error // returned by successive runs, the last one repeats
+	runs    int
+	cleanup error
+	log     *[]string
+}
+
+func (s *fakeStage) Name() string        { return s.name }
+func (s *fakeStage) DependsOn() []string { return s.deps }
+
+func (s *fakeStage) Run(ctx context.Context) error {
+	s.runs++
+	if s.log != nil {
+		*s.log = append(*s.log, "run "+s.name)
+	}
+	if len(s.errs) == 0 {
+		return nil
+	}
+	if s.runs > len(s.errs) {
+		return s.errs[len(s.errs)-1]
+	}
+	return s.errs[s.runs-1]
+}
+
+func (s *fakeStage) Cleanup(ctx context.Context) error {
+	if s.log != nil {
+		*s.log = append(*s.log, "cleanup "+s.name)
+	}
+	return s.cleanup
+}
+
+func stage(name string, deps ...string) *fakeStage {
+	return &fakeStage{name: name, deps: deps}
+}
+
+func failing(name string, err error, deps ...string) *fakeStage {
+	return &fakeStage{name: name, deps: deps, errs: []error{err}}
+}
+
+func TestPipelineOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		stages  []Stage
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "registration order",
+			stages: []Stage{stage("a"), stage("b"), stage("c")},
+			want:   []string{"a", "b", "c"},
+		},
+		{
+			name:   "dependencies first",
+			stages: []Stage{stage("verify", "start"), stage("start", "install"), stage("install")},
+			want:   []string{"install", "start", "verify"},
+		},
+		{
+			name:   "independent stages keep their place",
+			stages: []Stage{stage("a"), stage("c", "b"), stage("b"), stage("d")},
+			want:   []string{"a", "b", "d", "c"},
+		},
+		{
+			name:    "unknown dependency",
+			stages:  []Stage{stage("a", "missing")},
+			wantErr: "depends on unknown stage missing",
+		},
+		{
+			name:    "cycle",
+			stages:  []Stage{stage("a", "b"), stage("b", "a")},
+			wantErr: "dependency cycle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPipeline(tt.stages...).Names()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Names error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Names: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRegisterReplaces(t *testing.T) {
+	p := NewPipeline(stage("a"), stage("b"))
+	replacement := stage("a")
+	p.Register(replacement)
+
+	names, err := p.Names()
+	if err != nil {
+		t.Fatalf("Names: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("Names = %v, want [a b]", names)
+	}
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if replacement.runs != 1 {
+		t.Errorf("replacement ran %d times, want 1", replacement.runs)
+	}
+}
+
+func TestPipelineRunStatus(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name   string
+		stages []Stage
+		skip   []string
+		want   map[string]string
+	}{
+		{
+			name:   "all pass",
+			stages: []Stage{stage("a"), stage("b", "a")},
+			want:   map[string]string{"a": StagePassed, "b": StagePassed},
+		},
+		{
+			name:   "failure skips dependents only",
+			stages: []Stage{failing("a", boom), stage("b", "a"), stage("c", "b"), stage("d")},
+			want:   map[string]string{"a": StageFailed, "b": StageSkipped, "c": StageSkipped, "d": StagePassed},
+		},
+		{
+			name:   "disabled stage skips dependents",
+			stages: []Stage{stage("a"), stage("b", "a"), stage("c")},
+			skip:   []string{"a"},
+			want:   map[string]string{"a": StageSkipped, "b": StageSkipped, "c": StagePassed},
+		},
+		{
+			name:   "stage skipping itself skips dependents",
+			stages: []Stage{failing("a", fmt.Errorf("no tiflash: %w", ErrSkipStage)), stage("b", "a")},
+			want:   map[string]string{"a": StageSkipped, "b": StageSkipped},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPipeline(tt.stages...)
+			p.Skip(tt.skip...)
+			results, err := p.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			got := make(map[string]string, len(results))
+			for _, r := range results {
+				got[r.Name] = r.Status
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineSkippedStagesDoNotRun(t *testing.T) {
+	a, b := failing("a", errors.New("boom")), stage("b", "a")
+	if _, err := NewPipeline(a, b).Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if b.runs != 0 {
+		t.Errorf("dependent of a failed stage ran %d times", b.runs)
+	}
+}
+
+func TestPipelineCleanup(t *testing.T) {
+	var log []string
+	a := &fakeStage{name: "a", log: &log}
+	b := &fakeStage{name: "b", deps: []string{"a"}, errs: []error{errors.New("boom")}, log: &log}
+	c := &fakeStage{name: "c", deps: []string{"b"}, log: &log}
+	d := &fakeStage{name: "d", log: &log, cleanup: errors.New("busy")}
+
+	p := NewPipeline(a, b, c, d)
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	errs := p.Cleanup(context.Background())
+
+	// the failed stage is cleaned up too, the skipped one was never started
+	want := []string{"run a", "run b", "run d", "cleanup d", "cleanup b", "cleanup a"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("calls = %v, want %v", log, want)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "d: busy") {
+		t.Errorf("Cleanup errors = %v, want d: busy", errs)
+	}
+	if errs := p.Cleanup(context.Background()); len(errs) != 0 || len(log) != len(want) {
+		t.Errorf("second Cleanup cleaned up again")
+	}
+}
+
+func TestPipelineStageTiming(t *testing.T) {
+	slow := &slowStage{fakeStage: fakeStage{name: "slow"}, delay: 20 * time.Millisecond}
+	results, err := NewPipeline(slow, failing("broken", errors.New("boom"), "slow"), stage("after", "broken")).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		status      string
+		minDuration time.Duration
+		timed       bool
+		err         string
+	}{
+		{name: "slow", status: StagePassed, minDuration: 20 * time.Millisecond, timed: true},
+		{name: "broken", status: StageFailed, timed: true, err: "boom"},
+		{name: "after", status: StageSkipped},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("Run returned %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		r := results[i]
+		if r.Name != tt.name || r.Status != tt.status || r.Error != tt.err {
+			t.Errorf("result %d = %s %s %q, want %s %s %q", i, r.Name, r.Status, r.Error, tt.name, tt.status, tt.err)
+		}
+		if !tt.timed {
+			if !r.Start.IsZero() || r.Attempts != 0 {
+				t.Errorf("%s was timed without running: %+v", r.Name, r)
+			}
+			continue
+		}
+		if r.Start.IsZero() || r.End.Before(r.Start) || r.Duration != r.End.Sub(r.Start) || r.Duration < tt.minDuration {
+			t.Errorf("%s timing = %s to %s (%s), want at least %s", r.Name, r.Start, r.End, r.Duration, tt.minDuration)
+		}
+		if r.Start.Location() != time.UTC {
+			t.Errorf("%s start is not UTC", r.Name)
+		}
+		if r.Attempts != 1 || r.History != nil {
+			t.Errorf("%s attempts = %d, history = %v, want one attempt without history", r.Name, r.Attempts, r.History)
+		}
+	}
+	if results[1].Start.Before(results[0].End) {
+		t.Errorf("broken started before slow ended")
+	}
+}
+
+// slowStage takes delay to run
+type slowStage struct {
+	fakeStage
+	delay time.Duration
+}
+
+func (s *slowStage) Run(ctx context.Context) error {
+	time.Sleep(s.delay)
+	return s.fakeStage.Run(ctx)
+}
+
+// recordingStage records its errors on a checker before failing, like the
+// real stages do
+type recordingStage struct {
+	fakeStage
+	c        *Checker
+	category string
+}
+
+func (s *recordingStage) Run(ctx context.Context) error {
+	err := s.fakeStage.Run(ctx)
+	if err != nil && s.category != "" {
+		s.c.recordCategorizedError(s.name, s.category, err.Error())
+	}
+	return err
+}
+
+func TestPipelineRetry(t *testing.T) {
+	network := errors.New("dial tcp: i/o timeout")
+	missing := errors.New("component tiflash doesn't exist")
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		errs         []error
+		category     string
+		wantStatus   string
+		wantAttempts int
+	}{
+		{name: "no policy", errs: []error{network, nil}, wantStatus: StageFailed, wantAttempts: 1},
+		{name: "passes on retry", policy: RetryPolicy{MaxAttempts: 3}, errs: []error{network, nil}, wantStatus: StagePassed, wantAttempts: 2},
+		{name: "gives up", policy: RetryPolicy{MaxAttempts: 3}, errs: []error{network}, wantStatus: StageFailed, wantAttempts: 3},
+		{
+			name:   "retryable category",
+			policy: RetryPolicy{MaxAttempts: 3, Categories: []string{CategoryNetwork}},
+			errs:   []error{network, nil}, category: CategoryNetwork,
+			wantStatus: StagePassed, wantAttempts: 2,
+		},
+		{
+			name:   "other category",
+			policy: RetryPolicy{MaxAttempts: 3, Categories: []string{CategoryNetwork}},
+			errs:   []error{missing, nil}, category: CategoryPackageMissing,
+			wantStatus: StageFailed, wantAttempts: 1,
+		},
+		{
+			name:       "unrecorded error is classified",
+			policy:     RetryPolicy{MaxAttempts: 3, Categories: []string{CategoryNetwork}},
+			errs:       []error{network, nil},
+			wantStatus: StagePassed, wantAttempts: 2,
+		},
+		{
+			name:       "unrecorded error of another category",
+			policy:     RetryPolicy{MaxAttempts: 3, Categories: []string{CategoryNetwork}},
+			errs:       []error{missing, nil},
+			wantStatus: StageFailed, wantAttempts: 1,
+		},
+		{name: "skip is not retried", policy: RetryPolicy{MaxAttempts: 3}, errs: []error{ErrSkipStage, nil}, wantStatus: StageSkipped, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{}
+			s := &recordingStage{fakeStage: fakeStage{name: "download", errs: tt.errs}, c: c, category: tt.category}
+			p := NewPipeline(s)
+			p.Retry("download", tt.policy)
+			p.attempts = c
+
+			results, err := p.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			r := results[0]
+			if r.Status != tt.wantStatus || r.Attempts != tt.wantAttempts || s.runs != tt.wantAttempts {
+				t.Errorf("result = %s after %d attempts (%d runs), want %s after %d", r.Status, r.Attempts, s.runs, tt.wantStatus, tt.wantAttempts)
+			}
+			// a stage run once has no history
+			wantHistory := tt.wantAttempts
+			if wantHistory == 1 {
+				wantHistory = 0
+			}
+			if len(r.History) != wantHistory {
+				t.Errorf("history = %+v, want %d attempts", r.History, wantHistory)
+			}
+			// errors of retried attempts no longer fail the run
+			if failures := c.failures(); tt.category != "" && tt.wantStatus == StagePassed && len(failures) != 0 {
+				t.Errorf("failures = %+v, want the retried errors set aside", failures)
+			}
+		})
+	}
+}
+
+func TestPipelineRetryBackoff(t *testing.T) {
+	var log []string
+	s := &fakeStage{name: "download", errs: []error{errors.New("boom"), errors.New("boom"), nil}, log: &log}
+	p := NewPipeline(s)
+	p.Retry("download", RetryPolicy{MaxAttempts: 3, Backoff: 20 * time.Millisecond})
+
+	results, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	h := results[0].History
+	if len(h) != 3 {
+		t.Fatalf("history = %+v, want 3 attempts", h)
+	}
+	// the backoff doubles, 20ms before the second attempt and 40ms before the third
+	for i, want := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond} {
+		if wait := h[i+1].Start.Sub(h[i].End); wait < want {
+			t.Errorf("wait before attempt %d = %s, want at least %s", i+2, wait, want)
+		}
+	}
+	// every failed attempt is cleaned up before the next one
+	want := []string{"run download", "cleanup download", "run download", "cleanup download", "run download"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("calls = %v, want %v", log, want)
+	}
+}
+
+func TestPipelineRetryStops(t *testing.T) {
+	tests := []struct {
+		name    string
+		cleanup error
+		cancel  bool
+	}{
+		{name: "cleanup failed", cleanup: errors.New("busy")},
+		{name: "context done", cancel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			s := &fakeStage{name: "download", errs: []error{errors.New("boom"), nil}, cleanup: tt.cleanup}
+			p := NewPipeline(s)
+			p.Retry("download", RetryPolicy{MaxAttempts: 3, Backoff: time.Hour})
+
+			results, err := p.Run(ctx)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if results[0].Status != StageFailed || s.runs != 1 {
+				t.Errorf("result = %s after %d runs, want failed after 1", results[0].Status, s.runs)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// default playground ports, shifted by --port-offset
+const (
+	defaultTiDBPort         = 4000
+	defaultTiDBStatusPort   = 10080
+	defaultPDPort           = 2379
+	defaultPDPeerPort       = 2380
+	defaultTiKVPort         = 20160
+	defaultTiKVStatusPort   = 20180
+	defaultTiFlashPort      = 3930
+	defaultTiFlashProxyPort = 20170
+	defaultPrometheusPort   = 9090
+)
+
+const playgroundHost = "127.0.0.1"
+
+// topology derives playground flags and instance addresses from the config.
+type topology struct {
+	config.PlaygroundConfig
+}
+
+func (t topology) tidbAddr() string {
+	port := defaultTiDBPort + t.PortOffset
+	if t.DBPort != 0 {
+		port = t.DBPort
+	}
+	return net.JoinHostPort(playgroundHost, strconv.Itoa(port))
+}
+
+func (t topology) tidbStatusAddr() string {
+	return net.JoinHostPort(playgroundHost, strconv.Itoa(defaultTiDBStatusPort+t.PortOffset))
+}
+
+func (t topology) pdAddr() string {
+	port := defaultPDPort + t.PortOffset
+	if t.PDPort != 0 {
+		port = t.PDPort
+	}
+	return net.JoinHostPort(playgroundHost, strconv.Itoa(port))
+}
+
+// ports lists the addresses the first instance of every role listens on,
+// used to verify nothing is left behind after teardown.
+func (t topology) ports() []string {
+	addr := func(port int) string {
+		return net.JoinHostPort(playgroundHost, strconv.Itoa(port+t.PortOffset))
+	}
+
+	var addrs []string
+	if t.DB > 0 {
+		addrs = append(addrs, t.tidbAddr(), t.tidbStatusAddr())
+	}
+	if t.PD > 0 {
+		addrs = append(addrs, t.pdAddr(), addr(defaultPDPeerPort))
+	}
+	if t.KV > 0 {
+		addrs = append(addrs, addr(defaultTiKVPort), addr(defaultTiKVStatusPort))
+	}
+	if t.TiFlash+t.TiFlashWrite+t.TiFlashCompute > 0 {
+		addrs = append(addrs, addr(defaultTiFlashPort), addr(defaultTiFlashProxyPort))
+	}
+	if !t.WithoutMonitor {
+		addrs = append(addrs, addr(defaultPrometheusPort))
+	}
+	return addrs
+}
+
+func (t topology) hasTiFlash() bool {
+	return t.tiflashStores() > 0
+}
+
+// has reports whether the topology runs at least one instance of the role.
+func (t topology) has(role string) bool {
+	switch role {
+	case "tidb", "db":
+		return t.DB > 0
+	case "tikv", "kv":
+		return t.KV > 0
+	case "pd":
+		return t.PD > 0
+	case "tiflash":
+		return t.hasTiFlash()
+	}
+	return false
+}
+
+// tiflashStores is the number of TiFlash instances registering as stores in PD;
+// compute nodes of the disaggregated mode do not.
+func (t topology) tiflashStores() int {
+	return t.TiFlash + t.TiFlashWrite
+}
+
+// playgroundProcess is a running playground. A goroutine waits on the process
+// so that an early exit is noticed while the cluster is still starting.
+type playgroundProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	// err is the result of cmd.Wait, valid once done is closed
+	err error
+}
+
+func (p *playgroundProcess) exited() <-chan struct{} {
+	return p.done
+}
+
+func (c *Checker) startPlayground(ctx context.Context) (*playgroundProcess, error) {
+	logger.Info("Starting TiUP playground")
+
+	args := c.playgroundArgs()
+	logger.Info(fmt.Sprintf("Playground command: tiup %s", strings.Join(args, " ")))
+	cmd := c.command(ctx, "tiup", args...)
+	// own process group, so that teardown reaches the servers playground spawns
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// stream playground output to a per-run log file instead of pipes
+	// nobody reads, which could block the child once the pipe is full
+	logFile, err := c.createPlaygroundLog()
+	if err != nil {
+		c.recordError("playground", fmt.Sprintf("Failed to create playground log: %v", err))
+		return nil, fmt.Errorf("failed to create playground log: %v", err)
+	}
+	defer logFile.Close()
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		c.recordError("playground", fmt.Sprintf("Failed to start playground: %v", err))
+		return nil, fmt.Errorf("failed to start playground: %v", err)
+	}
+
+	p := &playgroundProcess{
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+
+	if err := waitReady(ctx, c.readinessProbes(), c.readinessTimeout, p.exited()); err != nil {
+		if errors.Is(err, errProcessExited) {
+			return p, c.playgroundError(fmt.Sprintf("%v (exit: %v)", err, p.err))
+		}
+		return p, c.playgroundError(err.Error())
+	}
+
+	logger.Info("Playground is ready")
+	return p, nil
+}
+
+// playgroundDataDir is where tiup keeps the data and logs of the tagged playground.
+func (c *Checker) playgroundDataDir() string {
+	return filepath.Join(c.tiupHome(), "data", c.playgroundTag)
+}
+
+func (c *Checker) tidbDSN() string {
+	return fmt.Sprintf("root@tcp(%s)/", c.topology.tidbAddr())
+}
+
+// playgroundArgs builds the tiup arguments for starting the configured playground.
+func (c *Checker) playgroundArgs() []string {
+	t := c.topology
+	args := []string{"playground", c.channel, "--tag", c.playgroundTag}
+
+	if t.Mode != "" {
+		args = append(args, "--mode", t.Mode)
+	}
+	args = append(args,
+		"--db", strconv.Itoa(t.DB),
+		"--kv", strconv.Itoa(t.KV),
+		"--pd", strconv.Itoa(t.PD),
+		"--tiflash", strconv.Itoa(t.TiFlash),
+	)
+	if t.TiFlashWrite > 0 {
+		args = append(args, "--tiflash.write", strconv.Itoa(t.TiFlashWrite))
+	}
+	if t.TiFlashCompute > 0 {
+		args = append(args, "--tiflash.compute", strconv.Itoa(t.TiFlashCompute))
+	}
+	if t.hasTiFlash() {
+		args = append(args, "--tiflash.timeout", "240")
+	}
+
+	if t.PortOffset != 0 {
+		args = append(args, "--port-offset", strconv.Itoa(t.PortOffset))
+	}
+	if t.DBPort != 0 {
+		args = append(args, "--db.port", strconv.Itoa(t.DBPort))
+	}
+	if t.PDPort != 0 {
+		args = append(args, "--pd.port", strconv.Itoa(t.PDPort))
+	}
+	if t.WithoutMonitor {
+		args = append(args, "--without-monitor")
+	}
+
+	// sort roles so the command line is stable between runs
+	roles := make([]string, 0, len(t.ConfigFiles))
+	for role := range t.ConfigFiles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		args = append(args, fmt.Sprintf("--%s.config", role), t.ConfigFiles[role])
+	}
+
+	return append(args, t.ExtraArgs...)
+}
@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestPlaygroundArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		pg   config.PlaygroundConfig
+		want string
+	}{
+		{
+			name: "default",
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlash: 1},
+			want: "playground nightly --tag t1 --db 1 --kv 1 --pd 1 --tiflash 1 --tiflash.timeout 240",
+		},
+		{
+			name: "without tiflash",
+			pg:   config.PlaygroundConfig{DB: 2, KV: 3, PD: 1, WithoutMonitor: true},
+			want: "playground nightly --tag t1 --db 2 --kv 3 --pd 1 --tiflash 0 --without-monitor",
+		},
+		{
+			name: "disaggregated tiflash",
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlashWrite: 1, TiFlashCompute: 1, Mode: "tidb-disagg"},
+			want: "playground nightly --tag t1 --mode tidb-disagg --db 1 --kv 1 --pd 1 --tiflash 0 " +
+				"--tiflash.write 1 --tiflash.compute 1 --tiflash.timeout 240",
+		},
+		{
+			name: "ports, configs and extra args",
+			pg: config.PlaygroundConfig{
+				DB: 1, KV: 1, PD: 1, PortOffset: 10000, DBPort: 4400, PDPort: 2400,
+				ConfigFiles: map[string]string{"kv": "/etc/tikv.toml", "db": "/etc/tidb.toml"},
+				ExtraArgs:   []string{"--host", "0.0.0.0"},
+			},
+			want: "playground nightly --tag t1 --db 1 --kv 1 --pd 1 --tiflash 0 " +
+				"--port-offset 10000 --db.port 4400 --pd.port 2400 " +
+				"--db.config /etc/tidb.toml --kv.config /etc/tikv.toml --host 0.0.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{channel: "nightly", playgroundTag: "t1", topology: topology{tt.pg}}
+			if got := strings.Join(c.playgroundArgs(), " "); got != tt.want {
+				t.Errorf("playgroundArgs =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopologyAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		pg         config.PlaygroundConfig
+		tidb, pd   string
+		tidbStatus string
+		ports      []string
+	}{
+		{
+			name:       "default",
+			pg:         config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlash: 1},
+			tidb:       "127.0.0.1:4000",
+			tidbStatus: "127.0.0.1:10080",
+			pd:         "127.0.0.1:2379",
+			ports: []string{
+				"127.0.0.1:4000", "127.0.0.1:10080", "127.0.0.1:2379", "127.0.0.1:2380",
+				"127.0.0.1:20160", "127.0.0.1:20180", "127.0.0.1:3930", "127.0.0.1:20170", "127.0.0.1:9090",
+			},
+		},
+		{
+			name:       "offset",
+			pg:         config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, PortOffset: 100, WithoutMonitor: true},
+			tidb:       "127.0.0.1:4100",
+			tidbStatus: "127.0.0.1:10180",
+			pd:         "127.0.0.1:2479",
+			ports: []string{
+				"127.0.0.1:4100", "127.0.0.1:10180", "127.0.0.1:2479", "127.0.0.1:2480",
+				"127.0.0.1:20260", "127.0.0.1:20280",
+			},
+		},
+		{
+			name:       "explicit ports win over the offset",
+			pg:         config.PlaygroundConfig{DB: 1, PD: 1, PortOffset: 100, DBPort: 5000, PDPort: 3000, WithoutMonitor: true},
+			tidb:       "127.0.0.1:5000",
+			tidbStatus: "127.0.0.1:10180",
+			pd:         "127.0.0.1:3000",
+			ports:      []string{"127.0.0.1:5000", "127.0.0.1:10180", "127.0.0.1:3000", "127.0.0.1:2480"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topo := topology{tt.pg}
+			if got := topo.tidbAddr(); got != tt.tidb {
+				t.Errorf("tidbAddr = %s, want %s", got, tt.tidb)
+			}
+			if got := topo.tidbStatusAddr(); got != tt.tidbStatus {
+				t.Errorf("tidbStatusAddr = %s, want %s", got, tt.tidbStatus)
+			}
+			if got := topo.pdAddr(); got != tt.pd {
+				t.Errorf("pdAddr = %s, want %s", got, tt.pd)
+			}
+			if got := topo.ports(); !reflect.DeepEqual(got, tt.ports) {
+				t.Errorf("ports = %v, want %v", got, tt.ports)
+			}
+		})
+	}
+}
+
+func TestTopologyHas(t *testing.T) {
+	tests := []struct {
+		name string
+		pg   config.PlaygroundConfig
+		want map[string]bool
+	}{
+		{
+			name: "full",
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlash: 1},
+			want: map[string]bool{"tidb": true, "db": true, "tikv": true, "kv": true, "pd": true, "tiflash": true, "ticdc": false},
+		},
+		{
+			name: "compute only tiflash registers no store",
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlashCompute: 1},
+			want: map[string]bool{"tiflash": false},
+		},
+		{
+			name: "write node",
+			pg:   config.PlaygroundConfig{KV: 1, PD: 1, TiFlashWrite: 2},
+			want: map[string]bool{"tidb": false, "tiflash": true},
+		},
+	}
+	for _, tt := range tests {
+		topo := topology{tt.pg}
+		for role, want := range tt.want {
+			if got := topo.has(role); got != want {
+				t.Errorf("%s: has(%s) = %v, want %v", tt.name, role, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const reachabilityTimeout = 10 * time.Second
+
+// preflightStage verifies the host can run a check at all, so that a busy port
+// or a full disk is not reported as a broken nightly.
+type preflightStage struct {
+	c *Checker
+}
+
+func (s *preflightStage) Name() string        { return "preflight" }
+func (s *preflightStage) DependsOn() []string { return nil }
+
+func (s *preflightStage) Run(ctx context.Context) error {
+	return s.c.runPreflight(ctx)
+}
+
+func (s *preflightStage) Cleanup(ctx context.Context) error { return nil }
+
+type preflightCheck struct {
+	name string
+	run  func(ctx context.Context) []string
+	// warnOnly checks only log their problems, the check can still run
+	warnOnly bool
+}
+
+func (c *Checker) runPreflight(ctx context.Context) error {
+	skip := make(map[string]bool)
+	for _, name := range c.preflight.Skip {
+		skip[name] = true
+	}
+
+	checks := []preflightCheck{
+		{name: "tiup", run: c.checkTiUPBinary},
+		{name: "ports", run: c.checkPortsFree},
+		{name: "disk", run: c.checkFreeDisk},
+		{name: "fds", run: c.checkOpenFiles},
+		{name: "mirror", run: c.checkMirrorReachable},
+		// an unreachable report server only delays delivery, the report is
+		// spooled and resent
+		{name: "endpoint", run: c.checkEndpointReachable, warnOnly: true},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if skip[check.name] {
+			logger.Info(fmt.Sprintf("Skipping preflight check %s", check.name))
+			continue
+		}
+		problems := check.run(ctx)
+		if check.warnOnly {
+			for _, p := range problems {
+				logger.Warn(fmt.Sprintf("Preflight check %s: %s", check.name, p))
+			}
+			if len(problems) == 0 {
+				logger.Info(fmt.Sprintf("Preflight check %s passed", check.name))
+			}
+			continue
+		}
+		for _, p := range problems {
+			c.recordCategorizedError("preflight", CategoryEnvironment, p)
+		}
+		if len(problems) > 0 {
+			failed++
+		} else {
+			logger.Info(fmt.Sprintf("Preflight check %s passed", check.name))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d preflight checks failed", failed)
+	}
+	return nil
+}
+
+func (c *Checker) checkTiUPBinary(ctx context.Context) []string {
+	if _, err := exec.LookPath("tiup"); err != nil {
+		return []string{fmt.Sprintf("tiup binary not found: %v", err)}
+	}
+	return nil
+}
+
+// checkPortsFree makes sure no earlier playground or other service holds the
+// ports the configured topology is going to use.
+func (c *Checker) checkPortsFree(ctx context.Context) []string {
+	var problems []string
+	for _, addr := range c.topology.ports() {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("port %s is not available: %v", addr, err))
+			continue
+		}
+		l.Close()
+	}
+	return problems
+}
+
+func (c *Checker) checkFreeDisk(ctx context.Context) []string {
+	// the home of a new target is only created by the download stage
+	dir := c.tiupHome()
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return []string{fmt.Sprintf("failed to get free disk space of %s: %v", dir, err)}
+	}
+	free := uint64(stat.Bavail) * uint64(stat.Bsize)
+	required := uint64(c.preflight.MinFreeDiskGB) << 30
+	if free < required {
+		return []string{fmt.Sprintf("only %.1f GiB free in %s, need %d GiB",
+			float64(free)/(1<<30), dir, c.preflight.MinFreeDiskGB)}
+	}
+	return nil
+}
+
+// checkOpenFiles checks the hard limit, TiKV raises its soft limit up to it
+// and a playground below the minimum fails once TiKV runs out of files.
+func (c *Checker) checkOpenFiles(ctx context.Context) []string {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return []string{fmt.Sprintf("failed to get file descriptor limit: %v", err)}
+	}
+	if limit.Max < uint64(c.preflight.MinOpenFiles) {
+		return []string{fmt.Sprintf("file descriptor limit is %d, the playground needs %d (ulimit -Hn)",
+			limit.Max, c.preflight.MinOpenFiles)}
+	}
+	return nil
+}
+
+func (c *Checker) checkMirrorReachable(ctx context.Context) []string {
+	mirror := c.target.Mirror
+	if mirror == "" {
+		mirror = os.Getenv("TIUP_MIRRORS")
+	}
+	if mirror == "" {
+		mirror = DefaultMirror
+	}
+	if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+		// a local mirror directory
+		if _, err := os.Stat(mirror); err != nil {
+			return []string{fmt.Sprintf("mirror %s is not accessible: %v", mirror, err)}
+		}
+		return nil
+	}
+	return checkReachable(ctx, "mirror", strings.TrimSuffix(mirror, "/")+"/timestamp.json")
+}
+
+func (c *Checker) checkEndpointReachable(ctx context.Context) []string {
+	return checkReachable(ctx, "report endpoint", c.apiEndpoint)
+}
+
+// checkReachable resolves the host of rawURL and sends a GET request. Any
+// HTTP response below 500 counts as reachable.
+func checkReachable(ctx context.Context, what, rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid %s URL %s: %v", what, rawURL, err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reachabilityTimeout)
+	defer cancel()
+
+	if net.ParseIP(u.Hostname()) == nil {
+		if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+			return []string{fmt.Sprintf("cannot resolve %s host %s: %v", what, u.Hostname(), err)}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid %s URL %s: %v", what, rawURL, err)}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return []string{fmt.Sprintf("%s %s is not reachable: %v", what, rawURL, err)}
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return []string{fmt.Sprintf("%s %s returned status %d", what, rawURL, resp.StatusCode)}
+	}
+	return nil
+}
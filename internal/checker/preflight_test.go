@@ -0,0 +1,219 @@
+package checker
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+// statusServer answers every request with status and records the paths
+func statusServer(t *testing.T, status int) (*httptest.Server, *[]string) {
+	t.Helper()
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &paths
+}
+
+// freePort returns a port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestCheckReachable(t *testing.T) {
+	ok, _ := statusServer(t, http.StatusOK)
+	notFound, _ := statusServer(t, http.StatusNotFound)
+	broken, _ := statusServer(t, http.StatusBadGateway)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		problem string
+	}{
+		{name: "ok", url: ok.URL},
+		{name: "client errors count as reachable", url: notFound.URL + "/api/v1/report"},
+		{name: "server error", url: broken.URL, problem: "returned status 502"},
+		{name: "connection refused", url: down.URL, problem: "is not reachable"},
+		{name: "invalid url", url: "http://[::1", problem: "invalid endpoint URL"},
+	}
+	for _, tt := range tests {
+		problems := checkReachable(context.Background(), "endpoint", tt.url)
+		if tt.problem == "" {
+			if len(problems) != 0 {
+				t.Errorf("%s: checkReachable = %v, want no problems", tt.name, problems)
+			}
+			continue
+		}
+		if len(problems) != 1 || !strings.Contains(problems[0], tt.problem) {
+			t.Errorf("%s: checkReachable = %v, want %q", tt.name, problems, tt.problem)
+		}
+	}
+}
+
+func TestCheckMirrorReachable(t *testing.T) {
+	srv, paths := statusServer(t, http.StatusOK)
+	local := t.TempDir()
+
+	tests := []struct {
+		name     string
+		mirror   string
+		env      string
+		wantPath string
+		problem  string
+	}{
+		{name: "target mirror", mirror: srv.URL + "/", env: "http://127.0.0.1:1", wantPath: "/timestamp.json"},
+		{name: "operator mirror", env: srv.URL + "/nightly", wantPath: "/nightly/timestamp.json"},
+		{name: "local mirror", mirror: local},
+		{name: "missing local mirror", mirror: filepath.Join(local, "missing"), problem: "is not accessible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIUP_MIRRORS", tt.env)
+			*paths = nil
+			c := &Checker{target: Target{Mirror: tt.mirror, Channel: "nightly"}}
+			problems := c.checkMirrorReachable(context.Background())
+			if tt.problem != "" {
+				if len(problems) != 1 || !strings.Contains(problems[0], tt.problem) {
+					t.Errorf("checkMirrorReachable = %v, want %q", problems, tt.problem)
+				}
+				return
+			}
+			if len(problems) != 0 {
+				t.Errorf("checkMirrorReachable = %v, want no problems", problems)
+			}
+			if tt.wantPath != "" && (len(*paths) != 1 || (*paths)[0] != tt.wantPath) {
+				t.Errorf("mirror requests = %v, want %s", *paths, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestCheckPortsFree(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name     string
+		port     int
+		problems int
+	}{
+		{name: "free", port: freePort(t)},
+		{name: "busy", port: busy, problems: 1},
+	}
+	for _, tt := range tests {
+		c := &Checker{topology: topology{config.PlaygroundConfig{DB: 1, DBPort: tt.port, PortOffset: 40000, WithoutMonitor: true}}}
+		if problems := c.checkPortsFree(context.Background()); len(problems) != tt.problems {
+			t.Errorf("%s: checkPortsFree = %v, want %d problems", tt.name, problems, tt.problems)
+		}
+	}
+}
+
+func TestCheckFreeDisk(t *testing.T) {
+	// a home not created yet is checked on its closest existing parent
+	home := filepath.Join(t.TempDir(), "homes", "nightly")
+	tests := []struct {
+		minGB    int
+		problems int
+	}{
+		{minGB: 0},
+		{minGB: 1 << 30, problems: 1},
+	}
+	for _, tt := range tests {
+		c := &Checker{home: home, preflight: config.PreflightConfig{MinFreeDiskGB: tt.minGB}}
+		if problems := c.checkFreeDisk(context.Background()); len(problems) != tt.problems {
+			t.Errorf("checkFreeDisk(%d GiB) = %v, want %d problems", tt.minGB, problems, tt.problems)
+		}
+	}
+}
+
+func TestCheckOpenFiles(t *testing.T) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		t.Fatal(err)
+	}
+	if limit.Max > math.MaxInt32 {
+		t.Skipf("hard file descriptor limit %d is too high to exceed", limit.Max)
+	}
+	tests := []struct {
+		min      int
+		problems int
+	}{
+		{min: int(limit.Max)},
+		{min: int(limit.Max) + 1, problems: 1},
+	}
+	for _, tt := range tests {
+		c := &Checker{preflight: config.PreflightConfig{MinOpenFiles: tt.min}}
+		if problems := c.checkOpenFiles(context.Background()); len(problems) != tt.problems {
+			t.Errorf("checkOpenFiles(%d) = %v, want %d problems", tt.min, problems, tt.problems)
+		}
+	}
+}
+
+func TestRunPreflight(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name    string
+		skip    []string
+		wantErr string
+	}{
+		{name: "all skipped", skip: []string{"tiup", "ports", "disk", "fds", "mirror", "endpoint"}},
+		{name: "busy port", skip: []string{"tiup", "disk", "fds", "mirror", "endpoint"}, wantErr: "1 preflight checks failed"},
+		// the report is spooled when the server is down, it must not fail the check
+		{name: "report server down", skip: []string{"tiup", "ports", "disk", "fds", "mirror"}},
+	}
+	for _, tt := range tests {
+		c := &Checker{
+			apiEndpoint: down.URL + "/api/v1/status",
+			topology:    topology{config.PlaygroundConfig{DB: 1, DBPort: busy, PortOffset: 40000, WithoutMonitor: true}},
+			preflight:   config.PreflightConfig{Skip: tt.skip},
+		}
+		err := (&preflightStage{c: c}).Run(context.Background())
+		if tt.wantErr == "" {
+			if err != nil || len(c.errors) != 0 {
+				t.Errorf("%s: Run = %v with errors %+v, want success", tt.name, err, c.errors)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: Run = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		// a busy host is not a broken nightly
+		for _, e := range c.errors {
+			if e.Stage != "preflight" || e.Category != CategoryEnvironment {
+				t.Errorf("%s: recorded %+v, want an environment error of preflight", tt.name, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package checker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	initialProbeBackoff = time.Second
+	maxProbeBackoff     = 15 * time.Second
+	probeTimeout        = 5 * time.Second
+)
+
+var errProcessExited = errors.New("playground process exited")
+
+// probe checks whether one role of the playground is ready to serve.
+type probe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// waitReady runs the probes in order, polling each one with exponential
+// backoff until it passes. It gives up when the timeout expires or as soon as
+// exited is closed.
+func waitReady(ctx context.Context, probes []probe, timeout time.Duration, exited <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, p := range probes {
+		logger.Info(fmt.Sprintf("Waiting for %s to be ready...", p.name))
+		backoff := initialProbeBackoff
+		for attempt := 1; ; attempt++ {
+			probeCtx, probeCancel := context.WithTimeout(ctx, probeTimeout)
+			err := p.check(probeCtx)
+			probeCancel()
+			if err == nil {
+				logger.Info(fmt.Sprintf("%s is ready after %d attempts (%s since start)",
+					p.name, attempt, time.Since(start).Round(time.Second)))
+				break
+			}
+
+			select {
+			case <-exited:
+				return fmt.Errorf("%w while waiting for %s: %v", errProcessExited, p.name, err)
+			case <-ctx.Done():
+				return fmt.Errorf("timeout after %s waiting for %s to be ready: %v", timeout, p.name, err)
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > maxProbeBackoff {
+				backoff = maxProbeBackoff
+			}
+		}
+	}
+	return nil
+}
+
+// readinessProbes returns the probes for every role in the configured topology.
+func (c *Checker) readinessProbes() []probe {
+	t := c.topology
+	var probes []probe
+
+	if t.PD > 0 {
+		probes = append(probes, probe{name: "PD", check: c.checkPDHealth})
+	}
+	if t.KV > 0 {
+		probes = append(probes, probe{name: "TiKV", check: func(ctx context.Context) error {
+			return c.checkStores(ctx, "TiKV", false, t.KV)
+		}})
+	}
+	if t.DB > 0 {
+		probes = append(probes,
+			probe{name: "TiDB status", check: func(ctx context.Context) error {
+				return checkHTTPStatus(ctx, fmt.Sprintf("http://%s/status", t.tidbStatusAddr()))
+			}},
+			probe{name: "TiDB SQL", check: c.checkTiDBConnection},
+		)
+	}
+	if t.hasTiFlash() {
+		probes = append(probes, probe{name: "TiFlash", check: func(ctx context.Context) error {
+			return c.checkStores(ctx, "TiFlash", true, t.tiflashStores())
+		}})
+	}
+
+	return probes
+}
+
+func (c *Checker) checkPDHealth(ctx context.Context) error {
+	var members []struct {
+		Name   string `json:"name"`
+		Health bool   `json:"health"`
+	}
+	url := fmt.Sprintf("http://%s/pd/api/v1/health", c.topology.pdAddr())
+	if err := getJSON(ctx, url, &members); err != nil {
+		return err
+	}
+
+	healthy := 0
+	for _, m := range members {
+		if m.Health {
+			healthy++
+		}
+	}
+	if healthy < c.topology.PD {
+		return fmt.Errorf("%d of %d PD members healthy", healthy, c.topology.PD)
+	}
+	return nil
+}
+
+type pdStore struct {
+	Store struct {
+		ID      uint64 `json:"id"`
+		Address string `json:"address"`
+		Labels  []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"labels"`
+		StatusAddress string `json:"status_address"`
+		StateName     string `json:"state_name"`
+	} `json:"store"`
+}
+
+func (s pdStore) isTiFlash() bool {
+	for _, l := range s.Store.Labels {
+		if l.Key == "engine" && l.Value == "tiflash" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStores waits until PD reports at least want stores of the kind as Up.
+// TiKV stores must additionally answer on their status endpoint.
+func (c *Checker) checkStores(ctx context.Context, kind string, tiflash bool, want int) error {
+	var resp struct {
+		Stores []pdStore `json:"stores"`
+	}
+	url := fmt.Sprintf("http://%s/pd/api/v1/stores", c.topology.pdAddr())
+	if err := getJSON(ctx, url, &resp); err != nil {
+		return err
+	}
+
+	up := 0
+	for _, s := range resp.Stores {
+		if s.isTiFlash() != tiflash || s.Store.StateName != "Up" {
+			continue
+		}
+		if !tiflash {
+			statusURL := fmt.Sprintf("http://%s/status", s.Store.StatusAddress)
+			if err := checkHTTPStatus(ctx, statusURL); err != nil {
+				return fmt.Errorf("%s store %d: %v", kind, s.Store.ID, err)
+			}
+		}
+		up++
+	}
+	if up < want {
+		return fmt.Errorf("%d of %d %s stores up", up, want, kind)
+	}
+	return nil
+}
+
+func (c *Checker) checkTiDBConnection(ctx context.Context) error {
+	db, err := sql.Open("mysql", c.tidbDSN())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.PingContext(ctx)
+}
+
+func checkHTTPStatus(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
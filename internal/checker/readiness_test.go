@@ -0,0 +1,183 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestWaitReady(t *testing.T) {
+	exited := make(chan struct{})
+	close(exited)
+	notReady := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		checks    []error // results of the probe's checks, the last one repeats
+		timeout   time.Duration
+		exited    <-chan struct{}
+		wantErr   string
+		wantErrIs error
+	}{
+		{name: "ready", checks: []error{nil}, timeout: time.Second},
+		{name: "ready on the second attempt", checks: []error{notReady, nil}, timeout: 5 * time.Second},
+		{name: "timeout", checks: []error{notReady}, timeout: 50 * time.Millisecond, wantErr: "timeout after 50ms waiting for fake to be ready: connection refused"},
+		{name: "process exited", checks: []error{notReady}, timeout: time.Minute, exited: exited, wantErrIs: errProcessExited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			p := probe{name: "fake", check: func(ctx context.Context) error {
+				calls++
+				if calls > len(tt.checks) {
+					return tt.checks[len(tt.checks)-1]
+				}
+				return tt.checks[calls-1]
+			}}
+			err := waitReady(context.Background(), []probe{p}, tt.timeout, tt.exited)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("waitReady = %v, want %q", err, tt.wantErr)
+				}
+			case tt.wantErrIs != nil:
+				if !errors.Is(err, tt.wantErrIs) {
+					t.Errorf("waitReady = %v, want %v", err, tt.wantErrIs)
+				}
+			case err != nil:
+				t.Errorf("waitReady: %v", err)
+			}
+		})
+	}
+}
+
+func TestWaitReadyOrder(t *testing.T) {
+	var order []string
+	probes := []probe{
+		{name: "PD", check: func(ctx context.Context) error { order = append(order, "PD"); return nil }},
+		{name: "TiKV", check: func(ctx context.Context) error { order = append(order, "TiKV"); return nil }},
+	}
+	if err := waitReady(context.Background(), probes, time.Second, nil); err != nil {
+		t.Fatalf("waitReady: %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"PD", "TiKV"}) {
+		t.Errorf("probe order = %v", order)
+	}
+}
+
+func TestReadinessProbes(t *testing.T) {
+	tests := []struct {
+		pg   config.PlaygroundConfig
+		want []string
+	}{
+		{
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlash: 1},
+			want: []string{"PD", "TiKV", "TiDB status", "TiDB SQL", "TiFlash"},
+		},
+		{
+			pg:   config.PlaygroundConfig{KV: 1, PD: 1, Mode: "tikv-slim"},
+			want: []string{"PD", "TiKV"},
+		},
+		{
+			pg:   config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlashCompute: 1},
+			want: []string{"PD", "TiKV", "TiDB status", "TiDB SQL"},
+		},
+	}
+	for _, tt := range tests {
+		c := &Checker{topology: topology{tt.pg}}
+		var names []string
+		for _, p := range c.readinessProbes() {
+			names = append(names, p.name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("readinessProbes(%+v) = %v, want %v", tt.pg, names, tt.want)
+		}
+	}
+}
+
+// fakePD serves the PD health and stores APIs and TiKV status endpoints
+func fakePD(t *testing.T, health, stores string) *Checker {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pd/api/v1/health":
+			fmt.Fprint(w, health)
+		case "/pd/api/v1/stores":
+			fmt.Fprint(w, strings.ReplaceAll(stores, "STATUS", srv.Listener.Addr().String()))
+		case "/status":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdPort, _ := strconv.Atoi(port)
+	return &Checker{topology: topology{config.PlaygroundConfig{PD: 2, PDPort: pdPort}}}
+}
+
+func TestCheckPDHealth(t *testing.T) {
+	tests := []struct {
+		health  string
+		wantErr string
+	}{
+		{health: `[{"name":"pd-0","health":true},{"name":"pd-1","health":true}]`},
+		{health: `[{"name":"pd-0","health":true},{"name":"pd-1","health":false}]`, wantErr: "1 of 2 PD members healthy"},
+		{health: `[]`, wantErr: "0 of 2 PD members healthy"},
+		{health: `not json`, wantErr: "invalid character"},
+	}
+	for _, tt := range tests {
+		err := fakePD(t, tt.health, "").checkPDHealth(context.Background())
+		if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("checkPDHealth(%s) = %v, want %q", tt.health, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckStores(t *testing.T) {
+	stores := `{"stores": [
+		{"store": {"id": 1, "state_name": "Up", "status_address": "STATUS"}},
+		{"store": {"id": 2, "state_name": "Offline", "status_address": "STATUS"}},
+		{"store": {"id": 3, "state_name": "Up", "labels": [{"key": "engine", "value": "tiflash"}]}},
+		{"store": {"id": 4, "state_name": "Down", "labels": [{"key": "engine", "value": "tiflash"}]}}
+	]}`
+	tests := []struct {
+		kind    string
+		tiflash bool
+		want    int
+		wantErr string
+	}{
+		{kind: "TiKV", want: 1},
+		{kind: "TiKV", want: 2, wantErr: "1 of 2 TiKV stores up"},
+		{kind: "TiFlash", tiflash: true, want: 1},
+		{kind: "TiFlash", tiflash: true, want: 2, wantErr: "1 of 2 TiFlash stores up"},
+	}
+	for _, tt := range tests {
+		err := fakePD(t, "", stores).checkStores(context.Background(), tt.kind, tt.tiflash, tt.want)
+		if (tt.wantErr == "") != (err == nil) || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("checkStores(%s, %d) = %v, want %q", tt.kind, tt.want, err, tt.wantErr)
+		}
+	}
+
+	// a TiKV store PD reports Up must also answer on its status port
+	down := strings.ReplaceAll(stores, "STATUS", "127.0.0.1:1")
+	c := fakePD(t, "", down)
+	if err := c.checkStores(context.Background(), "TiKV", false, 1); err == nil || !strings.Contains(err.Error(), "TiKV store 1") {
+		t.Errorf("checkStores with an unreachable status port = %v", err)
+	}
+}
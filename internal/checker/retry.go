@@ -0,0 +1,57 @@
+package checker
+
+// beginAttempt marks where the errors of a new stage attempt start.
+func (c *Checker) beginAttempt() {
+	c.attemptStart = len(c.errors)
+}
+
+// attemptCategories returns the categories of the errors recorded by the
+// current attempt.
+func (c *Checker) attemptCategories() []string {
+	var categories []string
+	for _, err := range c.errors[c.attemptStart:] {
+		categories = append(categories, err.Category)
+	}
+	return categories
+}
+
+// retryAttempt sets the errors of a failed attempt aside, the stage is run
+// again and its outcome decides the run.
+func (c *Checker) retryAttempt(attempt int) {
+	for i := c.attemptStart; i < len(c.errors); i++ {
+		c.errors[i].Attempt = attempt
+	}
+}
+
+// failures returns the errors that fail the run, i.e. all but those of
+// retried attempts.
+func (c *Checker) failures() []Error {
+	var failures []Error
+	for _, err := range c.errors {
+		if err.Attempt == 0 {
+			failures = append(failures, err)
+		}
+	}
+	return failures
+}
+
+// retriedErrors returns the errors of retried attempts.
+func (c *Checker) retriedErrors() []Error {
+	var retried []Error
+	for _, err := range c.errors {
+		if err.Attempt > 0 {
+			retried = append(retried, err)
+		}
+	}
+	return retried
+}
+
+// retried reports whether any stage needed more than one attempt.
+func (c *Checker) retried() bool {
+	for _, stage := range c.stages {
+		if stage.Attempts > 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttemptTracking(t *testing.T) {
+	c := &Checker{}
+	c.recordError("preflight", "port 4000 is not available")
+
+	// first download attempt fails on the network and is retried
+	c.beginAttempt()
+	c.recordError("download", "dial tcp: lookup tiup-mirrors.pingcap.com: no such host")
+	c.recordError("download", "read: connection reset by peer")
+	if got := c.attemptCategories(); !reflect.DeepEqual(got, []string{CategoryNetwork, CategoryNetwork}) {
+		t.Errorf("attemptCategories = %v, want two network errors", got)
+	}
+	c.retryAttempt(1)
+
+	// the second attempt fails for good
+	c.beginAttempt()
+	if got := c.attemptCategories(); got != nil {
+		t.Errorf("attemptCategories of a fresh attempt = %v, want none", got)
+	}
+	c.recordError("download", "component tiflash doesn't exist")
+
+	tests := []struct {
+		name string
+		got  []Error
+		want []string
+	}{
+		{name: "failures", got: c.failures(), want: []string{"port 4000 is not available", "component tiflash doesn't exist"}},
+		{name: "retried", got: c.retriedErrors(), want: []string{"dial tcp: lookup tiup-mirrors.pingcap.com: no such host", "read: connection reset by peer"}},
+	}
+	for _, tt := range tests {
+		var messages []string
+		for _, e := range tt.got {
+			messages = append(messages, e.Error)
+		}
+		if !reflect.DeepEqual(messages, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, messages, tt.want)
+		}
+	}
+	for _, e := range c.retriedErrors() {
+		if e.Attempt != 1 {
+			t.Errorf("retried error %q has attempt %d, want 1", e.Error, e.Attempt)
+		}
+	}
+}
+
+func TestRetried(t *testing.T) {
+	tests := []struct {
+		name   string
+		stages []StageResult
+		want   bool
+	}{
+		{name: "no stages"},
+		{name: "single attempts", stages: []StageResult{{Name: "download", Attempts: 1}, {Name: "tiflash"}}},
+		{name: "retried stage", stages: []StageResult{{Name: "download", Attempts: 2}, {Name: "playground", Attempts: 1}}, want: true},
+	}
+	for _, tt := range tests {
+		c := &Checker{stages: tt.stages}
+		if got := c.retried(); got != tt.want {
+			t.Errorf("%s: retried = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package checker
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// BuildInfo identifies the checker binary, set through the Makefile LD_FLAGS.
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildTime string
+}
+
+// newRunID returns a random UUID identifying a run, the server stores a
+// report only once per run ID.
+func newRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// unique enough for deduplication
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newRunInfo collects the metadata sent with every report of this process.
+// Without a configured trigger a run with a CI job URL counts as "ci".
+func newRunInfo(cfg *config.Config, build BuildInfo) RunInfo {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Failed to get hostname: %v", err))
+	}
+
+	trigger := cfg.Trigger
+	if trigger == "" {
+		trigger = "manual"
+		if cfg.CIJobURL != "" {
+			trigger = "ci"
+		}
+	}
+
+	return RunInfo{
+		Hostname:  hostname,
+		Version:   build.Version,
+		Commit:    build.Commit,
+		BuildTime: build.BuildTime,
+		Trigger:   trigger,
+		CIJobURL:  cfg.CIJobURL,
+	}
+}
+
+// runURL is where the server serves the report of the current run.
+func (c *Checker) runURL() string {
+	return c.apiURL("/runs/" + c.runID)
+}
+
+func (c *Checker) runDetail() notify.RunDetail {
+	return notify.RunDetail{
+		ID:       c.runID,
+		URL:      c.runURL(),
+		Hostname: c.runInfo.Hostname,
+		Trigger:  c.runInfo.Trigger,
+		CIJobURL: c.runInfo.CIJobURL,
+	}
+}
@@ -0,0 +1,60 @@
+package checker
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestNewRunID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := newRunID()
+		if !uuid.MatchString(id) {
+			t.Fatalf("newRunID = %q, want a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("newRunID returned %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNewRunInfo(t *testing.T) {
+	build := BuildInfo{Version: "v1.2.0", Commit: "abc1234", BuildTime: "2024-05-01T00:00:00Z"}
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		trigger string
+	}{
+		{name: "manual", cfg: config.Config{}, trigger: "manual"},
+		{name: "ci job", cfg: config.Config{CIJobURL: "https://ci.example.com/job/1"}, trigger: "ci"},
+		{name: "configured", cfg: config.Config{Trigger: "cron", CIJobURL: "https://ci.example.com/job/1"}, trigger: "cron"},
+	}
+	for _, tt := range tests {
+		info := newRunInfo(&tt.cfg, build)
+		if info.Trigger != tt.trigger || info.CIJobURL != tt.cfg.CIJobURL {
+			t.Errorf("%s: trigger = %q, CI job = %q, want %q, %q", tt.name, info.Trigger, info.CIJobURL, tt.trigger, tt.cfg.CIJobURL)
+		}
+		if info.Version != build.Version || info.Commit != build.Commit || info.BuildTime != build.BuildTime || info.Hostname == "" {
+			t.Errorf("%s: run info = %+v, want the build and the hostname", tt.name, info)
+		}
+	}
+}
+
+func TestRunDetail(t *testing.T) {
+	c := &Checker{
+		apiEndpoint: "https://checker.example.com/api/v1/status",
+		runID:       "0b5e6c1a-3f2d-4c4e-9a1b-2c3d4e5f6a7b",
+		runInfo:     RunInfo{Hostname: "runner-1", Trigger: "ci", CIJobURL: "https://ci.example.com/job/1"},
+	}
+	run := c.runDetail()
+	if run.URL != "https://checker.example.com/api/v1/runs/0b5e6c1a-3f2d-4c4e-9a1b-2c3d4e5f6a7b" {
+		t.Errorf("run URL = %s", run.URL)
+	}
+	if run.ID != c.runID || run.Hostname != "runner-1" || run.Trigger != "ci" || run.CIJobURL != "https://ci.example.com/job/1" {
+		t.Errorf("runDetail = %+v", run)
+	}
+}
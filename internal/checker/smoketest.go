@@ -0,0 +1,326 @@
+package checker
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// defaultSmokeTests is the suite used when no smoke test directory is configured.
+//
+//go:embed smoketests
+var defaultSmokeTests embed.FS
+
+const (
+	defaultCaseTimeout = 30 * time.Second
+	cleanupTimeout     = 30 * time.Second
+	eventuallyInterval = 2 * time.Second
+)
+
+// smokeCase is one smoke test, loaded from a YAML file or a plain .sql file.
+// All steps of a case run on the same connection, so session variables and
+// transactions carry over from one step to the next.
+type smokeCase struct {
+	Name    string        `yaml:"name"`
+	File    string        `yaml:"-"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Requires lists roles that must be part of the topology, e.g. "tiflash"
+	Requires []string    `yaml:"requires"`
+	Steps    []smokeStep `yaml:"steps"`
+	// Cleanup steps always run and their failures are only logged
+	Cleanup []smokeStep `yaml:"cleanup"`
+}
+
+type smokeStep struct {
+	SQL string `yaml:"sql"`
+	// ExpectRows, when set, turns the step into a query whose result must
+	// match exactly. Values are compared as strings, NULL as "NULL".
+	ExpectRows *[][]string `yaml:"expect_rows"`
+	// ExpectContains requires some cell of the result to contain the text
+	ExpectContains string `yaml:"expect_contains"`
+	// ExpectError requires the statement to fail with a message containing the text
+	ExpectError string `yaml:"expect_error"`
+	// Eventually retries the step until it passes or the case times out
+	Eventually bool `yaml:"eventually"`
+}
+
+func (s smokeStep) isQuery() bool {
+	return s.ExpectRows != nil || s.ExpectContains != ""
+}
+
+// loadSmokeCases reads the cases from dir, or the embedded default suite if
+// dir is empty. Cases run in file name order.
+func loadSmokeCases(dir string) ([]smokeCase, error) {
+	var fsys fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(defaultSmokeTests, "smoketests")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	} else {
+		fsys = os.DirFS(dir)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var cases []smokeCase
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var tc smokeCase
+		switch path.Ext(name) {
+		case ".yaml", ".yml":
+			if err := yaml.Unmarshal(data, &tc); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+			}
+		case ".sql":
+			for _, stmt := range splitStatements(string(data)) {
+				tc.Steps = append(tc.Steps, smokeStep{SQL: stmt})
+			}
+		default:
+			continue
+		}
+
+		tc.File = name
+		if tc.Name == "" {
+			tc.Name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		if tc.Timeout == 0 {
+			tc.Timeout = defaultCaseTimeout
+		}
+		if len(tc.Steps) == 0 {
+			return nil, fmt.Errorf("smoke test %s has no steps", name)
+		}
+		cases = append(cases, tc)
+	}
+
+	return cases, nil
+}
+
+// splitStatements splits a SQL script on semicolons that end a line.
+// Lines starting with "--" are comments.
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			stmts = append(stmts, stmt)
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+// runSmokeCases executes every case and records its outcome. It returns the
+// number of failed cases.
+func (c *Checker) runSmokeCases(ctx context.Context, db *sql.DB, cases []smokeCase) int {
+	failed := 0
+	for _, tc := range cases {
+		logger.Info(fmt.Sprintf("Running test: %s (%s)", tc.Name, tc.File))
+		result := c.runSmokeCase(ctx, db, tc)
+		c.smokeTests = append(c.smokeTests, result)
+
+		switch result.Status {
+		case StagePassed:
+			logger.Info(fmt.Sprintf("✓ Passed: %s (%s)", tc.Name, result.Duration))
+		case StageSkipped:
+			logger.Info(fmt.Sprintf("- Skipped: %s (%s)", tc.Name, result.Error))
+		default:
+			failed++
+			c.recordError("smoke_test", fmt.Sprintf("%s failed: %s", tc.Name, result.Error))
+		}
+	}
+	return failed
+}
+
+func (c *Checker) runSmokeCase(ctx context.Context, db *sql.DB, tc smokeCase) SmokeTestResult {
+	result := SmokeTestResult{
+		Name: tc.Name,
+		File: tc.File,
+	}
+
+	for _, role := range tc.Requires {
+		if !c.topology.has(role) {
+			result.Status = StageSkipped
+			result.Error = fmt.Sprintf("topology has no %s", role)
+			return result
+		}
+	}
+
+	start := time.Now()
+	err := runSmokeSteps(ctx, db, tc)
+	result.Duration = time.Since(start)
+	result.Status = StagePassed
+	if err != nil {
+		result.Status = StageFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func runSmokeSteps(ctx context.Context, db *sql.DB, tc smokeCase) error {
+	ctx, cancel := context.WithTimeout(ctx, tc.Timeout)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+
+	defer func() {
+		// the case context may already be expired, give cleanup its own
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cleanupCancel()
+		for _, step := range tc.Cleanup {
+			if _, err := conn.ExecContext(cleanupCtx, step.SQL); err != nil {
+				logger.Error(fmt.Sprintf("Cleanup of %s failed: %s: %v", tc.Name, step.SQL, err))
+			}
+		}
+	}()
+
+	for i, step := range tc.Steps {
+		err := runSmokeStep(ctx, conn, step)
+		for step.Eventually && err != nil {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("step %d (%s) did not pass within %s: %v", i+1, step.SQL, tc.Timeout, err)
+			case <-time.After(eventuallyInterval):
+			}
+			err = runSmokeStep(ctx, conn, step)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %v", i+1, step.SQL, err)
+		}
+	}
+	return nil
+}
+
+func runSmokeStep(ctx context.Context, conn *sql.Conn, step smokeStep) error {
+	var rows [][]string
+	var err error
+	if step.isQuery() {
+		rows, err = queryStrings(ctx, conn, step.SQL)
+	} else {
+		_, err = conn.ExecContext(ctx, step.SQL)
+	}
+
+	if step.ExpectError != "" {
+		if err == nil {
+			return fmt.Errorf("expected error containing %q, got success", step.ExpectError)
+		}
+		if !strings.Contains(err.Error(), step.ExpectError) {
+			return fmt.Errorf("expected error containing %q, got: %v", step.ExpectError, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if step.ExpectRows != nil && !equalRows(rows, *step.ExpectRows) {
+		return fmt.Errorf("expected rows %v, got %v", *step.ExpectRows, rows)
+	}
+	if step.ExpectContains != "" && !rowsContain(rows, step.ExpectContains) {
+		return fmt.Errorf("expected result containing %q, got %v", step.ExpectContains, rows)
+	}
+	return nil
+}
+
+// queryStrings runs a query and returns every value as a string.
+func queryStrings(ctx context.Context, conn *sql.Conn, query string) ([][]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := [][]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(cols))
+		for i, v := range values {
+			row[i] = "NULL"
+			if v.Valid {
+				row[i] = v.String
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func equalRows(got, want [][]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if len(got[i]) != len(want[i]) {
+			return false
+		}
+		for j := range got[i] {
+			if got[i][j] != want[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func rowsContain(rows [][]string, text string) bool {
+	for _, row := range rows {
+		for _, v := range row {
+			if strings.Contains(v, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package checker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestLoadDefaultSmokeCases(t *testing.T) {
+	cases, err := loadSmokeCases("")
+	if err != nil {
+		t.Fatalf("loadSmokeCases: %v", err)
+	}
+	var files []string
+	for _, tc := range cases {
+		files = append(files, tc.File)
+		if tc.Name == "" || tc.Timeout == 0 || len(tc.Steps) == 0 {
+			t.Errorf("default case %s is incomplete: %+v", tc.File, tc)
+		}
+	}
+	want := []string{"01-basic.yaml", "02-ddl-add-index.yaml", "03-transaction.yaml", "04-placement-rules.yaml", "05-tiflash-mpp.yaml"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("default cases = %v, want %v", files, want)
+	}
+}
+
+func TestLoadSmokeCases(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"02-query.yaml": `
+name: query
+timeout: 1m
+requires: [tiflash]
+steps:
+  - sql: SELECT 1
+    expect_rows: [["1"]]
+  - sql: SELECT tidb_version()
+    expect_contains: TiDB
+  - sql: SELECT * FROM missing
+    expect_error: doesn't exist
+  - sql: SELECT 1
+    eventually: true
+cleanup:
+  - sql: DROP TABLE IF EXISTS t
+`,
+		"01-script.sql": "-- a plain script\nCREATE TABLE t (id INT);\nINSERT INTO t\nVALUES (1);\n",
+		"03-empty.yml":  "name: empty rows\nsteps:\n  - sql: SELECT 1 WHERE 0\n    expect_rows: []\n",
+		"README.md":     "not a case",
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), []byte(content))
+	}
+	if err := os.Mkdir(filepath.Join(dir, "04-dir.yaml"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases, err := loadSmokeCases(dir)
+	if err != nil {
+		t.Fatalf("loadSmokeCases: %v", err)
+	}
+	one := [][]string{{"1"}}
+	none := [][]string{}
+	want := []smokeCase{
+		{
+			Name:    "01-script",
+			File:    "01-script.sql",
+			Timeout: defaultCaseTimeout,
+			Steps:   []smokeStep{{SQL: "CREATE TABLE t (id INT)"}, {SQL: "INSERT INTO t\nVALUES (1)"}},
+		},
+		{
+			Name:     "query",
+			File:     "02-query.yaml",
+			Timeout:  time.Minute,
+			Requires: []string{"tiflash"},
+			Steps: []smokeStep{
+				{SQL: "SELECT 1", ExpectRows: &one},
+				{SQL: "SELECT tidb_version()", ExpectContains: "TiDB"},
+				{SQL: "SELECT * FROM missing", ExpectError: "doesn't exist"},
+				{SQL: "SELECT 1", Eventually: true},
+			},
+			Cleanup: []smokeStep{{SQL: "DROP TABLE IF EXISTS t"}},
+		},
+		{
+			Name:    "empty rows",
+			File:    "03-empty.yml",
+			Timeout: defaultCaseTimeout,
+			Steps:   []smokeStep{{SQL: "SELECT 1 WHERE 0", ExpectRows: &none}},
+		},
+	}
+	if !reflect.DeepEqual(cases, want) {
+		t.Errorf("loadSmokeCases =\n  %+v\nwant\n  %+v", cases, want)
+	}
+
+	queries := []bool{cases[1].Steps[0].isQuery(), cases[1].Steps[1].isQuery(), cases[1].Steps[2].isQuery(), cases[2].Steps[0].isQuery()}
+	if !reflect.DeepEqual(queries, []bool{true, true, false, true}) {
+		t.Errorf("isQuery = %v", queries)
+	}
+}
+
+func TestLoadSmokeCasesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "invalid yaml", file: "bad.yaml", content: "steps: [", wantErr: "failed to parse bad.yaml"},
+		{name: "unknown timeout", file: "bad.yaml", content: "timeout: soon\nsteps:\n  - sql: SELECT 1\n", wantErr: "failed to parse bad.yaml"},
+		{name: "no steps", file: "empty.yaml", content: "name: nothing\n", wantErr: "smoke test empty.yaml has no steps"},
+		{name: "empty script", file: "empty.sql", content: "-- nothing\n", wantErr: "smoke test empty.sql has no steps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, tt.file), []byte(tt.content))
+			if _, err := loadSmokeCases(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadSmokeCases error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := loadSmokeCases(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("loadSmokeCases of a missing directory succeeded")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "empty", script: ""},
+		{name: "one per line", script: "SELECT 1;\nSELECT 2;\n", want: []string{"SELECT 1", "SELECT 2"}},
+		{name: "multi line", script: "CREATE TABLE t (\n  id INT\n);\n", want: []string{"CREATE TABLE t (\n  id INT\n)"}},
+		{name: "comments and blank lines", script: "-- setup\n\nSELECT 1;\n  -- indented\nSELECT 2;", want: []string{"SELECT 1", "SELECT 2"}},
+		{name: "semicolon inside a line", script: "SELECT 'a;b' AS x;\n", want: []string{"SELECT 'a;b' AS x"}},
+		{name: "no final semicolon", script: "SELECT 1;\nSELECT 2\n", want: []string{"SELECT 1", "SELECT 2"}},
+	}
+	for _, tt := range tests {
+		if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitStatements = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEqualRows(t *testing.T) {
+	tests := []struct {
+		got, want [][]string
+		equal     bool
+	}{
+		{got: [][]string{}, want: [][]string{}, equal: true},
+		{got: [][]string{{"1", "a"}}, want: [][]string{{"1", "a"}}, equal: true},
+		{got: [][]string{{"1", "a"}}, want: [][]string{{"1", "b"}}},
+		{got: [][]string{{"1"}}, want: [][]string{{"1", "a"}}},
+		{got: [][]string{{"1"}, {"2"}}, want: [][]string{{"1"}}},
+		{got: [][]string{{"NULL"}}, want: [][]string{{""}}},
+	}
+	for _, tt := range tests {
+		if got := equalRows(tt.got, tt.want); got != tt.equal {
+			t.Errorf("equalRows(%v, %v) = %v, want %v", tt.got, tt.want, got, tt.equal)
+		}
+	}
+}
+
+func TestRowsContain(t *testing.T) {
+	rows := [][]string{{"t", "CREATE TABLE `t` /*T![placement] PLACEMENT POLICY=`smoke_policy` */"}}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "smoke_policy", want: true},
+		{text: "CREATE TABLE", want: true},
+		{text: "other_policy"},
+	}
+	for _, tt := range tests {
+		if got := rowsContain(rows, tt.text); got != tt.want {
+			t.Errorf("rowsContain(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRunSmokeCaseRequires(t *testing.T) {
+	c := &Checker{topology: topology{config.PlaygroundConfig{DB: 1, KV: 1, PD: 1}}}
+	tc := smokeCase{Name: "mpp", File: "mpp.yaml", Requires: []string{"tikv", "tiflash"}, Steps: []smokeStep{{SQL: "SELECT 1"}}}
+
+	// skipped before touching the database
+	got := c.runSmokeCase(context.Background(), nil, tc)
+	want := SmokeTestResult{Name: "mpp", File: "mpp.yaml", Status: StageSkipped, Error: "topology has no tiflash"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("runSmokeCase = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,315 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	reportTimeout   = 30 * time.Second
+	rejectedSuffix  = ".rejected"
+	spoolFileSuffix = ".json"
+	// artifactsSuffix names the list of artifacts spooled with a report
+	artifactsSuffix = ".artifacts"
+)
+
+var (
+	// errReportRejected is a report the server refused, resending won't help
+	errReportRejected = errors.New("report rejected")
+	// errReportUnauthorized is a report refused for the runner's credentials.
+	// Resending in this run won't help, but the report is fine and stays
+	// spooled until API_KEY or SIGNING_SECRET is fixed.
+	errReportUnauthorized = errors.New("report unauthorized")
+	// errReportSpooled is a report that could not be sent but is kept in the
+	// spool directory for the next start
+	errReportSpooled = errors.New("report spooled")
+)
+
+// deliverReport spools the report and posts it, the spool file is removed
+// once the server stored it. If sending fails but the report was spooled the
+// error wraps errReportSpooled.
+func (c *Checker) deliverReport(ctx context.Context, report *CheckReport) (int64, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal report: %v", err)
+	}
+	logger.Info(fmt.Sprintf("Sending report: %s", string(data)))
+
+	path, spoolErr := c.spoolReport(report, data)
+	if spoolErr != nil {
+		logger.Error(fmt.Sprintf("Failed to spool report, it is lost if sending fails: %v", spoolErr))
+	}
+
+	id, err := c.postReport(ctx, data)
+	if err != nil {
+		if path == "" {
+			return 0, err
+		}
+		if errors.Is(err, errReportRejected) {
+			c.rejectSpooled(path)
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w to %s: %v", errReportSpooled, path, err)
+	}
+
+	if path != "" {
+		removeSpooled(path)
+	}
+	return id, nil
+}
+
+// spoolReport writes an encoded report to the spool directory. The file is
+// renamed into place, so a flush never reads a partial report.
+func (c *Checker) spoolReport(report *CheckReport, data []byte) (string, error) {
+	if c.spoolDir == "" {
+		return "", fmt.Errorf("no spool directory configured")
+	}
+	if err := os.MkdirAll(c.spoolDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(c.spoolDir, ".report-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create spool file: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write spool file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write spool file: %v", err)
+	}
+
+	// named by time so that a flush resends in the original order
+	name := fmt.Sprintf("%s-%s%s", report.Timestamp.Format("20060102-150405"), report.RunID, spoolFileSuffix)
+	path := filepath.Join(c.spoolDir, name)
+	// written before the report, a flush must not find it without its artifacts
+	if err := c.spoolArtifacts(path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to move spool file into place: %v", err)
+	}
+	return path, nil
+}
+
+// spooledArtifact is an artifact as listed next to a spooled report. The
+// files stay in the run directory, which is kept like the spool.
+type spooledArtifact struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	ContentType string `json:"content_type"`
+}
+
+// spoolArtifacts lists the run's artifacts next to the report spooled at path,
+// so a flush uploads them with the resent report.
+func (c *Checker) spoolArtifacts(path string) error {
+	if len(c.artifacts) == 0 {
+		return nil
+	}
+	list := make([]spooledArtifact, 0, len(c.artifacts))
+	for _, a := range c.artifacts {
+		list = append(list, spooledArtifact{Name: a.name, Path: a.path, ContentType: a.contentType})
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spooled artifacts: %v", err)
+	}
+	if err := os.WriteFile(path+artifactsSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write spooled artifacts: %v", err)
+	}
+	return nil
+}
+
+// readSpooledArtifacts returns the artifacts spooled with the report at path.
+func readSpooledArtifacts(path string) ([]artifact, error) {
+	data, err := os.ReadFile(path + artifactsSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []spooledArtifact
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid spooled artifacts: %v", err)
+	}
+	artifacts := make([]artifact, 0, len(list))
+	for _, a := range list {
+		artifacts = append(artifacts, artifact{name: a.Name, path: a.Path, contentType: a.ContentType})
+	}
+	return artifacts, nil
+}
+
+// removeSpooled removes a delivered report and its artifact list.
+func removeSpooled(path string) {
+	if err := os.Remove(path); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to remove spooled report %s: %v", path, err))
+	}
+	if err := os.Remove(path + artifactsSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn(fmt.Sprintf("Failed to remove spooled artifacts %s%s: %v", path, artifactsSuffix, err))
+	}
+}
+
+// rejectSpooled keeps a refused report for inspection, out of later flushes.
+func (c *Checker) rejectSpooled(path string) {
+	if err := os.Rename(path, path+rejectedSuffix); err != nil {
+		logger.Error(fmt.Sprintf("Failed to set aside rejected report %s: %v", path, err))
+		return
+	}
+	if err := os.Rename(path+artifactsSuffix, path+rejectedSuffix+artifactsSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn(fmt.Sprintf("Failed to set aside artifacts of rejected report %s: %v", path, err))
+	}
+	logger.Error(fmt.Sprintf("Server rejected report, kept at %s%s", path, rejectedSuffix))
+}
+
+// postReport posts an encoded report and returns the id the server stored it
+// under. Network errors, rate limits and server errors are retried with backoff.
+func (c *Checker) postReport(ctx context.Context, data []byte) (int64, error) {
+	backoff := c.reportBackoff
+	var lastErr error
+	for attempt := 1; attempt <= c.reportAttempts || attempt == 1; attempt++ {
+		if attempt > 1 {
+			logger.Info(fmt.Sprintf("Retrying report in %s (attempt %d): %v", backoff, attempt, lastErr))
+			select {
+			case <-ctx.Done():
+				return 0, fmt.Errorf("%v (gave up: %v)", lastErr, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		id, err := c.postReportOnce(ctx, data)
+		if err == nil {
+			return id, nil
+		}
+		if errors.Is(err, errReportRejected) || errors.Is(err, errReportUnauthorized) {
+			return 0, err
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+func (c *Checker) postReportOnce(ctx context.Context, data []byte) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiEndpoint, bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+	// signed per attempt, a spooled report is sent long after it was written
+	if c.signingSecret != "" {
+		now := time.Now()
+		signature, err := auth.Sign(c.signingSecret, now, data)
+		if err != nil {
+			// the server could not decode it either
+			return 0, fmt.Errorf("%w: failed to sign report: %v", errReportRejected, err)
+		}
+		req.Header.Set(auth.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(auth.SignatureHeader, signature)
+	}
+
+	client := &http.Client{Timeout: reportTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send report: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return 0, fmt.Errorf("%w: status code %d, check API_KEY and SIGNING_SECRET", errReportUnauthorized, resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return 0, fmt.Errorf("rate limited: status code %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// the payload itself was refused
+		return 0, fmt.Errorf("%w: status code %d", errReportRejected, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return result.ID, nil
+}
+
+// FlushSpool resends the reports earlier runs could not deliver, oldest
+// first, with the artifacts spooled along, and returns how many were sent
+// and how many are still spooled.
+func (c *Checker) FlushSpool(ctx context.Context) (sent, remaining int) {
+	entries, err := os.ReadDir(c.spoolDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to read spool directory %s: %v", c.spoolDir, err))
+		return 0, 0
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), spoolFileSuffix) && !strings.HasPrefix(e.Name(), ".") {
+			paths = append(paths, filepath.Join(c.spoolDir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return 0, 0
+	}
+	logger.Info(fmt.Sprintf("Flushing %d spooled reports from %s", len(paths), c.spoolDir))
+
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to read spooled report %s: %v", path, err))
+			remaining++
+			continue
+		}
+
+		id, err := c.postReport(ctx, data)
+		if errors.Is(err, errReportRejected) {
+			c.rejectSpooled(path)
+			continue
+		}
+		if err != nil {
+			// the server is still unreachable, keep the rest for later
+			logger.Error(fmt.Sprintf("Failed to resend spooled report %s: %v", path, err))
+			remaining += len(paths) - i
+			break
+		}
+
+		logger.Info(fmt.Sprintf("Resent spooled report %s, id: %d", filepath.Base(path), id))
+		// like after a direct send, artifacts that fail to upload are not retried
+		artifacts, err := readSpooledArtifacts(path)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to read artifacts of spooled report %s: %v", path, err))
+		}
+		c.uploadArtifacts(ctx, id, artifacts)
+		removeSpooled(path)
+		sent++
+	}
+	return sent, remaining
+}
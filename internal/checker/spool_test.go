@@ -0,0 +1,321 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+)
+
+// fakeReports is a report server answering reports with statuses in turn,
+// the last one repeats, and 200 if none are given. Artifact uploads are
+// always accepted.
+type fakeReports struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	reports  []CheckReport
+	uploads  []string
+}
+
+func (f *fakeReports) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if strings.Contains(r.URL.Path, "/artifacts/") {
+		f.uploads = append(f.uploads, r.URL.Path)
+		return
+	}
+
+	var report CheckReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		f.t.Errorf("server got an invalid report: %v", err)
+	}
+	f.reports = append(f.reports, report)
+
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status = f.statuses[min(len(f.reports), len(f.statuses))-1]
+	}
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		json.NewEncoder(w).Encode(map[string]int{"id": len(f.reports)})
+	}
+}
+
+func (f *fakeReports) list() []CheckReport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CheckReport(nil), f.reports...)
+}
+
+func (f *fakeReports) uploaded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.uploads...)
+}
+
+func reportServer(t *testing.T, statuses ...int) (*httptest.Server, *fakeReports) {
+	t.Helper()
+	f := &fakeReports{t: t, statuses: statuses}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return srv, f
+}
+
+// spoolFiles lists the spool directory, without temporary files
+func spoolFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDeliverReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		posts    int
+		wantErr  error
+		// files left in the spool directory, by suffix
+		files []string
+	}{
+		{name: "stored", statuses: []int{200}, posts: 1},
+		{name: "server error retried", statuses: []int{503, 502, 200}, posts: 3},
+		{name: "rate limit retried", statuses: []int{429, 200}, posts: 2},
+		{name: "server down", statuses: []int{503}, posts: 3, wantErr: errReportSpooled, files: []string{".json", ".json.artifacts"}},
+		// bad credentials are not retried in this run, but the report is kept
+		{name: "unauthorized", statuses: []int{401}, posts: 1, wantErr: errReportSpooled, files: []string{".json", ".json.artifacts"}},
+		{name: "forbidden", statuses: []int{403}, posts: 1, wantErr: errReportSpooled, files: []string{".json", ".json.artifacts"}},
+		{name: "rejected", statuses: []int{400}, posts: 1, wantErr: errReportRejected, files: []string{".json.rejected", ".json.rejected.artifacts"}},
+		{name: "unprocessable", statuses: []int{422}, posts: 1, wantErr: errReportRejected, files: []string{".json.rejected", ".json.rejected.artifacts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, reports := reportServer(t, tt.statuses...)
+			c := &Checker{
+				apiEndpoint:    srv.URL + "/api/v1/status",
+				spoolDir:       t.TempDir(),
+				reportAttempts: 3,
+				reportBackoff:  time.Millisecond,
+				artifacts:      []artifact{{name: playgroundLogName, path: "/runs/run/playground.log", contentType: "text/plain"}},
+			}
+			report := &CheckReport{RunID: newRunID(), Timestamp: time.Now().UTC(), Status: StatusSuccess}
+
+			id, err := c.deliverReport(context.Background(), report)
+			if tt.wantErr == nil {
+				if err != nil || id != int64(tt.posts) {
+					t.Errorf("deliverReport = %d, %v, want id %d", id, err, tt.posts)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Errorf("deliverReport = %d, %v, want %v", id, err, tt.wantErr)
+			}
+			if got := len(reports.list()); got != tt.posts {
+				t.Errorf("server got %d posts, want %d", got, tt.posts)
+			}
+
+			files := spoolFiles(t, c.spoolDir)
+			if len(files) != len(tt.files) {
+				t.Fatalf("spool = %v, want files ending in %v", files, tt.files)
+			}
+			for i, suffix := range tt.files {
+				if !strings.HasSuffix(files[i], suffix) || !strings.Contains(files[i], report.RunID) {
+					t.Errorf("spool = %v, want files ending in %v", files, tt.files)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverReportWithoutSpool(t *testing.T) {
+	srv, _ := reportServer(t, http.StatusServiceUnavailable)
+	c := &Checker{apiEndpoint: srv.URL, reportAttempts: 1}
+	_, err := c.deliverReport(context.Background(), &CheckReport{RunID: newRunID()})
+	if err == nil || errors.Is(err, errReportSpooled) {
+		t.Errorf("deliverReport = %v, want an error that is not spooled", err)
+	}
+}
+
+func TestSpoolArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	c := &Checker{spoolDir: dir}
+	want := []artifact{
+		{name: playgroundLogName, path: "/runs/run/playground.log", contentType: "text/plain; charset=utf-8"},
+		{name: "components.tar.gz", path: "/runs/run/components.tar.gz", contentType: "application/gzip"},
+	}
+
+	tests := []struct {
+		name      string
+		artifacts []artifact
+	}{
+		{name: "none"},
+		{name: "playground log and component logs", artifacts: want},
+	}
+	for _, tt := range tests {
+		c.artifacts = tt.artifacts
+		report := &CheckReport{RunID: newRunID(), Timestamp: time.Now().UTC()}
+		path, err := c.spoolReport(report, []byte("{}"))
+		if err != nil {
+			t.Fatalf("%s: spoolReport: %v", tt.name, err)
+		}
+		got, err := readSpooledArtifacts(path)
+		if err != nil || !reflect.DeepEqual(got, tt.artifacts) {
+			t.Errorf("%s: readSpooledArtifacts = %+v, %v, want %+v", tt.name, got, err, tt.artifacts)
+		}
+		removeSpooled(path)
+	}
+	if files := spoolFiles(t, dir); len(files) != 0 {
+		t.Errorf("spool after removing = %v, want empty", files)
+	}
+}
+
+func TestFlushSpool(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		sent      int
+		remaining int
+		posts     int
+		// spooled reports left by their run ID, rejected ones end in .rejected
+		left    []string
+		uploads []string
+	}{
+		{
+			name: "all sent", statuses: []int{200}, sent: 3, posts: 3,
+			uploads: []string{"/api/v1/results/1/artifacts/playground.log"},
+		},
+		{
+			name: "rejected report is set aside", statuses: []int{200, 400, 200}, sent: 2, posts: 3,
+			left:    []string{"run-2.json.rejected"},
+			uploads: []string{"/api/v1/results/1/artifacts/playground.log"},
+		},
+		{
+			name: "server goes down", statuses: []int{200, 503}, sent: 1, remaining: 2, posts: 2,
+			left:    []string{"run-2.json", "run-3.json"},
+			uploads: []string{"/api/v1/results/1/artifacts/playground.log"},
+		},
+		{
+			name: "unauthorized keeps everything", statuses: []int{401}, remaining: 3, posts: 1,
+			left: []string{"run-1.json", "run-1.json.artifacts", "run-2.json", "run-3.json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, reports := reportServer(t, tt.statuses...)
+			dir := t.TempDir()
+			log := filepath.Join(dir, "playground.log")
+			writeFile(t, log, []byte("started"))
+
+			c := &Checker{apiEndpoint: srv.URL + "/api/v1/status", spoolDir: filepath.Join(dir, "spool"), reportAttempts: 1}
+			start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+			for i, id := range []string{"run-1", "run-2", "run-3"} {
+				// only the oldest report has an artifact
+				c.artifacts = nil
+				if i == 0 {
+					c.artifacts = []artifact{{name: playgroundLogName, path: log, contentType: "text/plain"}}
+				}
+				report := &CheckReport{RunID: id, Timestamp: start.Add(time.Duration(i) * time.Hour)}
+				data, _ := json.Marshal(report)
+				if _, err := c.spoolReport(report, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sent, remaining := c.FlushSpool(context.Background())
+			if sent != tt.sent || remaining != tt.remaining {
+				t.Errorf("FlushSpool = %d sent, %d remaining, want %d, %d", sent, remaining, tt.sent, tt.remaining)
+			}
+
+			var order []string
+			for _, r := range reports.list() {
+				order = append(order, r.RunID)
+			}
+			if want := []string{"run-1", "run-2", "run-3"}[:tt.posts]; !reflect.DeepEqual(order, want) {
+				t.Errorf("resent %v, want oldest first %v", order, want)
+			}
+			if got := reports.uploaded(); !reflect.DeepEqual(got, tt.uploads) {
+				t.Errorf("uploads = %v, want %v", got, tt.uploads)
+			}
+
+			var left []string
+			for _, name := range spoolFiles(t, c.spoolDir) {
+				// drop the timestamp
+				left = append(left, name[strings.Index(name, "run-"):])
+			}
+			if !reflect.DeepEqual(left, tt.left) {
+				t.Errorf("spool = %v, want %v", left, tt.left)
+			}
+		})
+	}
+
+	c := &Checker{spoolDir: filepath.Join(t.TempDir(), "missing")}
+	if sent, remaining := c.FlushSpool(context.Background()); sent != 0 || remaining != 0 {
+		t.Errorf("FlushSpool of a missing directory = %d, %d", sent, remaining)
+	}
+}
+
+func TestPostReportSigned(t *testing.T) {
+	const secret = "tcs_secret"
+	tests := []struct {
+		name    string
+		secret  string
+		data    string
+		signed  bool
+		wantErr error
+	}{
+		{name: "unsigned", data: `{"status":"success"}`},
+		{name: "signed", secret: secret, data: `{"status": "success", "platform": "linux-amd64"}`, signed: true},
+		{name: "not json", secret: secret, data: `{"status":`, wantErr: errReportRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				posts++
+				body, _ := io.ReadAll(r.Body)
+				timestamp, signature := r.Header.Get(auth.TimestampHeader), r.Header.Get(auth.SignatureHeader)
+				if !tt.signed {
+					if timestamp != "" || signature != "" {
+						t.Errorf("unsigned report has signature headers %q, %q", timestamp, signature)
+					}
+				} else if _, err := auth.Verify(secret, timestamp, signature, body, time.Now(), time.Minute); err != nil {
+					t.Errorf("server can't verify the report: %v", err)
+				}
+				json.NewEncoder(w).Encode(map[string]int{"id": 1})
+			}))
+			defer srv.Close()
+
+			c := &Checker{apiEndpoint: srv.URL, signingSecret: tt.secret}
+			_, err := c.postReportOnce(context.Background(), []byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || posts != 0 {
+					t.Errorf("postReportOnce = %v after %d posts, want %v before posting", err, posts, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || posts != 1 {
+				t.Errorf("postReportOnce = %v after %d posts, want one post", err, posts)
+			}
+		})
+	}
+}
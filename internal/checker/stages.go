@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// downloadStage updates tiup and installs the nightly components.
+type downloadStage struct {
+	c *Checker
+}
+
+func (s *downloadStage) Name() string        { return "download" }
+func (s *downloadStage) DependsOn() []string { return []string{"preflight"} }
+
+func (s *downloadStage) Run(ctx context.Context) error {
+	return s.c.checkTiUPDownload(ctx)
+}
+
+func (s *downloadStage) Cleanup(ctx context.Context) error { return nil }
+
+// playgroundStage starts a tiup playground and keeps it running until cleanup.
+type playgroundStage struct {
+	c       *Checker
+	process *playgroundProcess
+}
+
+func (s *playgroundStage) Name() string        { return "playground" }
+func (s *playgroundStage) DependsOn() []string { return []string{"download"} }
+
+func (s *playgroundStage) Run(ctx context.Context) error {
+	process, err := s.c.startPlayground(ctx)
+	if process != nil {
+		s.process = process
+	}
+	return err
+}
+
+func (s *playgroundStage) Cleanup(ctx context.Context) error {
+	if s.process == nil {
+		return nil
+	}
+	playground := s.process
+	s.process = nil
+
+	var problems []string
+	if err := playground.stop(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if leftovers := s.c.playgroundLeftovers(); len(leftovers) > 0 {
+		problems = append(problems, "leftovers after teardown: "+strings.Join(leftovers, ", "))
+	}
+
+	// a tagged playground keeps its data directory after exit
+	if err := os.RemoveAll(s.c.playgroundDataDir()); err != nil {
+		problems = append(problems, fmt.Sprintf("failed to remove playground data: %v", err))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// smokeTestStage runs SQL smoke tests against the playground.
+type smokeTestStage struct {
+	c *Checker
+}
+
+func (s *smokeTestStage) Name() string        { return "smoke_test" }
+func (s *smokeTestStage) DependsOn() []string { return []string{"playground"} }
+
+func (s *smokeTestStage) Run(ctx context.Context) error {
+	return s.c.runSmokeTest(ctx)
+}
+
+func (s *smokeTestStage) Cleanup(ctx context.Context) error { return nil }
@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// DefaultMirror is the official tiup mirror, used by targets without one.
+const DefaultMirror = "https://tiup-mirrors.pingcap.com"
+
+// Target is a tiup mirror and the channel installed from it. Every target
+// runs the whole pipeline and gets its own report.
+type Target struct {
+	// Mirror is the mirror URL, the tiup default if empty
+	Mirror string
+	// Channel is "nightly", a release such as "v8.5.0", or any tiup version tag
+	Channel string
+}
+
+// ParseTarget parses "channel" or "channel@mirror".
+func ParseTarget(s string) (Target, error) {
+	channel, mirror, _ := strings.Cut(strings.TrimSpace(s), "@")
+	if channel == "" {
+		return Target{}, fmt.Errorf("invalid target %q: missing channel", s)
+	}
+	return Target{Mirror: mirror, Channel: channel}, nil
+}
+
+func (t Target) String() string {
+	if t.Mirror == "" {
+		return t.Channel
+	}
+	return t.Channel + "@" + t.Mirror
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// dirName is a file system friendly name of the target.
+func (t Target) dirName() string {
+	mirror := strings.TrimPrefix(strings.TrimPrefix(t.Mirror, "https://"), "http://")
+	name := t.Channel
+	if mirror != "" {
+		name += "-" + mirror
+	}
+	return strings.Trim(unsafePathChars.ReplaceAllString(name, "_"), "_")
+}
+
+// startTarget resets the per-run state so the pipeline can run for target.
+// With more than one target, or a custom mirror, the target gets its own
+// TIUP_HOME so mirrors and installed components cannot leak between them.
+func (c *Checker) startTarget(target Target) {
+	c.target = target
+	c.channel = target.Channel
+	c.runID = newRunID()
+	c.errors = make([]Error, 0)
+	c.versions = Versions{Components: make(map[string]ComponentVersion)}
+	c.stages = nil
+	c.installs = nil
+	c.artifacts = nil
+	c.smokeTests = nil
+	c.consistency = nil
+
+	c.home = ""
+	c.runDir = filepath.Join(c.runsDir, c.runName)
+	c.playgroundTag = "checker-" + c.runName
+	if len(c.targets) > 1 || target.Mirror != "" {
+		// an isolated run gets a temporary home from prepareHome instead
+		if !c.isolate {
+			c.home = filepath.Join(c.targetsHome, target.dirName())
+		}
+		c.runDir = filepath.Join(c.runDir, target.dirName())
+		c.playgroundTag += "-" + target.dirName()
+	}
+}
+
+// command builds a command that runs against the target's TIUP_HOME and mirror.
+func (c *Checker) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if c.home != "" {
+		cmd.Env = append(os.Environ(), "TIUP_HOME="+c.home)
+		if c.target.Mirror != "" {
+			// TIUP_MIRRORS inherited from the operator would win over the target's mirror
+			cmd.Env = append(cmd.Env, "TIUP_MIRRORS="+c.target.Mirror)
+		}
+	}
+	return cmd
+}
+
+// setupMirror points the target's TIUP_HOME at its mirror. tiup fetches the
+// mirror's root.json here, so a fresh home works without copying keys.
+func (c *Checker) setupMirror(ctx context.Context) error {
+	if c.home == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.home, 0755); err != nil {
+		return fmt.Errorf("failed to create TIUP_HOME: %v", err)
+	}
+
+	mirror := c.target.Mirror
+	if mirror == "" {
+		mirror = DefaultMirror
+	}
+	logger.Info(fmt.Sprintf("Using TIUP_HOME %s with mirror %s", c.home, mirror))
+	return c.runCommand(ctx, "tiup", "mirror", "set", mirror)
+}
+
+// tiupHome returns the directory tiup keeps its components and data in.
+func (c *Checker) tiupHome() string {
+	if c.home != "" {
+		return c.home
+	}
+	if home := os.Getenv("TIUP_HOME"); home != "" {
+		return home
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".tiup"
+	}
+	return filepath.Join(home, ".tiup")
+}
@@ -0,0 +1,252 @@
+package checker
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Target
+		wantErr bool
+	}{
+		{in: "nightly", want: Target{Channel: "nightly"}},
+		{in: " v8.5.0 ", want: Target{Channel: "v8.5.0"}},
+		{in: "nightly@https://tiup.example.com", want: Target{Mirror: "https://tiup.example.com", Channel: "nightly"}},
+		{in: "v8.5.0@/srv/mirror", want: Target{Mirror: "/srv/mirror", Channel: "v8.5.0"}},
+		{in: "@https://tiup.example.com", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTarget(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTarget(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTargetNames(t *testing.T) {
+	tests := []struct {
+		target  Target
+		str     string
+		dirName string
+	}{
+		{Target{Channel: "nightly"}, "nightly", "nightly"},
+		{Target{Mirror: "https://tiup.example.com", Channel: "v8.5.0"}, "v8.5.0@https://tiup.example.com", "v8.5.0-tiup.example.com"},
+		{Target{Mirror: "http://10.0.0.1:8080/mirror/", Channel: "nightly"}, "nightly@http://10.0.0.1:8080/mirror/", "nightly-10.0.0.1_8080_mirror"},
+		{Target{Mirror: "/srv/mirror", Channel: "nightly"}, "nightly@/srv/mirror", "nightly-_srv_mirror"},
+	}
+	for _, tt := range tests {
+		if got := tt.target.String(); got != tt.str {
+			t.Errorf("%+v.String() = %q, want %q", tt.target, got, tt.str)
+		}
+		if got := tt.target.dirName(); got != tt.dirName {
+			t.Errorf("%+v.dirName() = %q, want %q", tt.target, got, tt.dirName)
+		}
+	}
+}
+
+func TestStartTarget(t *testing.T) {
+	nightly := Target{Channel: "nightly"}
+	mirrored := Target{Mirror: "https://tiup.example.com", Channel: "v8.5.0"}
+	tests := []struct {
+		name    string
+		targets []Target
+		target  Target
+		isolate bool
+		home    string
+		runDir  string
+		tag     string
+	}{
+		{
+			name:    "single default target shares the home",
+			targets: []Target{nightly},
+			target:  nightly,
+			runDir:  "runs/run",
+			tag:     "checker-run",
+		},
+		{
+			name:    "single target with a mirror",
+			targets: []Target{mirrored},
+			target:  mirrored,
+			home:    "homes/v8.5.0-tiup.example.com",
+			runDir:  "runs/run/v8.5.0-tiup.example.com",
+			tag:     "checker-run-v8.5.0-tiup.example.com",
+		},
+		{
+			name:    "several targets",
+			targets: []Target{nightly, mirrored},
+			target:  nightly,
+			home:    "homes/nightly",
+			runDir:  "runs/run/nightly",
+			tag:     "checker-run-nightly",
+		},
+		{
+			name:    "isolated target gets its home later",
+			targets: []Target{nightly, mirrored},
+			target:  mirrored,
+			isolate: true,
+			runDir:  "runs/run/v8.5.0-tiup.example.com",
+			tag:     "checker-run-v8.5.0-tiup.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{targets: tt.targets, isolate: tt.isolate, runsDir: "runs", runName: "run", targetsHome: "homes"}
+			c.errors = []Error{{Stage: "previous", Error: "left over"}}
+			c.artifacts = []artifact{{name: "previous"}}
+			previousRun := newRunID()
+			c.runID = previousRun
+
+			c.startTarget(tt.target)
+			if c.target != tt.target || c.channel != tt.target.Channel {
+				t.Errorf("target = %+v, channel = %q, want %+v", c.target, c.channel, tt.target)
+			}
+			if c.home != tt.home || c.runDir != tt.runDir || c.playgroundTag != tt.tag {
+				t.Errorf("home, run dir, tag = %q, %q, %q, want %q, %q, %q", c.home, c.runDir, c.playgroundTag, tt.home, tt.runDir, tt.tag)
+			}
+			if c.runID == "" || c.runID == previousRun {
+				t.Errorf("run id %q was not renewed", c.runID)
+			}
+			if len(c.errors) != 0 || len(c.artifacts) != 0 {
+				t.Errorf("state of the previous target was kept: %+v %+v", c.errors, c.artifacts)
+			}
+		})
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	t.Setenv("TIUP_MIRRORS", "https://operator.example.com")
+	tests := []struct {
+		name   string
+		home   string
+		target Target
+		want   []string
+	}{
+		{name: "shared home", target: Target{Channel: "nightly"}},
+		{name: "own home", home: "/homes/nightly", target: Target{Channel: "nightly"}, want: []string{"TIUP_HOME=/homes/nightly"}},
+		{
+			name:   "own mirror",
+			home:   "/homes/mirrored",
+			target: Target{Mirror: "https://tiup.example.com", Channel: "nightly"},
+			want:   []string{"TIUP_HOME=/homes/mirrored", "TIUP_MIRRORS=https://tiup.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		c := &Checker{home: tt.home, target: tt.target}
+		cmd := c.command(context.Background(), "tiup", "--version")
+		if tt.want == nil {
+			if cmd.Env != nil {
+				t.Errorf("%s: command env = %v, want the inherited one", tt.name, cmd.Env)
+			}
+			continue
+		}
+		// the last assignment wins, so the target's must come after the inherited ones
+		got := cmd.Env[len(cmd.Env)-len(tt.want):]
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: command env ends with %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewCheckerTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		want    []Target
+	}{
+		{name: "channel only", want: []Target{{Channel: "nightly"}}},
+		{
+			name:    "configured targets",
+			targets: []string{"nightly", "v8.5.0@https://tiup.example.com"},
+			want:    []Target{{Channel: "nightly"}, {Mirror: "https://tiup.example.com", Channel: "v8.5.0"}},
+		},
+		{name: "invalid targets are ignored", targets: []string{"@https://tiup.example.com", "v8.5.0"}, want: []Target{{Channel: "v8.5.0"}}},
+		{name: "no valid target", targets: []string{"@https://tiup.example.com"}, want: []Target{{Channel: "nightly"}}},
+	}
+	for _, tt := range tests {
+		c := NewChecker(&config.Config{TiUPChannel: "nightly", TiUPTargets: tt.targets}, component.Default(), BuildInfo{})
+		if len(c.targets) != len(tt.want) {
+			t.Errorf("%s: targets = %v, want %v", tt.name, c.targets, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if c.targets[i] != tt.want[i] {
+				t.Errorf("%s: targets = %v, want %v", tt.name, c.targets, tt.want)
+				break
+			}
+		}
+		// Run starts each target, a run ID from here would not be the reported one
+		if c.runID != "" {
+			t.Errorf("%s: NewChecker started a run %s", tt.name, c.runID)
+		}
+	}
+}
+
+// blockingStage runs until its context is done
+type blockingStage struct {
+	name string
+}
+
+func (s *blockingStage) Name() string                      { return s.name }
+func (s *blockingStage) DependsOn() []string               { return nil }
+func (s *blockingStage) Cleanup(ctx context.Context) error { return nil }
+
+func (s *blockingStage) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunTimesOutEachTarget(t *testing.T) {
+	fakeTiUP(t, `echo v1.16.0`)
+	t.Setenv("FEISHU_SUCCESS_WEBHOOK", "")
+	t.Setenv("FEISHU_FAILURE_WEBHOOK", "")
+	srv, reports := reportServer(t)
+	dir := t.TempDir()
+	cfg := &config.Config{
+		TiUPTargets:   []string{"nightly", "v8.5.0@https://tiup.example.com"},
+		TargetTimeout: 50 * time.Millisecond,
+		APIEndpoint:   srv.URL,
+		RunDir:        filepath.Join(dir, "runs"),
+		TargetsHome:   filepath.Join(dir, "homes"),
+		SpoolDir:      filepath.Join(dir, "spool"),
+	}
+	c := NewChecker(cfg, component.Default(), BuildInfo{})
+	c.pipeline = NewPipeline(&blockingStage{name: "hang"})
+
+	start := time.Now()
+	if c.Run(context.Background()) {
+		t.Errorf("Run passed with hanging stages")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %s, the target timeout did not stop it", elapsed)
+	}
+
+	got := reports.list()
+	if len(got) != 2 {
+		t.Fatalf("server got %d reports, want one per target", len(got))
+	}
+	for i, want := range c.targets {
+		r := got[i]
+		if r.Channel != want.Channel || r.Mirror != want.Mirror {
+			t.Errorf("report %d is for %s@%s, want %s", i, r.Channel, r.Mirror, want)
+		}
+		if r.Status != StatusFailed || len(r.Stages) != 1 || !strings.Contains(r.Stages[0].Error, context.DeadlineExceeded.Error()) {
+			t.Errorf("report %d = %s with stages %+v, want the hanging stage timed out", i, r.Status, r.Stages)
+		}
+	}
+	if got[0].RunID == got[1].RunID {
+		t.Errorf("targets share run id %s", got[0].RunID)
+	}
+}
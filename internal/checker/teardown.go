@@ -0,0 +1,133 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	stopGracePeriod   = 10 * time.Second
+	killWaitPeriod    = 5 * time.Second
+	groupPollInterval = 200 * time.Millisecond
+	portCheckTimeout  = 500 * time.Millisecond
+)
+
+// groupAlive reports whether any process of the process group still exists.
+func groupAlive(pgid int) bool {
+	err := syscall.Kill(-pgid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// waitGroupExit waits until the process group is gone and tiup itself was reaped.
+func (p *playgroundProcess) waitGroupExit(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(groupPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.exited():
+			if !groupAlive(p.cmd.Process.Pid) {
+				return true
+			}
+		default:
+		}
+		select {
+		case <-deadline:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop terminates the whole process group of the playground: SIGTERM first,
+// SIGKILL for whatever is still running after the grace period. The servers
+// are signalled directly, so they go away even if tiup already exited and
+// orphaned them.
+func (p *playgroundProcess) stop() error {
+	pgid := p.cmd.Process.Pid
+	if !groupAlive(pgid) {
+		<-p.exited()
+		logger.Info(fmt.Sprintf("Playground process group already exited: %v", p.err))
+		return nil
+	}
+
+	logger.Info(fmt.Sprintf("Cleaning up: Gracefully stopping playground process group %d", pgid))
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		logger.Error(fmt.Sprintf("Failed to send SIGTERM: %v", err))
+	}
+	if p.waitGroupExit(stopGracePeriod) {
+		logger.Info("Process group exited gracefully")
+		return nil
+	}
+
+	logger.Info("Process group didn't exit in time, forcing kill")
+	if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to kill playground process group: %v", err)
+	}
+	if !p.waitGroupExit(killWaitPeriod) {
+		return fmt.Errorf("playground process group %d still alive after SIGKILL", pgid)
+	}
+	return nil
+}
+
+// playgroundLeftovers looks for processes and listening ports the playground
+// should have released. Leftover processes are killed so that they do not
+// break the next run, but are still reported.
+func (c *Checker) playgroundLeftovers() []string {
+	var leftovers []string
+
+	// servers started by playground carry its data directory on the command line
+	pids, err := findProcesses(c.playgroundDataDir())
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Cannot look for leftover playground processes: %v", err))
+	}
+	for _, pid := range pids {
+		leftovers = append(leftovers, fmt.Sprintf("process %d still running, killed", pid))
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+			logger.Error(fmt.Sprintf("Failed to kill leftover process %d: %v", pid, err))
+		}
+	}
+
+	for _, addr := range c.topology.ports() {
+		conn, err := net.DialTimeout("tcp", addr, portCheckTimeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		leftovers = append(leftovers, fmt.Sprintf("port %s still listening", addr))
+	}
+	return leftovers
+}
+
+// findProcesses returns the pids whose command line contains pattern,
+// except the checker and its parent.
+func findProcesses(pattern string) ([]int, error) {
+	out, err := exec.Command("pgrep", "-f", pattern).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// no process matched
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil || pid == os.Getpid() || pid == os.Getppid() {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+// startGroup runs script as the leader of a new process group, like the
+// playground is started
+func startGroup(t *testing.T, script string) *playgroundProcess {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	p := &playgroundProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+	t.Cleanup(func() { syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) })
+	return p
+}
+
+func TestPlaygroundProcessStop(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "leader and child", script: "sleep 60 & sleep 60"},
+		// the servers outlive tiup when it exits first
+		{name: "orphaned child", script: "sleep 60 & exit 0"},
+		{name: "already exited", script: "exit 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := startGroup(t, tt.script)
+			pgid := p.cmd.Process.Pid
+			if err := p.stop(); err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+			if groupAlive(pgid) {
+				t.Errorf("process group %d is still alive", pgid)
+			}
+			select {
+			case <-p.exited():
+			default:
+				t.Errorf("the playground process was not reaped")
+			}
+		})
+	}
+}
+
+func TestFindProcesses(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "data", "checker-run")
+	p := startGroup(t, "sleep 60; : "+marker)
+
+	pids, err := findProcesses(marker)
+	if err != nil {
+		t.Fatalf("findProcesses: %v", err)
+	}
+	if len(pids) != 1 || pids[0] != p.cmd.Process.Pid {
+		t.Errorf("findProcesses = %v, want [%d]", pids, p.cmd.Process.Pid)
+	}
+
+	pids, err = findProcesses(marker + "-other")
+	if err != nil || len(pids) != 0 {
+		t.Errorf("findProcesses of an unused pattern = %v, %v, want none", pids, err)
+	}
+}
+
+func TestPlaygroundLeftovers(t *testing.T) {
+	c := &Checker{home: t.TempDir(), playgroundTag: "checker-run"}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	free := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		port    int
+		process bool
+		want    []string
+	}{
+		{name: "clean", port: free},
+		{name: "port in use", port: busy, want: []string{"port 127.0.0.1:" + strconv.Itoa(busy) + " still listening"}},
+		{name: "process left", port: free, process: true, want: []string{"still running, killed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.topology = topology{config.PlaygroundConfig{DB: 1, DBPort: tt.port, PortOffset: 40000, WithoutMonitor: true}}
+			var p *playgroundProcess
+			if tt.process {
+				p = startGroup(t, "sleep 60; : "+c.playgroundDataDir())
+			}
+
+			got := c.playgroundLeftovers()
+			if len(got) != len(tt.want) {
+				t.Fatalf("playgroundLeftovers = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if !strings.Contains(got[i], tt.want[i]) {
+					t.Errorf("playgroundLeftovers = %v, want %v", got, tt.want)
+				}
+			}
+			if p != nil {
+				select {
+				case <-p.exited():
+				case <-time.After(5 * time.Second):
+					t.Errorf("leftover process was not killed")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package checker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	tiflashCheckTable  = "smoke.tiflash_check"
+	tiflashCheckRows   = 1000
+	tiflashCheckGroups = 7
+	tiflashPollPeriod  = 2 * time.Second
+)
+
+const tiflashAggregation = "SELECT grp, COUNT(*), SUM(v), MIN(v), MAX(v) FROM " +
+	tiflashCheckTable + " GROUP BY grp ORDER BY grp"
+
+// tiflashStage verifies that TiFlash can sync a replica and answer MPP
+// queries with the same results as TiKV.
+type tiflashStage struct {
+	c *Checker
+}
+
+func (s *tiflashStage) Name() string        { return "tiflash" }
+func (s *tiflashStage) DependsOn() []string { return []string{"playground"} }
+
+func (s *tiflashStage) Run(ctx context.Context) error {
+	if !s.c.topology.hasTiFlash() {
+		return fmt.Errorf("%w: topology has no TiFlash", ErrSkipStage)
+	}
+	if err := s.c.checkTiFlashReplica(ctx); err != nil {
+		s.c.recordError("tiflash", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s *tiflashStage) Cleanup(ctx context.Context) error { return nil }
+
+func (c *Checker) checkTiFlashReplica(ctx context.Context) error {
+	logger.Info("==================== Starting TiFlash check ====================")
+
+	db, err := sql.Open("mysql", c.tidbDSN())
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %v", err)
+	}
+	defer conn.Close()
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+tiflashCheckTable); err != nil {
+			logger.Error(fmt.Sprintf("Failed to drop %s: %v", tiflashCheckTable, err))
+		}
+	}()
+
+	if err := prepareTiFlashTable(ctx, conn); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := waitTiFlashReplica(ctx, conn, c.tiflashReplicaTimeout); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("TiFlash replica available after %s", time.Since(start).Round(time.Second)))
+
+	if _, err := conn.ExecContext(ctx, "SET SESSION tidb_isolation_read_engines = 'tikv'"); err != nil {
+		return fmt.Errorf("failed to read from TiKV: %v", err)
+	}
+	expected, err := queryStrings(ctx, conn, tiflashAggregation)
+	if err != nil {
+		return fmt.Errorf("aggregation on TiKV failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"SET SESSION tidb_isolation_read_engines = 'tiflash'",
+		"SET SESSION tidb_enforce_mpp = ON",
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s failed: %v", stmt, err)
+		}
+	}
+
+	plan, err := queryStrings(ctx, conn, "EXPLAIN "+tiflashAggregation)
+	if err != nil {
+		return fmt.Errorf("failed to explain MPP aggregation: %v", err)
+	}
+	if !rowsContain(plan, "mpp[tiflash]") {
+		return fmt.Errorf("aggregation is not executed as MPP on TiFlash, plan: %v", plan)
+	}
+
+	actual, err := queryStrings(ctx, conn, tiflashAggregation)
+	if err != nil {
+		return fmt.Errorf("MPP aggregation on TiFlash failed: %v", err)
+	}
+	if !equalRows(actual, expected) {
+		return fmt.Errorf("TiFlash result %v differs from TiKV result %v", actual, expected)
+	}
+
+	logger.Info("==================== TiFlash check completed successfully ====================")
+	return nil
+}
+
+func prepareTiFlashTable(ctx context.Context, conn *sql.Conn) error {
+	values := make([]string, 0, tiflashCheckRows)
+	for i := 1; i <= tiflashCheckRows; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d, %d)", i, i%tiflashCheckGroups, i*31%997))
+	}
+
+	for _, stmt := range []string{
+		"CREATE DATABASE IF NOT EXISTS smoke",
+		"DROP TABLE IF EXISTS " + tiflashCheckTable,
+		"CREATE TABLE " + tiflashCheckTable + " (id INT PRIMARY KEY, grp INT, v INT)",
+		"INSERT INTO " + tiflashCheckTable + " VALUES " + strings.Join(values, ", "),
+		"ALTER TABLE " + tiflashCheckTable + " SET TIFLASH REPLICA 1",
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to prepare TiFlash table: %v", err)
+		}
+	}
+	return nil
+}
+
+// waitTiFlashReplica polls information_schema.tiflash_replica until the replica is available.
+func waitTiFlashReplica(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	schema, table, _ := strings.Cut(tiflashCheckTable, ".")
+	query := "SELECT AVAILABLE, PROGRESS FROM information_schema.tiflash_replica WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	for {
+		var available int
+		var progress float64
+		err := conn.QueryRowContext(ctx, query, schema, table).Scan(&available, &progress)
+		if err == nil && available == 1 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("TiFlash replica not available within %s: %v", timeout, err)
+			}
+			return fmt.Errorf("TiFlash replica not available within %s, progress %.2f", timeout, progress)
+		case <-time.After(tiflashPollPeriod):
+		}
+	}
+}
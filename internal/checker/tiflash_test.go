@@ -0,0 +1,56 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/config"
+)
+
+func TestTiFlashStage(t *testing.T) {
+	tests := []struct {
+		name     string
+		pg       config.PlaygroundConfig
+		wantSkip bool
+	}{
+		{name: "no tiflash", pg: config.PlaygroundConfig{DB: 1, KV: 1, PD: 1}, wantSkip: true},
+		{name: "compute nodes only", pg: config.PlaygroundConfig{DB: 1, KV: 1, PD: 1, TiFlashCompute: 1}, wantSkip: true},
+	}
+	for _, tt := range tests {
+		c := &Checker{topology: topology{tt.pg}}
+		err := (&tiflashStage{c: c}).Run(context.Background())
+		if got := errors.Is(err, ErrSkipStage); got != tt.wantSkip {
+			t.Errorf("%s: Run = %v, want skip %v", tt.name, err, tt.wantSkip)
+		}
+		if len(c.errors) != 0 {
+			t.Errorf("%s: a skipped stage recorded errors: %+v", tt.name, c.errors)
+		}
+	}
+}
+
+func TestDefaultPipeline(t *testing.T) {
+	cfg := &config.Config{
+		TiUPChannel: "nightly",
+		SkipStages:  []string{"smoke_test"},
+		Retries:     map[string]config.RetryConfig{"download": {MaxAttempts: 3}},
+	}
+	c := NewChecker(cfg, component.Default(), BuildInfo{})
+
+	names, err := c.Pipeline().Names()
+	if err != nil {
+		t.Fatalf("Names: %v", err)
+	}
+	want := []string{"preflight", "download", "playground", "smoke_test", "tiflash"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("pipeline = %v, want %v", names, want)
+	}
+	if !c.Pipeline().skipped["smoke_test"] || c.Pipeline().skipped["tiflash"] {
+		t.Errorf("skipped stages = %v, want smoke_test only", c.Pipeline().skipped)
+	}
+	if got := c.Pipeline().retries["download"].MaxAttempts; got != 3 {
+		t.Errorf("download attempts = %d, want 3", got)
+	}
+}
@@ -26,17 +26,154 @@ type Versions struct {
 type Error struct {
     Stage     string    `json:"stage"`
     Error     string    `json:"error"`
+    // Category tells build regressions apart from problems such as the
+    // environment, empty if unclassified
+    Category  string    `json:"category,omitempty"`
+    // Attempt is set on errors of a stage attempt that was retried, they do
+    // not fail the run
+    Attempt   int       `json:"attempt,omitempty"`
     Timestamp time.Time `json:"timestamp"`
 }
 
+type StageResult struct {
+    Name     string        `json:"name"`
+    Status   string        `json:"status"`
+    Start    time.Time     `json:"start"`
+    End      time.Time     `json:"end"`
+    Duration time.Duration `json:"duration"`
+    Attempts int           `json:"attempts"`
+    Error    string        `json:"error,omitempty"`
+    // History lists every attempt of a retried stage
+    History  []StageAttempt `json:"history,omitempty"`
+}
+
+// StageAttempt is one run of a stage.
+type StageAttempt struct {
+    Attempt  int           `json:"attempt"`
+    Status   string        `json:"status"`
+    Start    time.Time     `json:"start"`
+    End      time.Time     `json:"end"`
+    Duration time.Duration `json:"duration"`
+    Error    string        `json:"error,omitempty"`
+}
+
+type ComponentInstall struct {
+    Component string        `json:"component"`
+    Package   string        `json:"package"`
+    Success   bool          `json:"success"`
+    Duration  time.Duration `json:"duration"`
+    Bytes     int64         `json:"bytes"`
+    Error     string        `json:"error,omitempty"`
+}
+
+// InstanceVersion is one row of the version consistency matrix.
+type InstanceVersion struct {
+    Component    string `json:"component"`
+    Instance     string `json:"instance"`
+    Version      string `json:"version"`
+    BaseVersion  string `json:"base_version"`
+    GitHash      string `json:"git_hash"`
+    ExpectedHash string `json:"expected_hash,omitempty"`
+    VersionMatch bool   `json:"version_match"`
+    HashStatus   string `json:"hash_status"`
+}
+
+type SmokeTestResult struct {
+    Name     string        `json:"name"`
+    File     string        `json:"file"`
+    Status   string        `json:"status"`
+    Duration time.Duration `json:"duration"`
+    Error    string        `json:"error,omitempty"`
+}
+
+type ArtifactInfo struct {
+    Name        string    `json:"name"`
+    ContentType string    `json:"content_type"`
+    Size        int64     `json:"size"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// HomeUsage describes the TIUP_HOME a run used.
+type HomeUsage struct {
+    // Isolated is set when the run used a temporary TIUP_HOME
+    Isolated        bool   `json:"isolated"`
+    SeedBytes       int64  `json:"seed_bytes"`
+    DownloadedBytes int64  `json:"downloaded_bytes"`
+    DiskUsage       int64  `json:"disk_usage"`
+    // KeptPath is where a failed run's temporary TIUP_HOME was kept
+    KeptPath        string `json:"kept_path,omitempty"`
+}
+
+// RunInfo describes where a run came from.
+type RunInfo struct {
+    Hostname  string `json:"hostname,omitempty"`
+    // Version, Commit and BuildTime identify the checker build
+    Version   string `json:"version,omitempty"`
+    Commit    string `json:"commit,omitempty"`
+    BuildTime string `json:"build_time,omitempty"`
+    // Trigger is what started the run, e.g. "manual", "cron" or "ci"
+    Trigger   string `json:"trigger,omitempty"`
+    CIJobURL  string `json:"ci_job_url,omitempty"`
+}
+
+// ReportSignature is a runner's signature of a stored report, kept so that
+// the report can be verified again after it was stored.
+type ReportSignature struct {
+    KeyID     int64     `json:"key_id"`
+    Value     string    `json:"value"`
+    Timestamp time.Time `json:"timestamp"`
+    // Payload is the canonical report that was signed, see auth.Canonicalize
+    Payload   []byte    `json:"-"`
+}
+
 type CheckReport struct {
-    Timestamp time.Time    `json:"timestamp"`
-    Status    string       `json:"status"`
-    Platform  string       `json:"platform"`
-    OS        string       `json:"os"`
-    Arch      string       `json:"arch"`
-    Errors    []Error      `json:"errors,omitempty"`
-    Version   Versions     `json:"version"`
+    ID          int64              `json:"id,omitempty"`
+    Timestamp   time.Time          `json:"timestamp"`
+    Status      string             `json:"status"`
+    Platform    string             `json:"platform"`
+    OS          string             `json:"os"`
+    Arch        string             `json:"arch"`
+    // RunID is generated by the checker, the server stores one report per run
+    RunID       string             `json:"run_id,omitempty"`
+    Run         *RunInfo           `json:"run,omitempty"`
+    // SignedBy is the runner whose key signed the report and Signature the
+    // signature itself, both set by the server
+    SignedBy    string             `json:"signed_by,omitempty"`
+    Signature   *ReportSignature   `json:"signature,omitempty"`
+    // Mirror and Channel identify the target the report belongs to
+    Mirror      string             `json:"mirror,omitempty"`
+    Channel     string             `json:"channel,omitempty"`
+    Errors      []Error            `json:"errors,omitempty"`
+    Stages      []StageResult      `json:"stages,omitempty"`
+    Installs    []ComponentInstall `json:"installs,omitempty"`
+    SmokeTests  []SmokeTestResult  `json:"smoke_tests,omitempty"`
+    Consistency []InstanceVersion  `json:"consistency,omitempty"`
+    Home        *HomeUsage         `json:"home,omitempty"`
+    Version     Versions           `json:"version"`
+    Artifacts   []ArtifactInfo     `json:"artifacts,omitempty"`
+}
+
+// ErrorRecord is a stored error together with the check result it came from.
+type ErrorRecord struct {
+    CheckResultID int64     `json:"check_result_id"`
+    Platform      string    `json:"platform"`
+    Mirror        string    `json:"mirror,omitempty"`
+    Channel       string    `json:"channel,omitempty"`
+    Status        string    `json:"status"`
+    Stage         string    `json:"stage"`
+    Category      string    `json:"category"`
+    Error         string    `json:"error"`
+    // Attempt is set if the error belongs to a retried stage attempt
+    Attempt       int       `json:"attempt,omitempty"`
+    Timestamp     time.Time `json:"timestamp"`
+}
+
+// CategoryCount is the number of errors of a category.
+type CategoryCount struct {
+    Category string `json:"category"`
+    Count    int    `json:"count"`
+    // Runs is the number of check results with at least one such error
+    Runs     int    `json:"runs"`
 }
 
 type BranchCommitInfo struct {
@@ -47,3 +184,16 @@ type BranchCommitInfo struct {
     UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// BranchHeadChange records a branch head moving from PreviousHash to GitHash.
+type BranchHeadChange struct {
+    ID           int64     `json:"id"`
+    Component    string    `json:"component"`
+    Branch       string    `json:"branch"`
+    GitHash      string    `json:"git_hash"`
+    PreviousHash string    `json:"previous_hash,omitempty"`
+    // CommitsAdded is how many commits the branch gained, nil if unknown
+    CommitsAdded *int      `json:"commits_added,omitempty"`
+    CommitTime   time.Time `json:"commit_time"`
+    ObservedAt   time.Time `json:"observed_at"`
+}
+
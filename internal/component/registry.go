@@ -0,0 +1,152 @@
+// Package component describes the TiDB components the checker knows about:
+// where their source lives, how tiup packages them and how they show up in
+// information_schema.cluster_info.
+package component
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Component struct {
+	// Name identifies the component in reports and branch commits, e.g. "tidb"
+	Name string `yaml:"name"`
+	// Repo is the GitHub repository, "owner/name"
+	Repo          string `yaml:"repo"`
+	DefaultBranch string `yaml:"default_branch"`
+	// Branches are tracked in addition to the default branch
+	Branches []string `yaml:"branches"`
+	// Package is the tiup component name
+	Package string `yaml:"package"`
+	// Role is the TYPE in information_schema.cluster_info, empty for tools
+	// that are not cluster members
+	Role string `yaml:"role"`
+	// VersionArgs make the binary print its build info including the git hash
+	VersionArgs []string `yaml:"version_args"`
+}
+
+var defaults = []Component{
+	{Name: "tidb", Repo: "pingcap/tidb", DefaultBranch: "master", Package: "tidb", Role: "tidb", VersionArgs: []string{"-V"}},
+	{Name: "tikv", Repo: "tikv/tikv", DefaultBranch: "master", Package: "tikv", Role: "tikv", VersionArgs: []string{"-V"}},
+	{Name: "pd", Repo: "tikv/pd", DefaultBranch: "master", Package: "pd", Role: "pd", VersionArgs: []string{"-V"}},
+	{Name: "tiflash", Repo: "pingcap/tiflash", DefaultBranch: "master", Package: "tiflash", Role: "tiflash", VersionArgs: []string{"version"}},
+}
+
+// Registry is an ordered, read-only set of components.
+type Registry struct {
+	components []Component
+	byName     map[string]int
+	byRole     map[string]int
+}
+
+// Default returns the registry of the core cluster components.
+func Default() *Registry {
+	r, _ := newRegistry(defaults)
+	return r
+}
+
+// Load returns the default registry merged with the components in the YAML
+// file at path: entries with a known name replace the default, others are
+// added. An empty path returns the defaults.
+func Load(path string) (*Registry, error) {
+	if path == "" {
+		return Default(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read component registry: %w", err)
+	}
+	var file struct {
+		Components []Component `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse component registry %s: %w", path, err)
+	}
+
+	merged := append([]Component(nil), defaults...)
+	for _, comp := range file.Components {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == comp.Name {
+				merged[i] = comp
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, comp)
+		}
+	}
+	return newRegistry(merged)
+}
+
+func newRegistry(components []Component) (*Registry, error) {
+	r := &Registry{
+		byName: make(map[string]int),
+		byRole: make(map[string]int),
+	}
+	for _, comp := range components {
+		if comp.Name == "" {
+			return nil, fmt.Errorf("component without name")
+		}
+		if !strings.Contains(comp.Repo, "/") {
+			return nil, fmt.Errorf("component %s: repo must be owner/name, got %q", comp.Name, comp.Repo)
+		}
+		if comp.DefaultBranch == "" {
+			comp.DefaultBranch = "master"
+		}
+		if comp.Package == "" {
+			comp.Package = comp.Name
+		}
+		if _, ok := r.byName[comp.Name]; ok {
+			return nil, fmt.Errorf("duplicate component %s", comp.Name)
+		}
+
+		r.byName[comp.Name] = len(r.components)
+		if comp.Role != "" {
+			r.byRole[comp.Role] = len(r.components)
+		}
+		r.components = append(r.components, comp)
+	}
+	return r, nil
+}
+
+// All returns the components in registration order.
+func (r *Registry) All() []Component {
+	return append([]Component(nil), r.components...)
+}
+
+// Names returns the component names in registration order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.components))
+	for _, comp := range r.components {
+		names = append(names, comp.Name)
+	}
+	return names
+}
+
+func (r *Registry) Get(name string) (Component, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return Component{}, false
+	}
+	return r.components[i], true
+}
+
+// ByRole finds the component reported under the cluster_info TYPE role.
+func (r *Registry) ByRole(role string) (Component, bool) {
+	i, ok := r.byRole[role]
+	if !ok {
+		return Component{}, false
+	}
+	return r.components[i], true
+}
+
+func (r *Registry) IsValid(name string) bool {
+	_, ok := r.byName[name]
+	return ok
+}
@@ -0,0 +1,154 @@
+package component
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeRegistry(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "components.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		names   []string
+		check   map[string]Component
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			names: []string{"tidb", "tikv", "pd", "tiflash"},
+		},
+		{
+			name: "override keeps position",
+			yaml: `
+components:
+  - name: tikv
+    repo: tikv/tikv
+    default_branch: main
+    branches: [release-8.5]
+    role: tikv
+`,
+			names: []string{"tidb", "tikv", "pd", "tiflash"},
+			check: map[string]Component{
+				"tikv": {Name: "tikv", Repo: "tikv/tikv", DefaultBranch: "main", Branches: []string{"release-8.5"}, Package: "tikv", Role: "tikv"},
+			},
+		},
+		{
+			name: "new component gets defaults",
+			yaml: `
+components:
+  - name: cdc
+    repo: pingcap/tiflow
+`,
+			names: []string{"tidb", "tikv", "pd", "tiflash", "cdc"},
+			check: map[string]Component{
+				"cdc": {Name: "cdc", Repo: "pingcap/tiflow", DefaultBranch: "master", Package: "cdc"},
+			},
+		},
+		{
+			name:    "missing name",
+			yaml:    "components:\n  - repo: pingcap/tiflow\n",
+			wantErr: "component without name",
+		},
+		{
+			name:    "bad repo",
+			yaml:    "components:\n  - name: cdc\n    repo: tiflow\n",
+			wantErr: "repo must be owner/name",
+		},
+		{
+			name:  "later entry wins",
+			yaml:  "components:\n  - name: cdc\n    repo: pingcap/tiflow\n  - name: cdc\n    repo: pingcap/ticdc\n",
+			names: []string{"tidb", "tikv", "pd", "tiflash", "cdc"},
+			check: map[string]Component{
+				"cdc": {Name: "cdc", Repo: "pingcap/ticdc", DefaultBranch: "master", Package: "cdc"},
+			},
+		},
+		{
+			name:    "invalid yaml",
+			yaml:    "components: [",
+			wantErr: "failed to parse component registry",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.yaml != "" {
+				path = writeRegistry(t, tt.yaml)
+			}
+			r, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := r.Names(); !reflect.DeepEqual(got, tt.names) {
+				t.Errorf("Names = %v, want %v", got, tt.names)
+			}
+			for name, want := range tt.check {
+				got, ok := r.Get(name)
+				if !ok || !reflect.DeepEqual(got, want) {
+					t.Errorf("Get(%s) = %+v, want %+v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Load of a missing file succeeded")
+	}
+}
+
+func TestLookups(t *testing.T) {
+	r, err := Load(writeRegistry(t, `
+components:
+  - name: cdc
+    repo: pingcap/tiflow
+    package: cdc
+  - name: tidb
+    repo: pingcap/tidb
+    role: tidb-server
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		role string
+		want string
+		ok   bool
+	}{
+		{role: "tidb-server", want: "tidb", ok: true},
+		{role: "tikv", want: "tikv", ok: true},
+		{role: "tidb"},
+		{role: ""},
+	}
+	for _, tt := range tests {
+		comp, ok := r.ByRole(tt.role)
+		if ok != tt.ok || comp.Name != tt.want {
+			t.Errorf("ByRole(%q) = %s, %v, want %s, %v", tt.role, comp.Name, ok, tt.want, tt.ok)
+		}
+	}
+
+	for name, want := range map[string]bool{"cdc": true, "tidb": true, "tiproxy": false} {
+		if got := r.IsValid(name); got != want {
+			t.Errorf("IsValid(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
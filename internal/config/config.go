@@ -1,8 +1,11 @@
 package config
 
 import (
+    "fmt"
     "os"
     "strconv"
+    "strings"
+    "time"
 )
 
 type Config struct {
@@ -15,12 +18,123 @@ type Config struct {
     }
     Server struct {
         Port int
+        // AuthDisabled lets writes through without an API key
+        AuthDisabled bool
+        // SignatureWindow is how far a report's signature timestamp may be
+        // from the server's clock
+        SignatureWindow time.Duration
+        // SecretKey encrypts the runners' signing secrets in the database,
+        // 32 hex encoded bytes; required with auth enabled
+        SecretKey string
     }
     APIEndpoint string
+    // APIKey authenticates the checker's writes to the server
+    APIKey string
+    // SigningSecret signs the reports, issued together with the API key
+    SigningSecret string
+    // SpoolDir keeps reports until the server stored them, resent on the next start
+    SpoolDir string
+    // ReportAttempts and ReportBackoff control resending a report in the same run
+    ReportAttempts int
+    ReportBackoff  time.Duration
+    // Trigger tells what started the checker, e.g. "cron" or "ci"
+    Trigger string
+    // CIJobURL links a report to the CI job that ran the checker
+    CIJobURL string
     LogPath string
+    RunDir  string
     GitHubToken string
+    GitHubAPIURL string
+    // GitHubCachePath persists commit times looked up on GitHub, memory only if empty
+    GitHubCachePath string
+    // ComponentsConfig is a YAML file adding to or overriding the built-in
+    // component registry (repository, default branch, tiup package, role)
+    ComponentsConfig string
     CronSchedule string
     EnableCron   bool
+    // TrackedBranches are followed besides each component's default branch,
+    // "branch" for every component or "component:branch" for one of them
+    TrackedBranches []string
+    // ReleaseBranchPattern selects the release branches discovered on GitHub;
+    // the newest ReleaseBranchLimit of them are tracked, none if 0
+    ReleaseBranchPattern string
+    ReleaseBranchLimit   int
+    // FreshnessThreshold is how far a nightly may lag behind master before it is stale
+    FreshnessThreshold time.Duration
+    SkipStages   []string
+
+    // TiUPChannel is the version installed for components listed without
+    // one: "nightly", a release such as "v8.5.0", or any tiup version tag
+    TiUPChannel    string
+    TiUPComponents []string
+    // TiUPTargets are "channel" or "channel@mirror" entries checked one after
+    // another, only TiUPChannel on the default mirror if empty
+    TiUPTargets []string
+    // TargetTimeout bounds the check of each target, so later targets get
+    // the same budget as the first
+    TargetTimeout time.Duration
+    // TargetsHome holds the isolated TIUP_HOME of each target
+    TargetsHome string
+    // Isolate runs every target in a temporary TIUP_HOME removed after the run
+    Isolate bool
+    // WarmCacheDir is a TIUP_HOME copied into the temporary one, if set
+    WarmCacheDir string
+    // KeepHomeOnFailure keeps the temporary TIUP_HOME of failed runs for debugging
+    KeepHomeOnFailure bool
+
+    Playground PlaygroundConfig
+    // ReadinessTimeout bounds how long the checker waits for the playground to serve
+    ReadinessTimeout time.Duration
+    // SmokeTestDir holds .yaml/.sql smoke test cases, the built-in suite is used if empty
+    SmokeTestDir string
+    // TiFlashReplicaTimeout bounds the wait for a TiFlash replica to become available
+    TiFlashReplicaTimeout time.Duration
+
+    Preflight PreflightConfig
+    // Retries are the retry policies of stages by name
+    Retries map[string]RetryConfig
+}
+
+// RetryConfig is the retry policy of a stage.
+type RetryConfig struct {
+    MaxAttempts int
+    // Backoff is the wait before the first retry, doubled for every further one
+    Backoff time.Duration
+    // Categories are the retryable error categories, any if empty
+    Categories []string
+}
+
+// PreflightConfig sets the thresholds of the environment checks run before a check.
+type PreflightConfig struct {
+    // Skip lists checks not to run: tiup, ports, disk, fds, mirror, endpoint
+    Skip []string
+    // MinFreeDiskGB is the free space required in the tiup home
+    MinFreeDiskGB int
+    // MinOpenFiles is the hard file descriptor limit a playground needs. Far
+    // below TiKV's production requirement, a single node playground with
+    // small data opens few files.
+    MinOpenFiles int
+}
+
+// PlaygroundConfig describes the topology started by `tiup playground`.
+type PlaygroundConfig struct {
+    DB             int
+    KV             int
+    PD             int
+    TiFlash        int
+    TiFlashWrite   int
+    TiFlashCompute int
+    // Mode is passed as --mode, e.g. "tidb" or "tiflash-disagg"
+    Mode           string
+    WithoutMonitor bool
+    // PortOffset shifts every default port, so that two playgrounds can
+    // run side by side on one host
+    PortOffset int
+    DBPort     int
+    PDPort     int
+    // ConfigFiles maps a playground role (db, kv, pd, tiflash) to a config file
+    ConfigFiles map[string]string
+    ExtraArgs   []string
 }
 
 func Load() *Config {
@@ -35,21 +149,87 @@ func Load() *Config {
     
     // server configuration
     cfg.Server.Port = getEnvInt("SERVER_PORT", 5050)
+    cfg.Server.AuthDisabled = getEnvBool("AUTH_DISABLED", false)
+    cfg.Server.SignatureWindow = getEnvDuration("SIGNATURE_WINDOW", 5*time.Minute)
+    cfg.Server.SecretKey = getEnv("SECRET_ENCRYPTION_KEY", "")
 
     // API configuration
     cfg.APIEndpoint = getEnv("API_ENDPOINT", "http://localhost:5050/api/v1/status")
+    cfg.APIKey = getEnv("API_KEY", "")
+    cfg.SigningSecret = getEnv("SIGNING_SECRET", "")
+    cfg.SpoolDir = getEnv("REPORT_SPOOL_DIR", "spool")
+    cfg.ReportAttempts = getEnvInt("REPORT_ATTEMPTS", 4)
+    cfg.ReportBackoff = getEnvDuration("REPORT_BACKOFF", 5*time.Second)
+    cfg.Trigger = getEnv("CHECK_TRIGGER", "")
+    // Jenkins sets BUILD_URL
+    cfg.CIJobURL = getEnv("CI_JOB_URL", getEnv("BUILD_URL", githubActionsJobURL()))
     
     // log configuration
     cfg.LogPath = getEnv("LOG_PATH", "logs/tiup_checker.log")
+    // per-run output such as the playground log
+    cfg.RunDir = getEnv("RUN_DIR", "logs/runs")
 
     // github token
     cfg.GitHubToken = getEnv("GH_TOKEN", "")
+    cfg.GitHubAPIURL = getEnv("GITHUB_API_URL", "https://api.github.com")
+    cfg.GitHubCachePath = getEnv("GITHUB_CACHE_PATH", "cache/github_commits.json")
+    cfg.ComponentsConfig = getEnv("COMPONENTS_CONFIG", "")
 
     // cron schedule
     cfg.CronSchedule = getEnv("CRON_SCHEDULE", "*/30 * * * *")
     cfg.EnableCron = getEnvBool("ENABLE_CRON", false)
 
-    
+    // branches followed by the commit updater, e.g. "release-8.5,release-9.0"
+    cfg.TrackedBranches = getEnvList("TRACKED_BRANCHES", nil)
+    cfg.ReleaseBranchPattern = getEnv("RELEASE_BRANCH_PATTERN", `^release-\d+\.\d+$`)
+    cfg.ReleaseBranchLimit = getEnvInt("RELEASE_BRANCH_LIMIT", 3)
+
+    cfg.FreshnessThreshold = getEnvDuration("FRESHNESS_THRESHOLD", 48*time.Hour)
+
+    // checker stages to skip, e.g. "smoke_test"
+    cfg.SkipStages = getEnvList("SKIP_STAGES", nil)
+
+    // components installed by the download check, "name" or "name:version"
+    cfg.TiUPChannel = getEnv("TIUP_CHANNEL", "nightly")
+    cfg.TiUPComponents = getEnvList("TIUP_COMPONENTS", []string{
+        "tidb", "tikv", "pd", "tiflash", "prometheus", "grafana",
+        "ctl", "cdc", "tiproxy", "br", "dumpling",
+    })
+    // e.g. "nightly,v8.5.0@https://tiup-mirrors.pingcap.com"
+    cfg.TiUPTargets = getEnvList("TIUP_TARGETS", nil)
+    cfg.TargetTimeout = getEnvDuration("TARGET_TIMEOUT", 10*time.Minute)
+    cfg.TargetsHome = getEnv("TIUP_TARGETS_HOME", "tiup-homes")
+    cfg.Isolate = getEnvBool("TIUP_ISOLATE", false)
+    cfg.WarmCacheDir = getEnv("TIUP_WARM_CACHE", "")
+    cfg.KeepHomeOnFailure = getEnvBool("TIUP_KEEP_HOME_ON_FAILURE", false)
+
+    // playground topology
+    cfg.Playground.DB = getEnvInt("PLAYGROUND_DB", 1)
+    cfg.Playground.KV = getEnvInt("PLAYGROUND_KV", 1)
+    cfg.Playground.PD = getEnvInt("PLAYGROUND_PD", 1)
+    cfg.Playground.TiFlash = getEnvInt("PLAYGROUND_TIFLASH", 1)
+    cfg.Playground.TiFlashWrite = getEnvInt("PLAYGROUND_TIFLASH_WRITE", 0)
+    cfg.Playground.TiFlashCompute = getEnvInt("PLAYGROUND_TIFLASH_COMPUTE", 0)
+    cfg.Playground.Mode = getEnv("PLAYGROUND_MODE", "")
+    cfg.Playground.WithoutMonitor = getEnvBool("PLAYGROUND_WITHOUT_MONITOR", false)
+    cfg.Playground.PortOffset = getEnvInt("PLAYGROUND_PORT_OFFSET", 0)
+    cfg.Playground.DBPort = getEnvInt("PLAYGROUND_DB_PORT", 0)
+    cfg.Playground.PDPort = getEnvInt("PLAYGROUND_PD_PORT", 0)
+    // e.g. "db=/etc/tidb.toml,kv=/etc/tikv.toml"
+    cfg.Playground.ConfigFiles = getEnvMap("PLAYGROUND_CONFIGS")
+    cfg.Playground.ExtraArgs = strings.Fields(getEnv("PLAYGROUND_EXTRA_ARGS", ""))
+    cfg.ReadinessTimeout = getEnvDuration("READINESS_TIMEOUT", 5*time.Minute)
+    cfg.SmokeTestDir = getEnv("SMOKE_TEST_DIR", "")
+    cfg.TiFlashReplicaTimeout = getEnvDuration("TIFLASH_REPLICA_TIMEOUT", 3*time.Minute)
+
+    // environment checks before the run
+    cfg.Preflight.Skip = getEnvList("PREFLIGHT_SKIP", nil)
+    cfg.Preflight.MinFreeDiskGB = getEnvInt("PREFLIGHT_MIN_FREE_DISK_GB", 10)
+    cfg.Preflight.MinOpenFiles = getEnvInt("PREFLIGHT_MIN_OPEN_FILES", 16384)
+
+    // "stage:attempts:backoff[:category|category...]" entries
+    cfg.Retries = getEnvRetries("RETRY_POLICIES", "download:3:30s:network")
+
     return cfg
 }
 
@@ -76,4 +256,78 @@ func getEnvBool(key string, defaultValue bool) bool {
         }
     }
     return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    if value := os.Getenv(key); value != "" {
+        if d, err := time.ParseDuration(value); err == nil {
+            return d
+        }
+    }
+    return defaultValue
+}
+
+// getEnvList reads a comma separated list, dropping empty items.
+func getEnvList(key string, defaultValue []string) []string {
+    value := os.Getenv(key)
+    if value == "" {
+        return defaultValue
+    }
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// getEnvMap reads a comma separated list of key=value pairs.
+func getEnvMap(key string) map[string]string {
+    m := make(map[string]string)
+    for _, item := range getEnvList(key, nil) {
+        if k, v, ok := strings.Cut(item, "="); ok {
+            m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+        }
+    }
+    return m
+}
+
+// getEnvRetries reads comma separated "stage:attempts:backoff[:categories]"
+// retry policies, categories separated by "|". Invalid entries are dropped.
+func getEnvRetries(key, defaultValue string) map[string]RetryConfig {
+    retries := make(map[string]RetryConfig)
+    for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+        parts := strings.Split(strings.TrimSpace(item), ":")
+        if len(parts) < 3 || len(parts) > 4 {
+            continue
+        }
+        attempts, err := strconv.Atoi(parts[1])
+        if err != nil {
+            continue
+        }
+        backoff, err := time.ParseDuration(parts[2])
+        if err != nil {
+            continue
+        }
+        rc := RetryConfig{MaxAttempts: attempts, Backoff: backoff}
+        if len(parts) == 4 {
+            for _, category := range strings.Split(parts[3], "|") {
+                if category = strings.TrimSpace(category); category != "" {
+                    rc.Categories = append(rc.Categories, category)
+                }
+            }
+        }
+        retries[strings.TrimSpace(parts[0])] = rc
+    }
+    return retries
+}
+
+// githubActionsJobURL is the URL of the running GitHub Actions job, if any.
+func githubActionsJobURL() string {
+    server, repo, run := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+    if server == "" || repo == "" || run == "" {
+        return ""
+    }
+    return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, run)
+}
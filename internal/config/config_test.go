@@ -0,0 +1,194 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetEnvList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: []string{"default"}},
+		{value: "a", want: []string{"a"}},
+		{value: " a , b,,c ", want: []string{"a", "b", "c"}},
+		{value: " , ", want: nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_LIST", tt.value)
+		if got := getEnvList("TEST_LIST", []string{"default"}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getEnvList(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGetEnvMap(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "db=/etc/tidb.toml", want: map[string]string{"db": "/etc/tidb.toml"}},
+		{value: "db = a.toml, kv=b.toml,invalid", want: map[string]string{"db": "a.toml", "kv": "b.toml"}},
+		{value: "db=a=b", want: map[string]string{"db": "a=b"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_MAP", tt.value)
+		if got := getEnvMap("TEST_MAP"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getEnvMap(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPlayground(t *testing.T) {
+	t.Setenv("PLAYGROUND_DB", "2")
+	t.Setenv("PLAYGROUND_TIFLASH", "0")
+	t.Setenv("PLAYGROUND_PORT_OFFSET", "not a number")
+	t.Setenv("PLAYGROUND_WITHOUT_MONITOR", "true")
+	t.Setenv("PLAYGROUND_CONFIGS", "kv=/etc/tikv.toml")
+	t.Setenv("PLAYGROUND_EXTRA_ARGS", "  --host   0.0.0.0 ")
+
+	got := Load().Playground
+	want := PlaygroundConfig{
+		DB: 2, KV: 1, PD: 1, TiFlash: 0,
+		WithoutMonitor: true,
+		ConfigFiles:    map[string]string{"kv": "/etc/tikv.toml"},
+		ExtraArgs:      []string{"--host", "0.0.0.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Playground = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadTrackedBranches(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		branches []string
+		pattern  string
+		limit    int
+	}{
+		{
+			name:    "defaults",
+			pattern: `^release-\d+\.\d+$`,
+			limit:   3,
+		},
+		{
+			name: "configured",
+			env: map[string]string{
+				"TRACKED_BRANCHES":       "release-8.5, release-9.0",
+				"RELEASE_BRANCH_PATTERN": `^release-\d+\.\d+-beta$`,
+				"RELEASE_BRANCH_LIMIT":   "0",
+			},
+			branches: []string{"release-8.5", "release-9.0"},
+			pattern:  `^release-\d+\.\d+-beta$`,
+			limit:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"TRACKED_BRANCHES", "RELEASE_BRANCH_PATTERN", "RELEASE_BRANCH_LIMIT"} {
+				t.Setenv(key, tt.env[key])
+			}
+			cfg := Load()
+			if !reflect.DeepEqual(cfg.TrackedBranches, tt.branches) || cfg.ReleaseBranchPattern != tt.pattern || cfg.ReleaseBranchLimit != tt.limit {
+				t.Errorf("Load = %v %q %d, want %v %q %d", cfg.TrackedBranches, cfg.ReleaseBranchPattern, cfg.ReleaseBranchLimit,
+					tt.branches, tt.pattern, tt.limit)
+			}
+		})
+	}
+}
+
+func TestLoadPreflight(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want PreflightConfig
+	}{
+		{
+			name: "defaults",
+			want: PreflightConfig{MinFreeDiskGB: 10, MinOpenFiles: 16384},
+		},
+		{
+			name: "configured",
+			env: map[string]string{
+				"PREFLIGHT_SKIP":             "mirror, endpoint",
+				"PREFLIGHT_MIN_FREE_DISK_GB": "20",
+				"PREFLIGHT_MIN_OPEN_FILES":   "65536",
+			},
+			want: PreflightConfig{Skip: []string{"mirror", "endpoint"}, MinFreeDiskGB: 20, MinOpenFiles: 65536},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PREFLIGHT_SKIP", "PREFLIGHT_MIN_FREE_DISK_GB", "PREFLIGHT_MIN_OPEN_FILES"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := Load().Preflight; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Preflight = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvRetries(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]RetryConfig
+	}{
+		{value: "", want: map[string]RetryConfig{"download": {MaxAttempts: 3, Backoff: 30 * time.Second, Categories: []string{"network"}}}},
+		{value: "playground:2:1m", want: map[string]RetryConfig{"playground": {MaxAttempts: 2, Backoff: time.Minute}}},
+		{
+			value: " download:3:10s:network| infra ,playground:2:5s:startup_timeout",
+			want: map[string]RetryConfig{
+				"download":   {MaxAttempts: 3, Backoff: 10 * time.Second, Categories: []string{"network", "infra"}},
+				"playground": {MaxAttempts: 2, Backoff: 5 * time.Second, Categories: []string{"startup_timeout"}},
+			},
+		},
+		{value: "download:three:10s,playground:2:soon,tiflash:2,smoke_test:1:1s:sql_failure:extra", want: map[string]RetryConfig{}},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_RETRIES", tt.value)
+		if got := getEnvRetries("TEST_RETRIES", "download:3:30s:network"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getEnvRetries(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoadCIJobURL(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "not in ci"},
+		{
+			name: "github actions",
+			env:  map[string]string{"GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "pingcap/tiup", "GITHUB_RUN_ID": "42"},
+			want: "https://github.com/pingcap/tiup/actions/runs/42",
+		},
+		{name: "incomplete github actions", env: map[string]string{"GITHUB_SERVER_URL": "https://github.com", "GITHUB_RUN_ID": "42"}},
+		{
+			name: "jenkins",
+			env:  map[string]string{"BUILD_URL": "https://jenkins.example.com/job/1/", "GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "pingcap/tiup", "GITHUB_RUN_ID": "42"},
+			want: "https://jenkins.example.com/job/1/",
+		},
+		{
+			name: "configured",
+			env:  map[string]string{"CI_JOB_URL": "https://ci.example.com/job/1", "BUILD_URL": "https://jenkins.example.com/job/1/"},
+			want: "https://ci.example.com/job/1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CI_JOB_URL", "BUILD_URL", "GITHUB_SERVER_URL", "GITHUB_REPOSITORY", "GITHUB_RUN_ID"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := Load().CIJobURL; got != tt.want {
+				t.Errorf("CIJobURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const createAPIKeysTable = `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    platforms VARCHAR(255) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    UNIQUE KEY idx_key_hash (key_hash),
+    INDEX idx_name (name)
+)`
+
+const apiKeyColumns = `id, name, prefix, key_hash, signing_secret, platforms, scopes, created_at, last_used_at, revoked_at`
+
+// CreateAPIKey stores a new API key and returns its id
+func (db *DB) CreateAPIKey(ctx context.Context, key *auth.Key) (int64, error) {
+	query := `
+        INSERT INTO api_keys (name, prefix, key_hash, signing_secret, platforms, scopes)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+
+	secret, err := db.sealSecret(key.Secret)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.db.ExecContext(ctx, query, key.Name, key.Prefix, key.Hash, secret,
+		strings.Join(key.Platforms, ","), strings.Join(key.Scopes, ","))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert api key: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get api key id: %w", err)
+	}
+	return id, nil
+}
+
+// GetAPIKeyByHash returns the key with the hash, revoked or not, ErrNotFound if there is none
+func (db *DB) GetAPIKeyByHash(ctx context.Context, hash string) (*auth.Key, error) {
+	row := db.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash)
+	key, err := db.scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key: %w", err)
+	}
+	return key, nil
+}
+
+// GetAPIKey returns the key with the id, revoked or not, ErrNotFound if there is none
+func (db *DB) GetAPIKey(ctx context.Context, id int64) (*auth.Key, error) {
+	row := db.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id)
+	key, err := db.scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns all keys, newest first
+func (db *DB) ListAPIKeys(ctx context.Context) ([]auth.Key, error) {
+	rows, err := db.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []auth.Key
+	for rows.Next() {
+		key, err := db.scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKeys revokes the active keys of a runner and returns how many there were
+func (db *DB) RevokeAPIKeys(ctx context.Context, name string) (int64, error) {
+	res, err := db.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE name = ? AND revoked_at IS NULL", time.Now(), name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke api keys: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get revoked api keys: %w", err)
+	}
+	return n, nil
+}
+
+// RevokeAPIKey revokes one active key, ErrNotFound if there is no such active key
+func (db *DB) RevokeAPIKey(ctx context.Context, id int64) error {
+	res, err := db.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get revoked api key: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was just used
+func (db *DB) TouchAPIKey(ctx context.Context, id int64) error {
+	if _, err := db.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (db *DB) scanAPIKey(row rowScanner) (*auth.Key, error) {
+	var (
+		key                 auth.Key
+		platforms, scopes   string
+		secret              string
+		lastUsed, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &secret, &platforms, &scopes,
+		&key.CreatedAt, &lastUsed, &revokedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if key.Secret, err = db.openSecret(secret); err != nil {
+		return nil, fmt.Errorf("signing secret of key %d: %w", key.ID, err)
+	}
+	key.Platforms = auth.ParseList(platforms)
+	key.Scopes = auth.ParseList(scopes)
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// sealSecret encrypts a signing secret for storage. Storing one in plaintext
+// is refused, a database read would be enough to forge reports.
+func (db *DB) sealSecret(secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	if db.secrets == nil {
+		return "", fmt.Errorf("refusing to store a signing secret in plaintext: %w", auth.ErrNoCipher)
+	}
+	return db.secrets.Seal(secret)
+}
+
+// openSecret decrypts a stored signing secret. Secrets stored before they
+// were encrypted are returned as they are until sealSecrets ran.
+func (db *DB) openSecret(stored string) (string, error) {
+	if !auth.IsSealed(stored) {
+		return stored, nil
+	}
+	if db.secrets == nil {
+		return "", auth.ErrNoCipher
+	}
+	return db.secrets.Open(stored)
+}
+
+// sealSecrets encrypts the signing secrets stored in plaintext, once an
+// encryption key is configured
+func (db *DB) sealSecrets(ctx context.Context) error {
+	if db.secrets == nil {
+		return nil
+	}
+
+	rows, err := db.db.QueryContext(ctx,
+		"SELECT id, signing_secret FROM api_keys WHERE signing_secret != '' AND signing_secret NOT LIKE 'v1:%'")
+	if err != nil {
+		return fmt.Errorf("failed to query plaintext signing secrets: %w", err)
+	}
+	plain := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan signing secret: %w", err)
+		}
+		plain[id] = secret
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query plaintext signing secrets: %w", err)
+	}
+
+	for id, secret := range plain {
+		sealed, err := db.secrets.Seal(secret)
+		if err != nil {
+			return err
+		}
+		if _, err := db.db.ExecContext(ctx, "UPDATE api_keys SET signing_secret = ? WHERE id = ?", sealed, id); err != nil {
+			return fmt.Errorf("failed to encrypt signing secret of key %d: %w", id, err)
+		}
+	}
+	if len(plain) > 0 {
+		logger.Info(fmt.Sprintf("Encrypted %d plaintext signing secrets", len(plain)))
+	}
+	return nil
+}
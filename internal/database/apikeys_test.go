@@ -0,0 +1,57 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+)
+
+func TestSecretStorage(t *testing.T) {
+	cipher, err := auth.NewCipher("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKey, withoutKey := &DB{secrets: cipher}, &DB{}
+	sealed, err := withKey.sealSecret("tcs_secret")
+	if err != nil {
+		t.Fatalf("sealSecret: %v", err)
+	}
+	if !auth.IsSealed(sealed) {
+		t.Errorf("sealSecret = %q, want it encrypted", sealed)
+	}
+
+	tests := []struct {
+		name    string
+		db      *DB
+		stored  string
+		want    string
+		wantErr error
+	}{
+		{name: "sealed", db: withKey, stored: sealed, want: "tcs_secret"},
+		{name: "no secret", db: withKey, stored: ""},
+		// stored before encryption was configured, sealed on the next start
+		{name: "plaintext", db: withKey, stored: "tcs_plain", want: "tcs_plain"},
+		{name: "plaintext without key", db: withoutKey, stored: "tcs_plain", want: "tcs_plain"},
+		{name: "sealed without key", db: withoutKey, stored: sealed, wantErr: auth.ErrNoCipher},
+	}
+	for _, tt := range tests {
+		got, err := tt.db.openSecret(tt.stored)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("openSecret(%s) = %q, %v, want %v", tt.name, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("openSecret(%s) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := withoutKey.sealSecret("tcs_secret"); !errors.Is(err, auth.ErrNoCipher) {
+		t.Errorf("sealSecret without a key = %v, want it refused", err)
+	}
+	if got, err := withoutKey.sealSecret(""); got != "" || err != nil {
+		t.Errorf("sealSecret of no secret = %q, %v, want nothing stored", got, err)
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+const createCheckArtifactsTable = `
+CREATE TABLE IF NOT EXISTS check_artifacts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    check_result_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    content LONGBLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_check_result_name (check_result_id, name)
+)`
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// CheckResultPlatform returns the platform of a stored check result, ErrNotFound if there is none
+func (db *DB) CheckResultPlatform(ctx context.Context, id int64) (string, error) {
+	var platform string
+	err := db.db.QueryRowContext(ctx, "SELECT platform FROM check_results WHERE id = ?", id).Scan(&platform)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query check result: %w", err)
+	}
+	return platform, nil
+}
+
+// SaveArtifact stores a file attached to a check result, replacing one with the same name
+func (db *DB) SaveArtifact(ctx context.Context, checkResultID int64, name, contentType string, content []byte) error {
+	query := `
+        INSERT INTO check_artifacts (check_result_id, name, content_type, size, content)
+        VALUES (?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE
+            content_type = VALUES(content_type),
+            size = VALUES(size),
+            content = VALUES(content),
+            created_at = CURRENT_TIMESTAMP
+    `
+
+	_, err := db.db.ExecContext(ctx, query, checkResultID, name, contentType, len(content), content)
+	if err != nil {
+		return fmt.Errorf("failed to save artifact: %w", err)
+	}
+	return nil
+}
+
+// GetArtifact returns the metadata and content of an artifact
+func (db *DB) GetArtifact(ctx context.Context, checkResultID int64, name string) (*checker.ArtifactInfo, []byte, error) {
+	query := `
+        SELECT name, content_type, size, created_at, content
+        FROM check_artifacts
+        WHERE check_result_id = ? AND name = ?
+    `
+
+	var info checker.ArtifactInfo
+	var content []byte
+	err := db.db.QueryRowContext(ctx, query, checkResultID, name).Scan(
+		&info.Name, &info.ContentType, &info.Size, &info.CreatedAt, &content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query artifact: %w", err)
+	}
+	return &info, content, nil
+}
+
+// ListArtifacts returns the artifacts stored for a check result
+func (db *DB) ListArtifacts(ctx context.Context, checkResultID int64) ([]checker.ArtifactInfo, error) {
+	results := []checker.CheckReport{{}}
+	if err := db.attachArtifacts(ctx, []int64{checkResultID}, results); err != nil {
+		return nil, err
+	}
+	return results[0].Artifacts, nil
+}
+
+// attachArtifacts loads the artifact list of the given check results, ids[i] belongs to results[i]
+func (db *DB) attachArtifacts(ctx context.Context, ids []int64, results []checker.CheckReport) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT check_result_id, name, content_type, size, created_at
+        FROM check_artifacts
+        WHERE check_result_id IN (%s)
+        ORDER BY check_result_id, name
+    `, placeholders(len(ids)))
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var info checker.ArtifactInfo
+		var checkResultID int64
+		if err := rows.Scan(&checkResultID, &info.Name, &info.ContentType, &info.Size, &info.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan artifact: %w", err)
+		}
+		if i, ok := index[checkResultID]; ok {
+			results[i].Artifacts = append(results[i].Artifacts, info)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+const createBranchCommitHistoryTable = `
+CREATE TABLE IF NOT EXISTS branch_commit_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    component VARCHAR(50) NOT NULL,
+    branch VARCHAR(100) NOT NULL,
+    git_hash CHAR(40) NOT NULL,
+    previous_hash CHAR(40) NULL,
+    commits_added INT NULL,
+    commit_time DATETIME NOT NULL,
+    observed_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    INDEX idx_component_branch_observed (component, branch, observed_at),
+    INDEX idx_git_hash (git_hash)
+)`
+
+// insertBranchHeadChange records that a branch moved to info.GitHash, previous
+// is empty for the first head seen on a branch
+func insertBranchHeadChange(ctx context.Context, tx *sql.Tx, info *checker.BranchCommitInfo, previous string) (*checker.BranchHeadChange, error) {
+	query := `
+        INSERT INTO branch_commit_history (component, branch, git_hash, previous_hash, commit_time)
+        VALUES (?, ?, ?, ?, ?)
+    `
+
+	var previousHash sql.NullString
+	if previous != "" {
+		previousHash = sql.NullString{String: previous, Valid: true}
+	}
+	res, err := tx.ExecContext(ctx, query, info.Component, info.Branch, info.GitHash, previousHash, info.CommitTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert branch head change: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get branch head change id: %w", err)
+	}
+
+	return &checker.BranchHeadChange{
+		ID:           id,
+		Component:    info.Component,
+		Branch:       info.Branch,
+		GitHash:      info.GitHash,
+		PreviousHash: previous,
+		CommitTime:   info.CommitTime,
+		ObservedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// SetCommitsAdded stores how many commits a recorded head change brought in
+func (db *DB) SetCommitsAdded(ctx context.Context, changeID int64, commits int) error {
+	_, err := db.db.ExecContext(ctx,
+		"UPDATE branch_commit_history SET commits_added = ? WHERE id = ?", commits, changeID)
+	if err != nil {
+		return fmt.Errorf("failed to update branch head change: %w", err)
+	}
+	return nil
+}
+
+// GetBranchCommit returns the recorded head of one branch, ErrNotFound if there is none
+func (db *DB) GetBranchCommit(ctx context.Context, component, branch string) (*checker.BranchCommitInfo, error) {
+	query := `
+        SELECT component, branch, git_hash, commit_time, updated_at
+        FROM branch_commits
+        WHERE component = ? AND branch = ?
+    `
+
+	var info checker.BranchCommitInfo
+	err := db.db.QueryRowContext(ctx, query, component, branch).Scan(
+		&info.Component, &info.Branch, &info.GitHash, &info.CommitTime, &info.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query branch head: %w", err)
+	}
+	return &info, nil
+}
+
+// ErrAmbiguousHash is returned when a short hash matches several commits
+var ErrAmbiguousHash = errors.New("ambiguous git hash")
+
+// HistoryDistance is how far a former head is behind the current one
+type HistoryDistance struct {
+	// GitHash is the full hash of the former head
+	GitHash string
+	// Commits is nil when a head change on the way has no commit count
+	Commits    *int
+	CommitTime time.Time
+}
+
+// historyMatch is a former head matching a hash prefix, at its latest change
+type historyMatch struct {
+	id         int64
+	gitHash    string
+	commitTime time.Time
+}
+
+// DistanceFromHead looks up hash, full or a prefix of at least 7 characters,
+// among the recorded heads of a branch and sums the commits added since. It
+// returns ErrNotFound if hash was never a head and ErrAmbiguousHash if it is a
+// prefix of several.
+func (db *DB) DistanceFromHead(ctx context.Context, component, branch, hash string) (*HistoryDistance, error) {
+	// two distinct matches are enough to know the prefix is ambiguous
+	rows, err := db.db.QueryContext(ctx, `
+        SELECT MAX(id), git_hash, MAX(commit_time)
+        FROM branch_commit_history
+        WHERE component = ? AND branch = ? AND git_hash LIKE CONCAT(?, '%')
+        GROUP BY git_hash
+        LIMIT 2
+    `, component, branch, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query branch history: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []historyMatch
+	for rows.Next() {
+		var m historyMatch
+		if err := rows.Scan(&m.id, &m.gitHash, &m.commitTime); err != nil {
+			return nil, fmt.Errorf("failed to scan branch history: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query branch history: %w", err)
+	}
+	match, err := singleMatch(matches)
+	if err != nil {
+		return nil, err
+	}
+	id := match.id
+	distance := HistoryDistance{GitHash: match.gitHash, CommitTime: match.commitTime}
+
+	var changes, counted, commits int
+	err = db.db.QueryRowContext(ctx, `
+        SELECT COUNT(*), COUNT(commits_added), COALESCE(SUM(commits_added), 0)
+        FROM branch_commit_history
+        WHERE component = ? AND branch = ? AND id > ?
+    `, component, branch, id).Scan(&changes, &counted, &commits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum branch history: %w", err)
+	}
+	if changes == counted {
+		distance.Commits = &commits
+	}
+	return &distance, nil
+}
+
+func singleMatch(matches []historyMatch) (*historyMatch, error) {
+	switch len(matches) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, ErrAmbiguousHash
+	}
+}
+
+// GetBranchCommitHistory returns the most recent head changes of a branch, newest first
+func (db *DB) GetBranchCommitHistory(ctx context.Context, component, branch string, limit int) ([]checker.BranchHeadChange, error) {
+	query := `
+        SELECT id, component, branch, git_hash, previous_hash, commits_added, commit_time, observed_at
+        FROM branch_commit_history
+        WHERE component = ? AND branch = ?
+        ORDER BY observed_at DESC, id DESC
+        LIMIT ?
+    `
+
+	rows, err := db.db.QueryContext(ctx, query, component, branch, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query branch history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []checker.BranchHeadChange{}
+	for rows.Next() {
+		var change checker.BranchHeadChange
+		var previous sql.NullString
+		var commitsAdded sql.NullInt64
+		if err := rows.Scan(&change.ID, &change.Component, &change.Branch, &change.GitHash, &previous,
+			&commitsAdded, &change.CommitTime, &change.ObservedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan branch history: %w", err)
+		}
+		change.PreviousHash = previous.String
+		if commitsAdded.Valid {
+			n := int(commitsAdded.Int64)
+			change.CommitsAdded = &n
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, rows.Err()
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSingleMatch(t *testing.T) {
+	a := historyMatch{id: 3, gitHash: "1feea49553ec8fd2e7a8c2e2c4f3b6d0a9e1f2c3"}
+	b := historyMatch{id: 5, gitHash: "1feea49aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}
+	tests := []struct {
+		name    string
+		matches []historyMatch
+		want    string
+		wantErr error
+	}{
+		{name: "never a head", wantErr: ErrNotFound},
+		{name: "unique prefix", matches: []historyMatch{a}, want: a.gitHash},
+		{name: "ambiguous prefix", matches: []historyMatch{a, b}, wantErr: ErrAmbiguousHash},
+	}
+	for _, tt := range tests {
+		got, err := singleMatch(tt.matches)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("singleMatch(%s) = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.gitHash != tt.want {
+			t.Errorf("singleMatch(%s) = %+v, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+}
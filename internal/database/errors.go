@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+const createCheckErrorsTable = `
+CREATE TABLE IF NOT EXISTS check_errors (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    check_result_id INT NOT NULL,
+    position INT NOT NULL,
+    stage VARCHAR(100) NOT NULL,
+    category VARCHAR(50) NOT NULL,
+    error TEXT,
+    timestamp DATETIME(3) NULL,
+    INDEX idx_check_result (check_result_id),
+    INDEX idx_category_timestamp (category, timestamp)
+)`
+
+// ErrorQuery filters stored errors, empty fields match everything
+type ErrorQuery struct {
+	Category string
+	Stage    string
+	Platform string
+	Days     int
+	Limit    int
+}
+
+// saveErrors stores the errors of a check result inside its transaction
+func saveErrors(ctx context.Context, tx *sql.Tx, checkResultID int64, errs []checker.Error) error {
+	query := `
+        INSERT INTO check_errors (check_result_id, position, stage, category, error, timestamp, attempt)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
+
+	for i, e := range errs {
+		_, err := tx.ExecContext(ctx, query, checkResultID, i, e.Stage, e.Category, e.Error, nullTime(e.Timestamp), e.Attempt)
+		if err != nil {
+			return fmt.Errorf("failed to insert error of stage %s: %w", e.Stage, err)
+		}
+	}
+
+	return nil
+}
+
+// errorFilter builds the WHERE clause shared by GetErrors and GetErrorSummary
+func errorFilter(q ErrorQuery) (string, []interface{}) {
+	conds := []string{"r.timestamp >= ?"}
+	args := []interface{}{time.Now().AddDate(0, 0, -q.Days)}
+	if q.Category != "" {
+		conds = append(conds, "e.category = ?")
+		args = append(args, q.Category)
+	}
+	if q.Stage != "" {
+		conds = append(conds, "e.stage = ?")
+		args = append(args, q.Stage)
+	}
+	if q.Platform != "" {
+		conds = append(conds, "r.platform = ?")
+		args = append(args, q.Platform)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// GetErrors returns the stored errors matching q, newest first
+func (db *DB) GetErrors(ctx context.Context, q ErrorQuery) ([]checker.ErrorRecord, error) {
+	where, args := errorFilter(q)
+	query := `
+        SELECT e.check_result_id, r.platform, r.mirror, r.channel, r.status,
+               e.stage, e.category, e.error, e.attempt, COALESCE(e.timestamp, r.timestamp)
+        FROM check_errors e
+        JOIN check_results r ON r.id = e.check_result_id
+        WHERE ` + where + `
+        ORDER BY r.timestamp DESC, e.check_result_id DESC, e.position
+        LIMIT ?
+    `
+	args = append(args, q.Limit)
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors: %w", err)
+	}
+	defer rows.Close()
+
+	records := []checker.ErrorRecord{}
+	for rows.Next() {
+		var (
+			r      checker.ErrorRecord
+			errMsg sql.NullString
+		)
+		if err := rows.Scan(&r.CheckResultID, &r.Platform, &r.Mirror, &r.Channel, &r.Status,
+			&r.Stage, &r.Category, &errMsg, &r.Attempt, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan error: %w", err)
+		}
+		r.Error = errMsg.String
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// GetErrorSummary counts the stored errors matching q by category
+func (db *DB) GetErrorSummary(ctx context.Context, q ErrorQuery) ([]checker.CategoryCount, error) {
+	where, args := errorFilter(q)
+	query := `
+        SELECT e.category, COUNT(*), COUNT(DISTINCT e.check_result_id)
+        FROM check_errors e
+        JOIN check_results r ON r.id = e.check_result_id
+        WHERE ` + where + `
+        GROUP BY e.category
+        ORDER BY COUNT(*) DESC
+    `
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query error summary: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []checker.CategoryCount{}
+	for rows.Next() {
+		var cc checker.CategoryCount
+		if err := rows.Scan(&cc.Category, &cc.Count, &cc.Runs); err != nil {
+			return nil, fmt.Errorf("failed to scan error summary: %w", err)
+		}
+		counts = append(counts, cc)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        ErrorQuery
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{name: "days only", q: ErrorQuery{Days: 7}, wantCond: "r.timestamp >= ?"},
+		{
+			name:     "all filters",
+			q:        ErrorQuery{Category: "network", Stage: "download", Platform: "linux-amd64", Days: 1},
+			wantCond: "r.timestamp >= ? AND e.category = ? AND e.stage = ? AND r.platform = ?",
+			wantArgs: []interface{}{"network", "download", "linux-amd64"},
+		},
+		{
+			name:     "platform",
+			q:        ErrorQuery{Platform: "darwin-arm64", Days: 30},
+			wantCond: "r.timestamp >= ? AND r.platform = ?",
+			wantArgs: []interface{}{"darwin-arm64"},
+		},
+	}
+	for _, tt := range tests {
+		before := time.Now().AddDate(0, 0, -tt.q.Days)
+		cond, args := errorFilter(tt.q)
+		if cond != tt.wantCond {
+			t.Errorf("%s: condition = %q, want %q", tt.name, cond, tt.wantCond)
+		}
+		since, ok := args[0].(time.Time)
+		if !ok || since.Before(before) || since.Sub(before) > time.Minute {
+			t.Errorf("%s: since = %v, want %d days ago", tt.name, args[0], tt.q.Days)
+		}
+		if rest := args[1:]; len(rest) != len(tt.wantArgs) || len(rest) > 0 && !reflect.DeepEqual(rest, tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, rest, tt.wantArgs)
+		}
+	}
+}
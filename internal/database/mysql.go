@@ -4,17 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/purelind/check-tiup-nightly/internal/auth"
 	"github.com/purelind/check-tiup-nightly/internal/checker"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 )
 
 type DB struct {
 	db *sql.DB
+	// secrets encrypts the signing secrets of API keys, nil if not configured
+	secrets *auth.Cipher
 }
 
 // database configuration
@@ -24,6 +28,8 @@ type Config struct {
 	User     string
 	Password string
 	Database string
+	// SecretKey encrypts the signing secrets of API keys, see auth.NewCipher
+	SecretKey string
 }
 
 type QueryType string
@@ -38,9 +44,36 @@ type QueryParams struct {
 	Days      int
 	Limit     int
 	QueryType QueryType
+	// Mirror and Channel select one target, nil matches any. The default
+	// mirror is stored as an empty mirror.
+	Mirror  *string
+	Channel *string
+}
+
+// targetFilter is the condition selecting the target of params
+func targetFilter(params QueryParams) (string, []interface{}) {
+	var cond string
+	var args []interface{}
+	if params.Mirror != nil {
+		cond += " AND mirror = ?"
+		args = append(args, *params.Mirror)
+	}
+	if params.Channel != nil {
+		cond += " AND channel = ?"
+		args = append(args, *params.Channel)
+	}
+	return cond, args
 }
 
 func New(cfg Config) (*DB, error) {
+	var secrets *auth.Cipher
+	if cfg.SecretKey != "" {
+		var err error
+		if secrets, err = auth.NewCipher(cfg.SecretKey); err != nil {
+			return nil, err
+		}
+	}
+
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&loc=Local",
 		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
 
@@ -66,7 +99,7 @@ func New(cfg Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db: db}, nil
+	return &DB{db: db, secrets: secrets}, nil
 }
 
 func (db *DB) Close() error {
@@ -87,6 +120,9 @@ func (db *DB) InitSchema(ctx context.Context) error {
             tiup_version TEXT,
             python_version TEXT,
             components_info JSON,
+            installs JSON,
+            smoke_tests JSON,
+            consistency JSON,
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             INDEX idx_platform_timestamp (platform, timestamp)
         )
@@ -96,34 +132,242 @@ func (db *DB) InitSchema(ctx context.Context) error {
 		return fmt.Errorf("failed to create check_results table: %w", err)
 	}
 
+	// columns added after the table was first created
+	if err := db.ensureColumn(ctx, "check_results", "installs", "JSON"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "smoke_tests", "JSON"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "consistency", "JSON"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "mirror", "VARCHAR(255) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "channel", "VARCHAR(100) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "home_usage", "JSON"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "run_id", "VARCHAR(64) NULL"); err != nil {
+		return err
+	}
+	if err := db.ensureIndex(ctx, "check_results", "idx_run_id", "UNIQUE INDEX idx_run_id (run_id)"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "run_info", "JSON"); err != nil {
+		return err
+	}
+	if err := db.ensureColumn(ctx, "check_results", "signed_by", "VARCHAR(100) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
 	if _, err := db.db.ExecContext(ctx, createBranchCommitsTable); err != nil {
 		return fmt.Errorf("failed to create branch_commits table: %w", err)
 	}
 
+	if _, err := db.db.ExecContext(ctx, createBranchCommitHistoryTable); err != nil {
+		return fmt.Errorf("failed to create branch_commit_history table: %w", err)
+	}
+
+	if _, err := db.db.ExecContext(ctx, createCheckStagesTable); err != nil {
+		return fmt.Errorf("failed to create check_stages table: %w", err)
+	}
+	if err := db.ensureColumn(ctx, "check_stages", "history", "JSON"); err != nil {
+		return err
+	}
+
+	if _, err := db.db.ExecContext(ctx, createCheckArtifactsTable); err != nil {
+		return fmt.Errorf("failed to create check_artifacts table: %w", err)
+	}
+
+	if _, err := db.db.ExecContext(ctx, createReportSignaturesTable); err != nil {
+		return fmt.Errorf("failed to create report_signatures table: %w", err)
+	}
+
+	if _, err := db.db.ExecContext(ctx, createAPIKeysTable); err != nil {
+		return fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+	// keys issued before reports were signed have no secret
+	if err := db.ensureColumn(ctx, "api_keys", "signing_secret", "VARCHAR(255) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// widened from VARCHAR(100) to hold sealed secrets
+	if err := db.ensureColumnLength(ctx, "api_keys", "signing_secret", 255, "VARCHAR(255) NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.sealSecrets(ctx); err != nil {
+		return err
+	}
+
+	if _, err := db.db.ExecContext(ctx, createCheckErrorsTable); err != nil {
+		return fmt.Errorf("failed to create check_errors table: %w", err)
+	}
+	if err := db.ensureColumn(ctx, "check_errors", "attempt", "INT NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing
+func (db *DB) ensureColumn(ctx context.Context, table, column, definition string) error {
+	var count int
+	err := db.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM information_schema.columns
+        WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+    `, table, column).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check column %s.%s: %w", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := db.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// ensureColumnLength widens a character column shorter than length
+func (db *DB) ensureColumnLength(ctx context.Context, table, column string, length int64, definition string) error {
+	var current sql.NullInt64
+	err := db.db.QueryRowContext(ctx, `
+        SELECT CHARACTER_MAXIMUM_LENGTH FROM information_schema.columns
+        WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+    `, table, column).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to check column %s.%s: %w", table, column, err)
+	}
+	if current.Valid && current.Int64 >= length {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s MODIFY %s %s", table, column, definition)
+	if _, err := db.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to widen column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// ensureIndex adds an index to an existing table if it is missing
+func (db *DB) ensureIndex(ctx context.Context, table, index, definition string) error {
+	var count int
+	err := db.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM information_schema.statistics
+        WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?
+    `, table, index).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to check index %s.%s: %w", table, index, err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD %s", table, definition)
+	if _, err := db.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to add index %s.%s: %w", table, index, err)
+	}
 	return nil
 }
 
-// save check result
-func (db *DB) SaveCheckResult(ctx context.Context, report *checker.CheckReport) error {
+// save check result and return its id. A report with the run ID of a stored
+// one is not saved again, created is false and the stored id is returned.
+func (db *DB) SaveCheckResult(ctx context.Context, report *checker.CheckReport) (id int64, created bool, err error) {
+	if report.RunID != "" {
+		id, err := db.checkResultIDByRunID(ctx, report.RunID)
+		if err == nil {
+			return id, false, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return 0, false, err
+		}
+	}
+
+	id, err = db.insertCheckResult(ctx, report)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry && report.RunID != "" {
+		// the same report was stored concurrently
+		id, err := db.checkResultIDByRunID(ctx, report.RunID)
+		return id, false, err
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// errDuplicateEntry is the MySQL error number of a unique key violation
+const errDuplicateEntry = 1062
+
+func (db *DB) checkResultIDByRunID(ctx context.Context, runID string) (int64, error) {
+	var id int64
+	err := db.db.QueryRowContext(ctx, "SELECT id FROM check_results WHERE run_id = ?", runID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query check result by run id: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) insertCheckResult(ctx context.Context, report *checker.CheckReport) (int64, error) {
 	query := `
         INSERT INTO check_results 
-        (timestamp, status, platform, os, arch, errors, tiup_version, python_version, components_info)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+        (timestamp, status, platform, os, arch, errors, tiup_version, python_version, components_info, installs, smoke_tests, consistency,
+         mirror, channel, home_usage, run_id, run_info, signed_by)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
 
 	// serialize JSON fields
 	errorsJSON, err := json.Marshal(report.Errors)
 	if err != nil {
-		return fmt.Errorf("failed to marshal errors: %w", err)
+		return 0, fmt.Errorf("failed to marshal errors: %w", err)
 	}
 
 	componentsJSON, err := json.Marshal(report.Version.Components)
 	logger.Info(fmt.Sprintf("Components: %s", string(componentsJSON)))
 	if err != nil {
-		return fmt.Errorf("failed to marshal components: %w", err)
+		return 0, fmt.Errorf("failed to marshal components: %w", err)
+	}
+
+	installsJSON, err := json.Marshal(report.Installs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal installs: %w", err)
+	}
+
+	smokeTestsJSON, err := json.Marshal(report.SmokeTests)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal smoke tests: %w", err)
+	}
+
+	consistencyJSON, err := json.Marshal(report.Consistency)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal consistency: %w", err)
+	}
+
+	homeUsageJSON, err := json.Marshal(report.Home)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal home usage: %w", err)
+	}
+
+	runInfoJSON, err := json.Marshal(report.Run)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal run info: %w", err)
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	_, err = db.db.ExecContext(ctx, query,
+	res, err := tx.ExecContext(ctx, query,
 		report.Timestamp,
 		report.Status,
 		report.Platform,
@@ -133,26 +377,55 @@ func (db *DB) SaveCheckResult(ctx context.Context, report *checker.CheckReport)
 		report.Version.TiUP,
 		report.Version.Python,
 		componentsJSON,
+		installsJSON,
+		smokeTestsJSON,
+		consistencyJSON,
+		report.Mirror,
+		report.Channel,
+		homeUsageJSON,
+		sql.NullString{String: report.RunID, Valid: report.RunID != ""},
+		runInfoJSON,
+		report.SignedBy,
 	)
 
 	if err != nil {
-		return fmt.Errorf("failed to insert check result: %w", err)
+		return 0, fmt.Errorf("failed to insert check result: %w", err)
 	}
 
-	return nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get check result id: %w", err)
+	}
+
+	if err := saveStages(ctx, tx, id, report.Stages); err != nil {
+		return 0, err
+	}
+
+	if err := saveErrors(ctx, tx, id, report.Errors); err != nil {
+		return 0, err
+	}
+
+	if err := saveSignature(ctx, tx, id, report.Signature); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit check result: %w", err)
+	}
+
+	return id, nil
 }
 
-// GetLatestResults get the latest results of all platforms
+// GetLatestResults get the latest results of all platforms, one per mirror and channel checked
 func (db *DB) GetLatestResults(ctx context.Context) ([]checker.CheckReport, error) {
 	query := `
         WITH RankedResults AS (
             SELECT *,
-                ROW_NUMBER() OVER (PARTITION BY platform ORDER BY timestamp DESC) as rn
+                ROW_NUMBER() OVER (PARTITION BY platform, mirror, channel ORDER BY timestamp DESC) as rn
             FROM check_results
             WHERE platform IN (?, ?, ?, ?)
         )
-        SELECT id, timestamp, status, platform, os, arch, 
-               errors, tiup_version, components_info, created_at
+        SELECT ` + resultColumns + `
         FROM RankedResults
         WHERE rn = 1
     `
@@ -169,24 +442,25 @@ func (db *DB) GetLatestResults(ctx context.Context) ([]checker.CheckReport, erro
 func (db *DB) GetPlatformResults(ctx context.Context, params QueryParams) ([]checker.CheckReport, error) {
 	var query string
 	var args []interface{}
+	target, targetArgs := targetFilter(params)
 
 	switch params.QueryType {
 	case QueryByDays:
 		query = `
-            SELECT id, timestamp, status, platform, os, arch, errors, tiup_version, components_info, created_at FROM check_results
-            WHERE platform = ?
+            SELECT ` + resultColumns + ` FROM check_results
+            WHERE platform = ?` + target + `
             AND timestamp >= DATE_SUB(NOW(), INTERVAL ? DAY)
             ORDER BY timestamp DESC
         `
-		args = []interface{}{params.Platform, params.Days}
+		args = append(append([]interface{}{params.Platform}, targetArgs...), params.Days)
 	case QueryByLimit:
 		query = `
-            SELECT id, timestamp, status, platform, os, arch, errors, tiup_version, components_info, created_at FROM check_results 
-            WHERE platform = ?
+            SELECT ` + resultColumns + ` FROM check_results 
+            WHERE platform = ?` + target + `
             ORDER BY timestamp DESC 
             LIMIT ?
         `
-		args = []interface{}{params.Platform, params.Limit}
+		args = append(append([]interface{}{params.Platform}, targetArgs...), params.Limit)
 	}
 
 	return db.queryResults(ctx, query, args...)
@@ -194,17 +468,40 @@ func (db *DB) GetPlatformResults(ctx context.Context, params QueryParams) ([]che
 
 // GetPlatformHistory get the history records of a specified platform
 func (db *DB) GetPlatformHistory(ctx context.Context, params QueryParams) ([]checker.CheckReport, error) {
+	target, targetArgs := targetFilter(params)
 	query := `
-        SELECT * FROM check_results 
-        WHERE platform = ?
+        SELECT ` + resultColumns + ` FROM check_results 
+        WHERE platform = ?` + target + `
         AND timestamp >= ?
         ORDER BY timestamp DESC
     `
 
 	daysAgo := time.Now().AddDate(0, 0, -params.Days)
-	return db.queryResults(ctx, query, params.Platform, daysAgo)
+	args := append(append([]interface{}{params.Platform}, targetArgs...), daysAgo)
+	return db.queryResults(ctx, query, args...)
 }
 
+// GetRun returns the check result of a run, ErrNotFound if there is none
+func (db *DB) GetRun(ctx context.Context, runID string) (*checker.CheckReport, error) {
+	query := `
+        SELECT ` + resultColumns + ` FROM check_results
+        WHERE run_id = ?
+    `
+
+	results, err := db.queryResults(ctx, query, runID)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+	return &results[0], nil
+}
+
+// resultColumns are the check_results columns scanned by queryResults, in order
+const resultColumns = `id, timestamp, status, platform, os, arch, errors, tiup_version, components_info, created_at,
+               installs, smoke_tests, consistency, mirror, channel, home_usage, run_id, run_info, signed_by`
+
 // common query results processing
 func (db *DB) queryResults(ctx context.Context, query string, args ...interface{}) ([]checker.CheckReport, error) {
 	rows, err := db.db.QueryContext(ctx, query, args...)
@@ -214,12 +511,14 @@ func (db *DB) queryResults(ctx context.Context, query string, args ...interface{
 	defer rows.Close()
 
 	var results []checker.CheckReport
+	var ids []int64
 	for rows.Next() {
 		var report checker.CheckReport
-		var errorsJSON, componentsJSON sql.NullString
+		var errorsJSON, componentsJSON, installsJSON, smokeTestsJSON, consistencyJSON, homeUsageJSON, runInfoJSON sql.NullString
 		var timestamp time.Time
 		var id sql.NullInt64
 		var createdAt time.Time
+		var runID sql.NullString
 
 		err := rows.Scan(
 			&id,
@@ -232,11 +531,22 @@ func (db *DB) queryResults(ctx context.Context, query string, args ...interface{
 			&report.Version.TiUP,
 			&componentsJSON,
 			&createdAt,
+			&installsJSON,
+			&smokeTestsJSON,
+			&consistencyJSON,
+			&report.Mirror,
+			&report.Channel,
+			&homeUsageJSON,
+			&runID,
+			&runInfoJSON,
+			&report.SignedBy,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
+		report.ID = id.Int64
+		report.RunID = runID.String
 		report.Timestamp = timestamp
 
 		// parse JSON fields
@@ -252,13 +562,56 @@ func (db *DB) queryResults(ctx context.Context, query string, args ...interface{
 			}
 		}
 
+		if installsJSON.Valid {
+			if err := json.Unmarshal([]byte(installsJSON.String), &report.Installs); err != nil {
+				logger.Error("Failed to unmarshal installs JSON:", err)
+			}
+		}
+
+		if smokeTestsJSON.Valid {
+			if err := json.Unmarshal([]byte(smokeTestsJSON.String), &report.SmokeTests); err != nil {
+				logger.Error("Failed to unmarshal smoke tests JSON:", err)
+			}
+		}
+
+		if consistencyJSON.Valid {
+			if err := json.Unmarshal([]byte(consistencyJSON.String), &report.Consistency); err != nil {
+				logger.Error("Failed to unmarshal consistency JSON:", err)
+			}
+		}
+
+		if homeUsageJSON.Valid {
+			if err := json.Unmarshal([]byte(homeUsageJSON.String), &report.Home); err != nil {
+				logger.Error("Failed to unmarshal home usage JSON:", err)
+			}
+		}
+
+		if runInfoJSON.Valid {
+			if err := json.Unmarshal([]byte(runInfoJSON.String), &report.Run); err != nil {
+				logger.Error("Failed to unmarshal run info JSON:", err)
+			}
+		}
+
 		results = append(results, report)
+		ids = append(ids, id.Int64)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
+	if err := db.attachStages(ctx, ids, results); err != nil {
+		return nil, err
+	}
+
+	if err := db.attachArtifacts(ctx, ids, results); err != nil {
+		return nil, err
+	}
+
+	if err := db.attachSignatures(ctx, ids, results); err != nil {
+		return nil, err
+	}
+
 	return results, nil
 }
 
@@ -273,7 +626,24 @@ CREATE TABLE IF NOT EXISTS branch_commits (
     UNIQUE KEY idx_component_branch (component, branch)
 )`
 
-func (db *DB) UpdateBranchCommit(ctx context.Context, info *checker.BranchCommitInfo) error {
+// UpdateBranchCommit stores the head of a branch. When the head moved, the
+// change is added to branch_commit_history and returned, otherwise it returns nil.
+func (db *DB) UpdateBranchCommit(ctx context.Context, info *checker.BranchCommitInfo) (*checker.BranchHeadChange, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx,
+		"SELECT git_hash FROM branch_commits WHERE component = ? AND branch = ? FOR UPDATE",
+		info.Component, info.Branch,
+	).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query branch head: %w", err)
+	}
+
 	query := `
         INSERT INTO branch_commits (component, branch, git_hash, commit_time)
         VALUES (?, ?, ?, ?)
@@ -283,23 +653,45 @@ func (db *DB) UpdateBranchCommit(ctx context.Context, info *checker.BranchCommit
             updated_at = CURRENT_TIMESTAMP
     `
     
-    _, err := db.db.ExecContext(ctx, query,
+    _, err = tx.ExecContext(ctx, query,
         info.Component,
         info.Branch,
         info.GitHash,
         info.CommitTime,
     )
-    
-    return err
+    if err != nil {
+        return nil, fmt.Errorf("failed to update branch head: %w", err)
+    }
+
+	var change *checker.BranchHeadChange
+	if previous != info.GitHash {
+		change, err = insertBranchHeadChange(ctx, tx, info, previous)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
 }
 
-func (db *DB) GetBranchCommits(ctx context.Context, branch string) ([]checker.BranchCommitInfo, error) {
-	query := `
+// GetBranchCommits returns the recorded heads of the given components,
+// optionally limited to one branch.
+func (db *DB) GetBranchCommits(ctx context.Context, branch string, components []string) ([]checker.BranchCommitInfo, error) {
+	if len(components) == 0 {
+		return nil, nil
+	}
+	query := fmt.Sprintf(`
         SELECT component, branch, git_hash, commit_time, updated_at
         FROM branch_commits
-        WHERE component IN ('tidb', 'tikv', 'pd', 'tiflash')
-    `
-	args := []interface{}{}
+        WHERE component IN (%s)
+    `, placeholders(len(components)))
+	args := make([]interface{}, 0, len(components)+1)
+	for _, name := range components {
+		args = append(args, name)
+	}
 	
 	if branch != "" {
 		query += " AND branch = ?"
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTargetFilter(t *testing.T) {
+	empty, nightly := "", "nightly"
+	tests := []struct {
+		name     string
+		params   QueryParams
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{name: "any target", params: QueryParams{}},
+		{name: "default mirror", params: QueryParams{Mirror: &empty}, wantCond: " AND mirror = ?", wantArgs: []interface{}{""}},
+		{name: "channel", params: QueryParams{Channel: &nightly}, wantCond: " AND channel = ?", wantArgs: []interface{}{"nightly"}},
+		{
+			name:     "both",
+			params:   QueryParams{Mirror: &empty, Channel: &nightly},
+			wantCond: " AND mirror = ? AND channel = ?",
+			wantArgs: []interface{}{"", "nightly"},
+		},
+	}
+	for _, tt := range tests {
+		cond, args := targetFilter(tt.params)
+		if cond != tt.wantCond || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("targetFilter(%s) = %q %v, want %q %v", tt.name, cond, args, tt.wantCond, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+// report_signatures keeps the signed canonical report of a check result, so
+// stored results can be verified against what the runner sent
+const createReportSignaturesTable = `
+CREATE TABLE IF NOT EXISTS report_signatures (
+    check_result_id INT PRIMARY KEY,
+    key_id INT NOT NULL,
+    signature VARCHAR(100) NOT NULL,
+    signed_at DATETIME NOT NULL,
+    payload MEDIUMBLOB NOT NULL,
+    INDEX idx_key_id (key_id)
+)`
+
+// saveSignature stores the signature of a check result inside its transaction
+func saveSignature(ctx context.Context, tx *sql.Tx, checkResultID int64, sig *checker.ReportSignature) error {
+	if sig == nil {
+		return nil
+	}
+
+	query := `
+        INSERT INTO report_signatures (check_result_id, key_id, signature, signed_at, payload)
+        VALUES (?, ?, ?, ?, ?)
+    `
+	if _, err := tx.ExecContext(ctx, query, checkResultID, sig.KeyID, sig.Value, sig.Timestamp, sig.Payload); err != nil {
+		return fmt.Errorf("failed to insert report signature: %w", err)
+	}
+	return nil
+}
+
+// GetReportSignature returns the signature of a check result with the signed
+// payload, ErrNotFound if the report was not signed
+func (db *DB) GetReportSignature(ctx context.Context, checkResultID int64) (*checker.ReportSignature, error) {
+	query := `
+        SELECT key_id, signature, signed_at, payload
+        FROM report_signatures
+        WHERE check_result_id = ?
+    `
+
+	var sig checker.ReportSignature
+	err := db.db.QueryRowContext(ctx, query, checkResultID).Scan(&sig.KeyID, &sig.Value, &sig.Timestamp, &sig.Payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report signature: %w", err)
+	}
+	return &sig, nil
+}
+
+// attachSignatures loads the signatures, without payload, of the results in one query
+func (db *DB) attachSignatures(ctx context.Context, ids []int64, results []checker.CheckReport) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT check_result_id, key_id, signature, signed_at
+        FROM report_signatures
+        WHERE check_result_id IN (%s)
+    `, placeholders(len(ids)))
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query report signatures: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			sig           checker.ReportSignature
+			checkResultID int64
+		)
+		if err := rows.Scan(&checkResultID, &sig.KeyID, &sig.Value, &sig.Timestamp); err != nil {
+			return fmt.Errorf("failed to scan report signature: %w", err)
+		}
+		if i, ok := index[checkResultID]; ok {
+			results[i].Signature = &sig
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+const createCheckStagesTable = `
+CREATE TABLE IF NOT EXISTS check_stages (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    check_result_id INT NOT NULL,
+    position INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    start_time DATETIME(3) NULL,
+    end_time DATETIME(3) NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    error TEXT,
+    INDEX idx_check_result (check_result_id),
+    INDEX idx_name_start (name, start_time)
+)`
+
+// saveStages stores the stage results of a check result inside its transaction
+func saveStages(ctx context.Context, tx *sql.Tx, checkResultID int64, stages []checker.StageResult) error {
+	query := `
+        INSERT INTO check_stages
+        (check_result_id, position, name, status, start_time, end_time, duration_ms, attempts, error, history)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+
+	for i, stage := range stages {
+		var history []byte
+		if len(stage.History) > 0 {
+			var err error
+			if history, err = json.Marshal(stage.History); err != nil {
+				return fmt.Errorf("failed to marshal history of stage %s: %w", stage.Name, err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, query,
+			checkResultID,
+			i,
+			stage.Name,
+			stage.Status,
+			nullTime(stage.Start),
+			nullTime(stage.End),
+			stage.Duration.Milliseconds(),
+			stage.Attempts,
+			stage.Error,
+			history,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert stage %s: %w", stage.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// attachStages loads the stages of the given check results, ids[i] belongs to results[i]
+func (db *DB) attachStages(ctx context.Context, ids []int64, results []checker.CheckReport) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	index := make(map[int64]int, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		args = append(args, id)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT check_result_id, name, status, start_time, end_time, duration_ms, attempts, error, history
+        FROM check_stages
+        WHERE check_result_id IN (%s)
+        ORDER BY check_result_id, position
+    `, placeholders(len(ids)))
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query stages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			stage         checker.StageResult
+			checkResultID int64
+			start, end    sql.NullTime
+			durationMs    int64
+			errMsg        sql.NullString
+			history       []byte
+		)
+		if err := rows.Scan(&checkResultID, &stage.Name, &stage.Status, &start, &end,
+			&durationMs, &stage.Attempts, &errMsg, &history); err != nil {
+			return fmt.Errorf("failed to scan stage: %w", err)
+		}
+		if len(history) > 0 {
+			if err := json.Unmarshal(history, &stage.History); err != nil {
+				return fmt.Errorf("failed to unmarshal history of stage %s: %w", stage.Name, err)
+			}
+		}
+		stage.Start = start.Time
+		stage.End = end.Time
+		stage.Duration = time.Duration(durationMs) * time.Millisecond
+		stage.Error = errMsg.String
+
+		i, ok := index[checkResultID]
+		if !ok {
+			continue
+		}
+		results[i].Stages = append(results[i].Stages, stage)
+	}
+
+	return rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, ""},
+		{1, "?"},
+		{3, "?, ?, ?"},
+	}
+	for _, tt := range tests {
+		if got := placeholders(tt.n); got != tt.want {
+			t.Errorf("placeholders(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestNullTime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		t     time.Time
+		valid bool
+	}{
+		{name: "set", t: now, valid: true},
+		{name: "zero", t: time.Time{}, valid: false},
+	}
+	for _, tt := range tests {
+		got := nullTime(tt.t)
+		if got.Valid != tt.valid || !got.Time.Equal(tt.t) {
+			t.Errorf("nullTime(%s) = %+v, want valid %v", tt.name, got, tt.valid)
+		}
+	}
+}
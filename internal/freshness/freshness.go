@@ -0,0 +1,132 @@
+package freshness
+
+import (
+	"sort"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+)
+
+// DefaultBranch is followed by nightly builds of components the registry doesn't know
+const DefaultBranch = "master"
+
+// ComponentFreshness compares the commit a component was built from with the branch head.
+type ComponentFreshness struct {
+	Component      string    `json:"component"`
+	GitHash        string    `json:"git_hash"`
+	CommitTime     time.Time `json:"commit_time"`
+	Branch         string    `json:"branch"`
+	HeadHash       string    `json:"head_hash,omitempty"`
+	HeadCommitTime time.Time `json:"head_commit_time,omitempty"`
+	// LagHours is how long the branch head is ahead of the built commit,
+	// nil when either commit time is unknown
+	LagHours *float64 `json:"lag_hours"`
+	Stale    bool     `json:"stale"`
+}
+
+// Result is the freshness of all components of one check report.
+type Result struct {
+	Platform       string               `json:"platform"`
+	Mirror         string               `json:"mirror,omitempty"`
+	Channel        string               `json:"channel,omitempty"`
+	Timestamp      time.Time            `json:"timestamp"`
+	Stale          bool                 `json:"stale"`
+	ThresholdHours float64              `json:"threshold_hours"`
+	Components     []ComponentFreshness `json:"components"`
+}
+
+type Evaluator struct {
+	threshold  time.Duration
+	components *component.Registry
+}
+
+// NewEvaluator returns an Evaluator comparing each component against the
+// head of its default branch in the registry.
+func NewEvaluator(threshold time.Duration, components *component.Registry) *Evaluator {
+	return &Evaluator{
+		threshold:  threshold,
+		components: components,
+	}
+}
+
+// Branch returns the branch a nightly build of the component follows.
+func (e *Evaluator) Branch(name string) string {
+	if comp, ok := e.components.Get(name); ok {
+		return comp.DefaultBranch
+	}
+	return DefaultBranch
+}
+
+// Applies reports whether the report is a nightly build, the only channel
+// expected to follow the branch heads.
+func (e *Evaluator) Applies(report *checker.CheckReport) bool {
+	return report.Channel == "" || report.Channel == "nightly"
+}
+
+// Evaluate computes per component how far the report lags behind the head
+// of its branch, heads of other branches are ignored. A report is stale when
+// any component lags more than the threshold.
+func (e *Evaluator) Evaluate(report *checker.CheckReport, heads []checker.BranchCommitInfo) Result {
+	headByComponent := make(map[string]checker.BranchCommitInfo, len(heads))
+	for _, h := range heads {
+		if h.Branch == e.Branch(h.Component) {
+			headByComponent[h.Component] = h
+		}
+	}
+
+	result := Result{
+		Platform:       report.Platform,
+		Mirror:         report.Mirror,
+		Channel:        report.Channel,
+		Timestamp:      report.Timestamp,
+		ThresholdHours: e.threshold.Hours(),
+		Components:     []ComponentFreshness{},
+	}
+
+	for _, name := range sortedComponents(report.Version.Components) {
+		comp := report.Version.Components[name]
+		cf := ComponentFreshness{
+			Component:  name,
+			GitHash:    comp.GitHash,
+			CommitTime: comp.CommitTime,
+			Branch:     e.Branch(name),
+		}
+
+		if head, ok := headByComponent[name]; ok {
+			cf.HeadHash = head.GitHash
+			cf.HeadCommitTime = head.CommitTime
+
+			var lag time.Duration
+			switch {
+			case head.GitHash == comp.GitHash:
+				lag = 0
+			case comp.CommitTime.IsZero() || head.CommitTime.IsZero():
+				lag = -1
+			case head.CommitTime.After(comp.CommitTime):
+				lag = head.CommitTime.Sub(comp.CommitTime)
+			}
+			if lag >= 0 {
+				hours := lag.Hours()
+				cf.LagHours = &hours
+				cf.Stale = lag > e.threshold
+			}
+		}
+
+		if cf.Stale {
+			result.Stale = true
+		}
+		result.Components = append(result.Components, cf)
+	}
+
+	return result
+}
+
+func sortedComponents(m map[string]checker.ComponentVersion) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
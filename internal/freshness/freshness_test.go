@@ -0,0 +1,151 @@
+package freshness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+)
+
+// testRegistry has tikv follow main instead of master
+func testRegistry(t *testing.T) *component.Registry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "components.yaml")
+	yaml := "components:\n  - name: tikv\n    repo: tikv/tikv\n    default_branch: main\n    role: tikv\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := component.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestBranch(t *testing.T) {
+	e := NewEvaluator(48*time.Hour, testRegistry(t))
+	tests := map[string]string{
+		"tidb":    "master",
+		"tikv":    "main",
+		"unknown": DefaultBranch,
+	}
+	for name, want := range tests {
+		if got := e.Branch(name); got != want {
+			t.Errorf("Branch(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	e := NewEvaluator(48*time.Hour, component.Default())
+	tests := map[string]bool{"": true, "nightly": true, "v8.5.0": false, "stable": false}
+	for channel, want := range tests {
+		if got := e.Applies(&checker.CheckReport{Channel: channel}); got != want {
+			t.Errorf("Applies(%q) = %v, want %v", channel, got, want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	hours := func(h float64) *float64 { return &h }
+	e := NewEvaluator(48*time.Hour, testRegistry(t))
+
+	tests := []struct {
+		name      string
+		built     checker.ComponentVersion
+		heads     []checker.BranchCommitInfo
+		wantHead  string
+		wantLag   *float64
+		wantStale bool
+	}{
+		{
+			name:     "at head",
+			built:    checker.ComponentVersion{GitHash: "a", CommitTime: now},
+			heads:    []checker.BranchCommitInfo{{Component: "tidb", Branch: "master", GitHash: "a", CommitTime: now}},
+			wantHead: "a",
+			wantLag:  hours(0),
+		},
+		{
+			name:     "behind within threshold",
+			built:    checker.ComponentVersion{GitHash: "a", CommitTime: now.Add(-24 * time.Hour)},
+			heads:    []checker.BranchCommitInfo{{Component: "tidb", Branch: "master", GitHash: "b", CommitTime: now}},
+			wantHead: "b",
+			wantLag:  hours(24),
+		},
+		{
+			name:      "stale",
+			built:     checker.ComponentVersion{GitHash: "a", CommitTime: now.Add(-72 * time.Hour)},
+			heads:     []checker.BranchCommitInfo{{Component: "tidb", Branch: "master", GitHash: "b", CommitTime: now}},
+			wantHead:  "b",
+			wantLag:   hours(72),
+			wantStale: true,
+		},
+		{
+			name:     "built commit newer than the recorded head",
+			built:    checker.ComponentVersion{GitHash: "c", CommitTime: now},
+			heads:    []checker.BranchCommitInfo{{Component: "tidb", Branch: "master", GitHash: "b", CommitTime: now.Add(-time.Hour)}},
+			wantHead: "b",
+			wantLag:  hours(0),
+		},
+		{
+			name:     "unknown commit time",
+			built:    checker.ComponentVersion{GitHash: "a"},
+			heads:    []checker.BranchCommitInfo{{Component: "tidb", Branch: "master", GitHash: "b", CommitTime: now}},
+			wantHead: "b",
+		},
+		{
+			name:  "only other branches",
+			built: checker.ComponentVersion{GitHash: "a", CommitTime: now.Add(-72 * time.Hour)},
+			heads: []checker.BranchCommitInfo{{Component: "tidb", Branch: "release-8.5", GitHash: "b", CommitTime: now}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &checker.CheckReport{
+				Platform:  "linux-amd64",
+				Timestamp: now,
+				Version:   checker.Versions{Components: map[string]checker.ComponentVersion{"tidb": tt.built}},
+			}
+			result := e.Evaluate(report, tt.heads)
+			if len(result.Components) != 1 {
+				t.Fatalf("Evaluate returned %d components", len(result.Components))
+			}
+			cf := result.Components[0]
+			if cf.Branch != "master" || cf.HeadHash != tt.wantHead || cf.Stale != tt.wantStale || result.Stale != tt.wantStale {
+				t.Errorf("Evaluate = %+v, want head %q stale %v", cf, tt.wantHead, tt.wantStale)
+			}
+			if (cf.LagHours == nil) != (tt.wantLag == nil) || (cf.LagHours != nil && *cf.LagHours != *tt.wantLag) {
+				t.Errorf("lag = %v, want %v", cf.LagHours, tt.wantLag)
+			}
+			if result.ThresholdHours != 48 || result.Platform != "linux-amd64" {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestEvaluateDefaultBranch(t *testing.T) {
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	report := &checker.CheckReport{Version: checker.Versions{Components: map[string]checker.ComponentVersion{
+		"tikv": {GitHash: "a", CommitTime: now.Add(-72 * time.Hour)},
+		"pd":   {GitHash: "p", CommitTime: now},
+	}}}
+	heads := []checker.BranchCommitInfo{
+		{Component: "tikv", Branch: "master", GitHash: "old", CommitTime: now.Add(-73 * time.Hour)},
+		{Component: "tikv", Branch: "main", GitHash: "b", CommitTime: now},
+		{Component: "pd", Branch: "master", GitHash: "p", CommitTime: now},
+	}
+
+	result := NewEvaluator(48*time.Hour, testRegistry(t)).Evaluate(report, heads)
+	if len(result.Components) != 2 || result.Components[0].Component != "pd" || result.Components[1].Component != "tikv" {
+		t.Fatalf("components = %+v, want pd and tikv in order", result.Components)
+	}
+	tikv := result.Components[1]
+	if tikv.Branch != "main" || tikv.HeadHash != "b" || !tikv.Stale || !result.Stale {
+		t.Errorf("tikv = %+v, want stale against main", tikv)
+	}
+}
@@ -0,0 +1,88 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// commitCache maps "repo@hash" to the commit time. Commits are immutable,
+// so entries never expire.
+type commitCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func newCommitCache(path string) *commitCache {
+	cc := &commitCache{
+		path:    path,
+		entries: make(map[string]time.Time),
+	}
+	if path == "" {
+		return cc
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error(fmt.Sprintf("Failed to read GitHub commit cache %s: %v", path, err))
+		}
+		return cc
+	}
+	if err := json.Unmarshal(data, &cc.entries); err != nil {
+		logger.Error(fmt.Sprintf("Ignoring corrupt GitHub commit cache %s: %v", path, err))
+		cc.entries = make(map[string]time.Time)
+	}
+	return cc
+}
+
+func (cc *commitCache) get(repo, hash string) (time.Time, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	t, ok := cc.entries[repo+"@"+hash]
+	return t, ok
+}
+
+func (cc *commitCache) put(repo, hash string, t time.Time) {
+	if !fullHashPattern.MatchString(hash) {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	key := repo + "@" + hash
+	if _, ok := cc.entries[key]; ok {
+		return
+	}
+	cc.entries[key] = t
+
+	if err := cc.save(); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write GitHub commit cache %s: %v", cc.path, err))
+	}
+}
+
+// save writes the cache atomically, the caller holds mu
+func (cc *commitCache) save() error {
+	if cc.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(cc.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cc.path), 0755); err != nil {
+		return err
+	}
+	tmp := cc.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cc.path)
+}
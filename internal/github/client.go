@@ -0,0 +1,404 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const DefaultBaseURL = "https://api.github.com"
+
+const (
+	defaultMaxRetries       = 3
+	defaultMaxRateLimitWait = 5 * time.Minute
+	defaultMaxCached        = 1000
+	initialRetryBackoff     = time.Second
+)
+
+// ErrNotFound is returned when GitHub answers 404, e.g. for a missing branch.
+var ErrNotFound = errors.New("GitHub resource not found")
+
+// ErrRateLimited is returned when the rate limit is exhausted and waiting for
+// the reset would take longer than allowed.
+var ErrRateLimited = errors.New("GitHub API rate limit exceeded")
+
+var fullHashPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+type Options struct {
+	// BaseURL of the API, DefaultBaseURL if empty. Point it at a
+	// githubfake.Server to run without network access.
+	BaseURL string
+	Token   string
+	// HTTPClient defaults to a client with a 30s timeout
+	HTTPClient *http.Client
+	// CachePath persists commit metadata between runs, memory only if empty
+	CachePath string
+	// MaxRetries for network errors, 5xx responses and rate limiting
+	MaxRetries int
+	// MaxRateLimitWait caps how long a request waits for the rate limit to reset
+	MaxRateLimitWait time.Duration
+	// MaxCachedResponses bounds the ETag cache, the oldest entries are evicted
+	MaxCachedResponses int
+}
+
+// Client is a small GitHub REST API client. Responses are cached by ETag so
+// repeated polling costs no rate limit, and commit times are cached by hash
+// because a commit never changes.
+type Client struct {
+	baseURL    string
+	token      string
+	http       *http.Client
+	maxRetries int
+	maxWait    time.Duration
+	maxCached  int
+
+	mu        sync.Mutex
+	responses map[string]cachedResponse
+	stored    uint64
+	rateLimit RateLimit
+	commits   *commitCache
+}
+
+type cachedResponse struct {
+	etag string
+	body []byte
+	// seq orders entries for eviction
+	seq uint64
+}
+
+// RateLimit is the quota reported by the last response.
+type RateLimit struct {
+	Remaining int
+	Reset     time.Time
+	// Known is false until a response carried the rate limit headers
+	Known bool
+}
+
+// Commit is the part of a GitHub commit the checker cares about.
+type Commit struct {
+	SHA  string
+	Time time.Time
+}
+
+// FromConfig builds the client shared by the checker and the updater.
+func FromConfig(cfg *config.Config) *Client {
+	return NewClient(Options{
+		BaseURL:   cfg.GitHubAPIURL,
+		Token:     cfg.GitHubToken,
+		CachePath: cfg.GitHubCachePath,
+	})
+}
+
+func NewClient(opts Options) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(opts.BaseURL, "/"),
+		token:      opts.Token,
+		http:       opts.HTTPClient,
+		maxRetries: opts.MaxRetries,
+		maxWait:    opts.MaxRateLimitWait,
+		maxCached:  opts.MaxCachedResponses,
+		responses:  make(map[string]cachedResponse),
+		commits:    newCommitCache(opts.CachePath),
+	}
+	if c.baseURL == "" {
+		c.baseURL = DefaultBaseURL
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: 30 * time.Second}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.maxWait == 0 {
+		c.maxWait = defaultMaxRateLimitWait
+	}
+	if c.maxCached == 0 {
+		c.maxCached = defaultMaxCached
+	}
+	if c.token == "" {
+		logger.Warn("GitHub token is empty, requests are subject to the anonymous rate limit")
+	}
+	return c
+}
+
+// GetCommit resolves a ref, a branch name or a commit hash, in repo ("owner/name").
+func (c *Client) GetCommit(ctx context.Context, repo, ref string) (*Commit, error) {
+	if fullHashPattern.MatchString(ref) {
+		if t, ok := c.commits.get(repo, ref); ok {
+			return &Commit{SHA: ref, Time: t}, nil
+		}
+	}
+
+	var result struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/commits/%s", repo, ref), &result); err != nil {
+		return nil, err
+	}
+
+	commit := &Commit{SHA: result.SHA, Time: result.Commit.Committer.Date}
+	c.commits.put(repo, commit.SHA, commit.Time)
+	return commit, nil
+}
+
+// Branch is a branch and the hash of its head commit.
+type Branch struct {
+	Name string
+	SHA  string
+}
+
+const branchesPerPage = 100
+
+// ListBranches returns every branch of repo.
+func (c *Client) ListBranches(ctx context.Context, repo string) ([]Branch, error) {
+	var branches []Branch
+	for page := 1; ; page++ {
+		var result []struct {
+			Name   string `json:"name"`
+			Commit struct {
+				SHA string `json:"sha"`
+			} `json:"commit"`
+		}
+		path := fmt.Sprintf("/repos/%s/branches?per_page=%d&page=%d", repo, branchesPerPage, page)
+		if err := c.get(ctx, path, &result); err != nil {
+			return nil, err
+		}
+		for _, b := range result {
+			branches = append(branches, Branch{Name: b.Name, SHA: b.Commit.SHA})
+		}
+		if len(result) < branchesPerPage {
+			return branches, nil
+		}
+	}
+}
+
+// Comparison describes how head relates to base, as reported by the compare API.
+type Comparison struct {
+	// Status is "identical", "ahead", "behind" or "diverged", from head's point of view
+	Status string
+	// AheadBy is the number of commits in head that are not in base
+	AheadBy  int
+	BehindBy int
+	Base     Commit
+}
+
+// Compare compares two refs of repo.
+func (c *Client) Compare(ctx context.Context, repo, base, head string) (*Comparison, error) {
+	var result struct {
+		Status     string `json:"status"`
+		AheadBy    int    `json:"ahead_by"`
+		BehindBy   int    `json:"behind_by"`
+		BaseCommit struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Committer struct {
+					Date time.Time `json:"date"`
+				} `json:"committer"`
+			} `json:"commit"`
+		} `json:"base_commit"`
+	}
+	// per_page=1 keeps GitHub from listing every commit in between
+	path := fmt.Sprintf("/repos/%s/compare/%s...%s?per_page=1", repo, base, head)
+	if err := c.get(ctx, path, &result); err != nil {
+		return nil, err
+	}
+
+	cmp := &Comparison{
+		Status:   result.Status,
+		AheadBy:  result.AheadBy,
+		BehindBy: result.BehindBy,
+		Base:     Commit{SHA: result.BaseCommit.SHA, Time: result.BaseCommit.Commit.Committer.Date},
+	}
+	c.commits.put(repo, cmp.Base.SHA, cmp.Base.Time)
+	return cmp, nil
+}
+
+// CommitTime returns the committer date of a commit.
+func (c *Client) CommitTime(ctx context.Context, repo, hash string) (time.Time, error) {
+	commit, err := c.GetCommit(ctx, repo, hash)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return commit.Time, nil
+}
+
+// RateLimit returns the quota left as of the last response.
+func (c *Client) RateLimit() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+// get fetches path and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	body, err := c.do(ctx, c.baseURL+path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (c *Client) do(ctx context.Context, url string) ([]byte, error) {
+	backoff := initialRetryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			logger.Info(fmt.Sprintf("Retrying GitHub request %s (attempt %d): %v", url, attempt+1, lastErr))
+		}
+
+		body, wait, err := c.doOnce(ctx, url)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if wait < 0 {
+			return nil, err
+		}
+		if wait == 0 {
+			// jitter keeps parallel checkers from retrying in lockstep
+			wait = backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+			backoff *= 2
+		}
+		if wait > c.maxWait {
+			return nil, fmt.Errorf("%w (retry would wait %s)", err, wait.Round(time.Second))
+		}
+		// a caller with a deadline gets the error now rather than at the deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, fmt.Errorf("%w (retry would wait %s, past the deadline)", err, wait.Round(time.Second))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil, lastErr
+}
+
+// doOnce performs one request. On failure wait tells the caller what to do:
+// negative means do not retry, zero means retry with backoff, positive means
+// retry after that duration.
+func (c *Client) doOnce(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	c.mu.Lock()
+	cached, hasCached := c.responses[url]
+	c.mu.Unlock()
+	if hasCached {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.recordRateLimit(resp.Header)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		return cached.body, 0, nil
+	case resp.StatusCode == http.StatusOK:
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			c.cacheResponse(url, etag, body)
+		}
+		return body, 0, nil
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		if wait, limited := rateLimitWait(resp.Header); limited {
+			logger.Warn(fmt.Sprintf("GitHub rate limit exceeded, resets in %s", wait.Round(time.Second)))
+			return nil, wait, ErrRateLimited
+		}
+	case resp.StatusCode == http.StatusNotFound:
+		// not logged here, a missing branch is for the caller to judge
+		return nil, -1, fmt.Errorf("%w: %s", ErrNotFound, url)
+	case resp.StatusCode >= 500:
+		return nil, 0, fmt.Errorf("GitHub API returned status: %d", resp.StatusCode)
+	}
+
+	logger.Error(fmt.Sprintf("GitHub API error - Status: %d, Response: %s", resp.StatusCode, string(body)))
+	return nil, -1, fmt.Errorf("GitHub API returned status: %d, body: %s", resp.StatusCode, string(body))
+}
+
+// cacheResponse stores a response by URL, evicting the oldest entry when
+// the cache is full.
+func (c *Client) cacheResponse(url, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.responses[url]; !ok && len(c.responses) >= c.maxCached {
+		oldest := ""
+		for key, r := range c.responses {
+			if oldest == "" || r.seq < c.responses[oldest].seq {
+				oldest = key
+			}
+		}
+		delete(c.responses, oldest)
+	}
+	c.stored++
+	c.responses[url] = cachedResponse{etag: etag, body: body, seq: c.stored}
+}
+
+func (c *Client) recordRateLimit(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit := RateLimit{Remaining: remaining, Known: true}
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		limit.Reset = time.Unix(reset, 0)
+	}
+	c.mu.Lock()
+	c.rateLimit = limit
+	c.mu.Unlock()
+}
+
+// rateLimitWait reports whether the response was rate limited and how long
+// to wait before the limit resets.
+func rateLimitWait(h http.Header) (time.Duration, bool) {
+	if retryAfter := h.Get("Retry-After"); retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(secs)*time.Second + time.Second, true
+		}
+	}
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Minute, true
+	}
+	wait := time.Until(time.Unix(reset, 0)) + time.Second
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, true
+}
@@ -0,0 +1,410 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/github/githubfake"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	testRepo = "pingcap/tidb"
+	hashA    = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	hashB    = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	hashC    = "cccccccccccccccccccccccccccccccccccccccc"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "github-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(filepath.Join(dir, "test.log")); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// statusRecorder records the status codes the client received
+type statusRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+}
+
+func (r *statusRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		r.mu.Lock()
+		r.statuses = append(r.statuses, resp.StatusCode)
+		r.mu.Unlock()
+	}
+	return resp, err
+}
+
+func (r *statusRecorder) Statuses() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.statuses...)
+}
+
+func newFakeClient(t *testing.T, opts Options) (*Client, *githubfake.Server) {
+	t.Helper()
+	fake := githubfake.NewServer()
+	t.Cleanup(fake.Close)
+	opts.BaseURL = fake.URL
+	return NewClient(opts), fake
+}
+
+func TestGetCommit(t *testing.T) {
+	client, fake := newFakeClient(t, Options{})
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake.SetCommit(testRepo, "master", hashA, at)
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantSHA string
+		wantErr bool
+	}{
+		{name: "branch", ref: "master", wantSHA: hashA},
+		{name: "hash", ref: hashA, wantSHA: hashA},
+		{name: "unknown ref", ref: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit, err := client.GetCommit(context.Background(), testRepo, tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetCommit(%s) succeeded, want error", tt.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetCommit(%s): %v", tt.ref, err)
+			}
+			if commit.SHA != tt.wantSHA || !commit.Time.Equal(at) {
+				t.Errorf("GetCommit(%s) = %s at %s, want %s at %s", tt.ref, commit.SHA, commit.Time, tt.wantSHA, at)
+			}
+		})
+	}
+}
+
+func TestETagCaching(t *testing.T) {
+	recorder := &statusRecorder{}
+	client, fake := newFakeClient(t, Options{HTTPClient: &http.Client{Transport: recorder}})
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake.SetCommit(testRepo, "master", hashA, first)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		commit, err := client.GetCommit(ctx, testRepo, "master")
+		if err != nil {
+			t.Fatalf("GetCommit: %v", err)
+		}
+		if commit.SHA != hashA {
+			t.Fatalf("GetCommit = %s, want %s", commit.SHA, hashA)
+		}
+	}
+
+	// a moved branch changes the ETag, the new head must not come from the cache
+	fake.SetCommit(testRepo, "master", hashB, first.Add(time.Hour))
+	commit, err := client.GetCommit(ctx, testRepo, "master")
+	if err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	if commit.SHA != hashB {
+		t.Errorf("GetCommit after push = %s, want %s", commit.SHA, hashB)
+	}
+
+	want := []int{http.StatusOK, http.StatusNotModified, http.StatusOK}
+	if got := recorder.Statuses(); !equalInts(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	t.Run("waits for the reset", func(t *testing.T) {
+		client, fake := newFakeClient(t, Options{})
+		fake.SetCommit(testRepo, "master", hashA, time.Now())
+		fake.SetRateLimit(0, time.Now().Add(time.Second))
+
+		start := time.Now()
+		if _, err := client.GetCommit(context.Background(), testRepo, "master"); err != nil {
+			t.Fatalf("GetCommit: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+			t.Errorf("GetCommit returned after %s, before the rate limit reset", elapsed)
+		}
+		if got := fake.Requests(); got != 2 {
+			t.Errorf("requests = %d, want 2", got)
+		}
+	})
+
+	t.Run("gives up past the maximum wait", func(t *testing.T) {
+		client, fake := newFakeClient(t, Options{MaxRateLimitWait: time.Second})
+		fake.SetCommit(testRepo, "master", hashA, time.Now())
+		fake.SetRateLimit(0, time.Now().Add(time.Hour))
+
+		_, err := client.GetCommit(context.Background(), testRepo, "master")
+		if err == nil || !strings.Contains(err.Error(), "rate limit") {
+			t.Fatalf("GetCommit error = %v, want rate limit error", err)
+		}
+		if got := fake.Requests(); got != 1 {
+			t.Errorf("requests = %d, want 1", got)
+		}
+	})
+
+	t.Run("does not wait past the deadline", func(t *testing.T) {
+		client, fake := newFakeClient(t, Options{})
+		fake.SetCommit(testRepo, "master", hashA, time.Now())
+		fake.SetRateLimit(0, time.Now().Add(time.Minute))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		start := time.Now()
+		if _, err := client.GetCommit(ctx, testRepo, "master"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("GetCommit error = %v, want %v", err, ErrRateLimited)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("GetCommit returned after %s, want right away", elapsed)
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	if got := NewClient(Options{}).RateLimit(); got.Known {
+		t.Errorf("RateLimit before any request = %+v, want unknown", got)
+	}
+	tests := []struct {
+		name      string
+		remaining int
+		want      int
+		known     bool
+	}{
+		{name: "unlimited", remaining: -1},
+		{name: "plenty", remaining: 5000, want: 4999, known: true},
+		{name: "last request", remaining: 1, want: 0, known: true},
+	}
+	for _, tt := range tests {
+		client, fake := newFakeClient(t, Options{})
+		fake.SetCommit(testRepo, "master", hashA, time.Now())
+		fake.SetRateLimit(tt.remaining, time.Now().Add(time.Hour))
+		if _, err := client.GetCommit(context.Background(), testRepo, "master"); err != nil {
+			t.Fatalf("%s: GetCommit: %v", tt.name, err)
+		}
+		if got := client.RateLimit(); got.Known != tt.known || got.Remaining != tt.want {
+			t.Errorf("%s: RateLimit = %+v, want %d remaining", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheBounded(t *testing.T) {
+	recorder := &statusRecorder{}
+	client, fake := newFakeClient(t, Options{HTTPClient: &http.Client{Transport: recorder}, MaxCachedResponses: 2})
+	branches := []string{"master", "release-8.1", "release-8.5"}
+	for _, branch := range branches {
+		fake.SetCommit(testRepo, branch, hashA, time.Now())
+	}
+
+	ctx := context.Background()
+	for _, branch := range append(branches, "master", "release-8.5") {
+		if _, err := client.GetCommit(ctx, testRepo, branch); err != nil {
+			t.Fatalf("GetCommit(%s): %v", branch, err)
+		}
+	}
+	if len(client.responses) != 2 {
+		t.Errorf("cached %d responses, want 2", len(client.responses))
+	}
+	// master was evicted by release-8.5 and had to be fetched again
+	want := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotModified}
+	if got := recorder.Statuses(); !equalInts(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if _, ok := client.responses[fmt.Sprintf("%s/repos/%s/commits/master", client.baseURL, testRepo)]; !ok {
+		t.Errorf("refetched master is not cached")
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		wantLimited bool
+		min, max    time.Duration
+	}{
+		{name: "not limited", headers: map[string]string{"X-RateLimit-Remaining": "10"}},
+		{name: "no headers"},
+		{
+			name:        "retry after",
+			headers:     map[string]string{"Retry-After": "30"},
+			wantLimited: true, min: 31 * time.Second, max: 31 * time.Second,
+		},
+		{
+			name: "reset",
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10),
+			},
+			wantLimited: true, min: 59 * time.Second, max: 61 * time.Second,
+		},
+		{
+			name: "reset in the past",
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10),
+			},
+			wantLimited: true, min: time.Second, max: time.Second,
+		},
+		{
+			name:        "invalid reset",
+			headers:     map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "soon"},
+			wantLimited: true, min: time.Minute, max: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			wait, limited := rateLimitWait(h)
+			if limited != tt.wantLimited {
+				t.Fatalf("limited = %v, want %v", limited, tt.wantLimited)
+			}
+			if wait < tt.min || wait > tt.max {
+				t.Errorf("wait = %s, want between %s and %s", wait, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestCommitCachePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "commits.json")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	fake := githubfake.NewServer()
+	defer fake.Close()
+	fake.SetCommit(testRepo, "master", hashA, at)
+	fake.SetCommit(testRepo, "master", hashB, at.Add(time.Hour))
+
+	ctx := context.Background()
+	client := NewClient(Options{BaseURL: fake.URL, CachePath: path})
+	if _, err := client.GetCommit(ctx, testRepo, hashA); err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	// branch names move, only hashes are cached
+	if _, err := client.GetCommit(ctx, testRepo, "master"); err != nil {
+		t.Fatalf("GetCommit: %v", err)
+	}
+	requests := fake.Requests()
+
+	// a new client, as on the next run, reads the cache from disk
+	client = NewClient(Options{BaseURL: fake.URL, CachePath: path})
+	for _, hash := range []string{hashA, hashB} {
+		if _, err := client.GetCommit(ctx, testRepo, hash); err != nil {
+			t.Fatalf("GetCommit(%s): %v", hash, err)
+		}
+	}
+	if got := fake.Requests(); got != requests {
+		t.Errorf("cached commits made %d requests, want none", got-requests)
+	}
+
+	if _, err := client.GetCommit(ctx, "pingcap/tiflow", hashA); err == nil {
+		t.Errorf("GetCommit of another repo succeeded from the cache")
+	}
+}
+
+func TestCommitCacheCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commits.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := newCommitCache(path)
+	if _, ok := cc.get(testRepo, hashA); ok {
+		t.Fatalf("corrupt cache returned an entry")
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cc.put(testRepo, hashA, at)
+	cc.put(testRepo, "master", at)
+
+	got, ok := newCommitCache(path).get(testRepo, hashA)
+	if !ok || !got.Equal(at) {
+		t.Errorf("rewritten cache get = %s, %v, want %s", got, ok, at)
+	}
+	if _, ok := newCommitCache(path).get(testRepo, "master"); ok {
+		t.Errorf("cache kept a branch name")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	client, fake := newFakeClient(t, Options{})
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake.SetCommit(testRepo, "master", hashA, at)
+	fake.SetCommit(testRepo, "master", hashB, at.Add(time.Hour))
+	fake.SetCommit(testRepo, "master", hashC, at.Add(2*time.Hour))
+
+	tests := []struct {
+		base, head string
+		status     string
+		ahead      int
+		behind     int
+	}{
+		{base: hashA, head: hashC, status: "ahead", ahead: 2},
+		{base: hashC, head: hashA, status: "behind", behind: 2},
+		{base: hashB, head: hashB, status: "identical"},
+	}
+	for _, tt := range tests {
+		cmp, err := client.Compare(context.Background(), testRepo, tt.base, tt.head)
+		if err != nil {
+			t.Fatalf("Compare(%.7s, %.7s): %v", tt.base, tt.head, err)
+		}
+		if cmp.Status != tt.status || cmp.AheadBy != tt.ahead || cmp.BehindBy != tt.behind || cmp.Base.SHA != tt.base {
+			t.Errorf("Compare(%.7s, %.7s) = %+v, want %s ahead %d behind %d", tt.base, tt.head, cmp, tt.status, tt.ahead, tt.behind)
+		}
+	}
+}
+
+func TestListBranchesPaginates(t *testing.T) {
+	client, fake := newFakeClient(t, Options{})
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	want := branchesPerPage + 5
+	for i := 0; i < want; i++ {
+		fake.SetCommit(testRepo, "release-"+strconv.Itoa(i), hashA, at)
+	}
+
+	branches, err := client.ListBranches(context.Background(), testRepo)
+	if err != nil {
+		t.Fatalf("ListBranches: %v", err)
+	}
+	if len(branches) != want {
+		t.Errorf("ListBranches returned %d branches, want %d", len(branches), want)
+	}
+	if got := fake.Requests(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,251 @@
+// Package githubfake is an in-process stand-in for the GitHub REST API,
+// so that code using the github client can run without network access.
+package githubfake
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type commit struct {
+	sha  string
+	time time.Time
+}
+
+// Server serves the subset of the GitHub API used by the checker and updater.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	refs     map[string]map[string]commit   // repo -> branch or sha -> commit
+	branches map[string]map[string][]string // repo -> branch -> heads, oldest first
+	requests int
+
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+}
+
+// NewServer starts a fake API server, close it with Close.
+func NewServer() *Server {
+	s := &Server{
+		refs:               make(map[string]map[string]commit),
+		branches:           make(map[string]map[string][]string),
+		rateLimitRemaining: -1,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// SetCommit registers a commit and makes it the head of branch, if branch is
+// not empty. Commits set on a branch form its linear history for compare.
+func (s *Server) SetCommit(repo, branch, sha string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs[repo] == nil {
+		s.refs[repo] = make(map[string]commit)
+	}
+	c := commit{sha: sha, time: t.UTC()}
+	s.refs[repo][sha] = c
+	if branch != "" {
+		s.refs[repo][branch] = c
+		if s.branches[repo] == nil {
+			s.branches[repo] = make(map[string][]string)
+		}
+		s.branches[repo][branch] = append(s.branches[repo][branch], sha)
+	}
+}
+
+// SetRateLimit makes the server report remaining requests; when it reaches
+// zero requests fail with 403 until reset. A negative value disables limiting.
+func (s *Server) SetRateLimit(remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimitRemaining = remaining
+	s.rateLimitReset = reset
+}
+
+// Requests returns how many requests reached the server.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if s.rateLimitRemaining >= 0 {
+		if s.rateLimitRemaining == 0 && time.Now().Before(s.rateLimitReset) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.rateLimitReset.Unix(), 10))
+			writeJSON(w, http.StatusForbidden, map[string]string{"message": "API rate limit exceeded"})
+			return
+		}
+		if s.rateLimitRemaining > 0 {
+			s.rateLimitRemaining--
+		}
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.rateLimitRemaining))
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method != http.MethodGet || len(parts) < 4 || parts[0] != "repos":
+	case len(parts) == 5 && parts[3] == "commits":
+		s.handleCommit(w, r, parts[1]+"/"+parts[2], parts[4])
+		return
+	case len(parts) == 5 && parts[3] == "compare":
+		s.handleCompare(w, r, parts[1]+"/"+parts[2], parts[4])
+		return
+	case len(parts) == 4 && parts[3] == "branches":
+		s.handleBranches(w, r, parts[1]+"/"+parts[2])
+		return
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+}
+
+// handleCommit serves /repos/{owner}/{name}/commits/{ref}
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	c, ok := s.refs[repo][ref]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "No commit found for SHA: " + ref})
+		return
+	}
+
+	writeCached(w, r, commitJSON(c))
+}
+
+// handleCompare serves /repos/{owner}/{name}/compare/{base}...{head}. Both
+// refs must resolve to commits on the history of one branch.
+func (s *Server) handleCompare(w http.ResponseWriter, r *http.Request, repo, spec string) {
+	baseRef, headRef, ok := strings.Cut(spec, "...")
+	base, baseOK := s.refs[repo][baseRef]
+	head, headOK := s.refs[repo][headRef]
+	if !ok || !baseOK || !headOK {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+
+	for _, history := range s.branches[repo] {
+		bi, hi := indexOf(history, base.sha), indexOf(history, head.sha)
+		if bi < 0 || hi < 0 {
+			continue
+		}
+		body := map[string]interface{}{
+			"base_commit": commitJSON(base),
+			"ahead_by":    0,
+			"behind_by":   0,
+		}
+		switch {
+		case hi == bi:
+			body["status"] = "identical"
+		case hi > bi:
+			body["status"] = "ahead"
+			body["ahead_by"] = hi - bi
+		default:
+			body["status"] = "behind"
+			body["behind_by"] = bi - hi
+		}
+		writeCached(w, r, body)
+		return
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "No common ancestor between " + baseRef + " and " + headRef + "."})
+}
+
+func indexOf(history []string, sha string) int {
+	for i, h := range history {
+		if h == sha {
+			return i
+		}
+	}
+	return -1
+}
+
+func commitJSON(c commit) map[string]interface{} {
+	return map[string]interface{}{
+		"sha": c.sha,
+		"commit": map[string]interface{}{
+			"committer": map[string]interface{}{
+				"date": c.time.Format(time.RFC3339),
+			},
+		},
+	}
+}
+
+// handleBranches serves /repos/{owner}/{name}/branches, paginated by the
+// per_page and page query parameters.
+func (s *Server) handleBranches(w http.ResponseWriter, r *http.Request, repo string) {
+	if _, ok := s.refs[repo]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return
+	}
+
+	names := make([]string, 0, len(s.branches[repo]))
+	for name := range s.branches[repo] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	start := (page - 1) * perPage
+	if start > len(names) {
+		start = len(names)
+	}
+	end := start + perPage
+	if end > len(names) {
+		end = len(names)
+	}
+
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, name := range names[start:end] {
+		body = append(body, map[string]interface{}{
+			"name":   name,
+			"commit": map[string]string{"sha": s.refs[repo][name].sha},
+		})
+	}
+	writeCached(w, r, body)
+}
+
+// writeCached answers with an ETag and honours If-None-Match like GitHub does.
+func writeCached(w http.ResponseWriter, r *http.Request, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		return
+	}
+	sum := sha1.Sum(data)
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:]))
+
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -6,17 +6,22 @@ import (
     "fmt"
     "net/http"
     "os"
+    "strings"
     "time"
 )
 
 const (
     EnvFeishuSuccessWebhook = "FEISHU_SUCCESS_WEBHOOK"
     EnvFeishuFailureWebhook = "FEISHU_FAILURE_WEBHOOK"
+    // EnvFeishuStageOwners maps error stages to the owning team,
+    // e.g. "tiflash=TiFlash team,download=TiUP team"
+    EnvFeishuStageOwners = "FEISHU_STAGE_OWNERS"
 )
 
 type Notifier struct {
     successWebhook string
     failureWebhook string
+    stageOwners    map[string]string
 }
 
 type Message struct {
@@ -30,10 +35,21 @@ func NewNotifier() *Notifier {
     return &Notifier{
         successWebhook: os.Getenv(EnvFeishuSuccessWebhook),
         failureWebhook: os.Getenv(EnvFeishuFailureWebhook),
+        stageOwners:    parseStageOwners(os.Getenv(EnvFeishuStageOwners)),
     }
 }
 
-func (n *Notifier) SendSuccessNotification(platform string, version string) error {
+func parseStageOwners(value string) map[string]string {
+    owners := make(map[string]string)
+    for _, item := range strings.Split(value, ",") {
+        if stage, owner, ok := strings.Cut(item, "="); ok {
+            owners[strings.TrimSpace(stage)] = strings.TrimSpace(owner)
+        }
+    }
+    return owners
+}
+
+func (n *Notifier) SendSuccessNotification(platform string, version string, run RunDetail) error {
     if n.successWebhook == "" {
         return nil
     }
@@ -43,43 +59,164 @@ func (n *Notifier) SendSuccessNotification(platform string, version string) erro
         Content: struct {
             Text string `json:"text"`
         }{
-            Text: fmt.Sprintf("✅ TiUP Nightly Check Success\nPlatform: %s\nTiUP Version: %s\nTime: %s", 
+            Text: fmt.Sprintf("✅ TiUP Nightly Check Success\nPlatform: %s\nTiUP Version: %s\nTime: %s%s", 
                 platform,
                 version, 
-                time.Now().Format(time.RFC3339)),
+                time.Now().Format(time.RFC3339),
+                formatRun(run)),
         },
     }
     return n.send(n.successWebhook, msg)
 }
 
-func (n *Notifier) SendFailureNotification(platform string, version string, errors []ErrorDetail) error {
+func (n *Notifier) SendFailureNotification(platform string, version string, errors []ErrorDetail, run RunDetail) error {
     if n.failureWebhook == "" {
         return nil
     }
     
+    msg := Message{
+        MsgType: "text",
+        Content: struct {
+            Text string `json:"text"`
+        }{
+            Text: fmt.Sprintf("❌ TiUP Nightly Check Failed\nPlatform: %s\nTiUP Version: %s\nTime: %s%s\nErrors:%s", 
+                platform,
+                version, 
+                time.Now().Format(time.RFC3339),
+                formatRun(run),
+                n.formatErrors(errors)),
+        },
+    }
+    return n.send(n.failureWebhook, msg)
+}
+
+// SendEnvironmentNotification reports a run that failed because of the host
+// running the checker, not because of the nightly build.
+func (n *Notifier) SendEnvironmentNotification(platform string, version string, errors []ErrorDetail, run RunDetail) error {
+    if n.failureWebhook == "" {
+        return nil
+    }
+
+    msg := Message{
+        MsgType: "text",
+        Content: struct {
+            Text string `json:"text"`
+        }{
+            Text: fmt.Sprintf("⚠️ TiUP Nightly Check Environment Problem (not a build regression)\nPlatform: %s\nTiUP Version: %s\nTime: %s%s\nErrors:%s",
+                platform,
+                version,
+                time.Now().Format(time.RFC3339),
+                formatRun(run),
+                n.formatErrors(errors)),
+        },
+    }
+    return n.send(n.failureWebhook, msg)
+}
+
+// SendFlakyNotification reports a run that passed only after retrying, on the
+// success webhook since there is nothing to fix right away.
+func (n *Notifier) SendFlakyNotification(platform string, version string, retried []ErrorDetail, run RunDetail) error {
+    if n.successWebhook == "" {
+        return nil
+    }
+
+    msg := Message{
+        MsgType: "text",
+        Content: struct {
+            Text string `json:"text"`
+        }{
+            Text: fmt.Sprintf("🔁 TiUP Nightly Check Flaky (passed after retry)\nPlatform: %s\nTiUP Version: %s\nTime: %s%s\nRetried errors:%s",
+                platform,
+                version,
+                time.Now().Format(time.RFC3339),
+                formatRun(run),
+                n.formatErrors(retried)),
+        },
+    }
+    return n.send(n.successWebhook, msg)
+}
+
+func (n *Notifier) formatErrors(errors []ErrorDetail) string {
     var errorText string
     for _, err := range errors {
         errorText += fmt.Sprintf("\n- [%s] %s (at %s)", 
             err.Stage, 
             err.Error,
             err.Timestamp)
+        if err.Category != "" {
+            errorText += fmt.Sprintf(" category: %s", err.Category)
+        }
+        if err.Attempt > 0 {
+            errorText += fmt.Sprintf(" attempt: %d", err.Attempt)
+        }
+        if owner := n.stageOwners[err.Stage]; owner != "" {
+            errorText += fmt.Sprintf(" owner: %s", owner)
+        }
     }
-    
+    return errorText
+}
+
+// SendStaleNotification alerts that a platform still runs nightly components
+// built from commits far behind their branch head.
+func (n *Notifier) SendStaleNotification(platform string, thresholdHours float64, lags []ComponentLag, run RunDetail) error {
+    if n.failureWebhook == "" {
+        return nil
+    }
+
+    var lagText string
+    for _, lag := range lags {
+        lagText += fmt.Sprintf("\n- %s: %s is %.1fh behind %s head %s",
+            lag.Component,
+            shortHash(lag.GitHash),
+            lag.LagHours,
+            lag.Branch,
+            shortHash(lag.HeadHash))
+    }
+
     msg := Message{
         MsgType: "text",
         Content: struct {
             Text string `json:"text"`
         }{
-            Text: fmt.Sprintf("❌ TiUP Nightly Check Failed\nPlatform: %s\nTiUP Version: %s\nTime: %s\nErrors:%s", 
+            Text: fmt.Sprintf("⚠️ TiUP Nightly Is Stale\nPlatform: %s\nThreshold: %.0fh\nTime: %s%s\nComponents:%s",
                 platform,
-                version, 
+                thresholdHours,
                 time.Now().Format(time.RFC3339),
-                errorText),
+                formatRun(run),
+                lagText),
         },
     }
     return n.send(n.failureWebhook, msg)
 }
 
+// formatRun renders the lines identifying a run, nothing for an unknown run.
+func formatRun(run RunDetail) string {
+    if run.ID == "" {
+        return ""
+    }
+    text := fmt.Sprintf("\nRun: %s", run.ID)
+    if run.URL != "" {
+        text += fmt.Sprintf("\nResult: %s", run.URL)
+    }
+    if run.Hostname != "" {
+        text += fmt.Sprintf("\nHost: %s", run.Hostname)
+    }
+    if run.Trigger != "" {
+        text += fmt.Sprintf("\nTrigger: %s", run.Trigger)
+    }
+    if run.CIJobURL != "" {
+        text += fmt.Sprintf("\nCI Job: %s", run.CIJobURL)
+    }
+    return text
+}
+
+func shortHash(hash string) string {
+    if len(hash) > 10 {
+        return hash[:10]
+    }
+    return hash
+}
+
 func (n *Notifier) send(webhook string, msg Message) error {
     payload, err := json.Marshal(msg)
     if err != nil {
@@ -102,5 +239,26 @@ func (n *Notifier) send(webhook string, msg Message) error {
 type ErrorDetail struct {
     Stage     string
     Error     string
+    Category  string
+    // Attempt is the retried attempt the error belongs to, 0 if it failed the run
+    Attempt   int
     Timestamp time.Time
-}
\ No newline at end of file
+}
+
+// RunDetail identifies the run a message is about.
+type RunDetail struct {
+    ID       string
+    // URL is where the server serves the run's result
+    URL      string
+    Hostname string
+    Trigger  string
+    CIJobURL string
+}
+
+type ComponentLag struct {
+    Component string
+    Branch    string
+    GitHash   string
+    HeadHash  string
+    LagHours  float64
+}
@@ -0,0 +1,30 @@
+package notify
+
+import "testing"
+
+func TestFormatRun(t *testing.T) {
+	tests := []struct {
+		name string
+		run  RunDetail
+		want string
+	}{
+		{name: "unknown run", run: RunDetail{Hostname: "runner-1"}, want: ""},
+		{name: "id only", run: RunDetail{ID: "run-1"}, want: "\nRun: run-1"},
+		{
+			name: "all details",
+			run: RunDetail{
+				ID:       "run-1",
+				URL:      "https://checker.example.com/api/v1/runs/run-1",
+				Hostname: "runner-1",
+				Trigger:  "ci",
+				CIJobURL: "https://ci.example.com/job/1",
+			},
+			want: "\nRun: run-1\nResult: https://checker.example.com/api/v1/runs/run-1\nHost: runner-1\nTrigger: ci\nCI Job: https://ci.example.com/job/1",
+		},
+	}
+	for _, tt := range tests {
+		if got := formatRun(tt.run); got != tt.want {
+			t.Errorf("formatRun(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
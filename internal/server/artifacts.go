@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// MaxArtifactSize is the largest artifact accepted by UploadArtifact. An
+// artifact is stored in a single row, which must stay below TiDB's default
+// txn-entry-size-limit of 6 MiB.
+const MaxArtifactSize = 5 << 20
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+
+func parseResultID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.Error(NewError(http.StatusBadRequest, "Invalid result id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// readArtifactContent reads an uploaded artifact, refusing one over
+// MaxArtifactSize or an archive that is not gzip compressed.
+func readArtifactContent(c *gin.Context, name string) ([]byte, bool) {
+	content, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxArtifactSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.Error(NewError(http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Artifact exceeds %d bytes", MaxArtifactSize)))
+			return nil, false
+		}
+		c.Error(NewError(http.StatusBadRequest, "Failed to read artifact"))
+		return nil, false
+	}
+
+	// archives must at least be gzip, so the dashboard can offer them for download as is
+	if strings.HasSuffix(name, ".gz") && !bytes.HasPrefix(content, gzipMagic) {
+		c.Error(NewError(http.StatusBadRequest, "Artifact is not gzip compressed"))
+		return nil, false
+	}
+	return content, true
+}
+
+func (h *Handler) UploadArtifact(c *gin.Context) {
+	id, ok := parseResultID(c)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	if !artifactNamePattern.MatchString(name) {
+		c.Error(NewError(http.StatusBadRequest, "Invalid artifact name"))
+		return
+	}
+
+	platform, err := h.db.CheckResultPlatform(c.Request.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		c.Error(NewError(http.StatusNotFound, "Result not found"))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to check result existence:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to save artifact"))
+		return
+	}
+	if !allowsPlatform(c, platform) {
+		c.Error(NewError(http.StatusForbidden, "API key not allowed for platform "+platform))
+		return
+	}
+
+	content, ok := readArtifactContent(c, name)
+	if !ok {
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	if err := h.db.SaveArtifact(c.Request.Context(), id, name, contentType, content); err != nil {
+		logger.Error("Failed to save artifact:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to save artifact"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"size":   len(content),
+	})
+}
+
+func (h *Handler) ListArtifacts(c *gin.Context) {
+	id, ok := parseResultID(c)
+	if !ok {
+		return
+	}
+
+	artifacts, err := h.db.ListArtifacts(c.Request.Context(), id)
+	if err != nil {
+		logger.Error("Failed to list artifacts:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch artifacts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      id,
+		"total":   len(artifacts),
+		"results": artifacts,
+	})
+}
+
+func (h *Handler) GetArtifact(c *gin.Context) {
+	id, ok := parseResultID(c)
+	if !ok {
+		return
+	}
+
+	info, content, err := h.db.GetArtifact(c.Request.Context(), id, c.Param("name"))
+	if errors.Is(err, database.ErrNotFound) {
+		c.Error(NewError(http.StatusNotFound, "Artifact not found"))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get artifact:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch artifact"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name))
+	c.Data(http.StatusOK, info.ContentType, content)
+}
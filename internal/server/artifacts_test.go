@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestArtifactNamePattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "playground.log", valid: true},
+		{name: "component-logs.tar.gz", valid: true},
+		{name: "playground.attempt-1.log", valid: true},
+		{name: "A_b-9", valid: true},
+		{name: strings.Repeat("a", 255), valid: true},
+		{name: strings.Repeat("a", 256)},
+		{name: ""},
+		{name: ".hidden"},
+		{name: "-flag"},
+		{name: "../etc/passwd"},
+		{name: "dir/file"},
+		{name: "with space.log"},
+	}
+	for _, tt := range tests {
+		if got := artifactNamePattern.MatchString(tt.name); got != tt.valid {
+			t.Errorf("artifactNamePattern.MatchString(%q) = %v, want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestUploadArtifactValidation(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{}
+	engine.POST("/results/:id/artifacts/:name", h.UploadArtifact)
+
+	tests := []struct {
+		path    string
+		message string
+	}{
+		{path: "/results/abc/artifacts/playground.log", message: "Invalid result id"},
+		{path: "/results/0/artifacts/playground.log", message: "Invalid result id"},
+		{path: "/results/-1/artifacts/playground.log", message: "Invalid result id"},
+		{path: "/results/1/artifacts/.playground.log", message: "Invalid artifact name"},
+		{path: "/results/1/artifacts/a%20b", message: "Invalid artifact name"},
+	}
+	for _, tt := range tests {
+		w := serve(engine, httptest.NewRequest("POST", tt.path, strings.NewReader("log")))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("POST %s = %d %s, want 400 %q", tt.path, w.Code, w.Body.String(), tt.message)
+		}
+	}
+}
+
+func TestReadArtifactContent(t *testing.T) {
+	engine := newTestEngine()
+	var got int
+	engine.POST("/artifacts/:name", func(c *gin.Context) {
+		content, ok := readArtifactContent(c, c.Param("name"))
+		if ok {
+			got = len(content)
+			c.Status(http.StatusOK)
+		}
+	})
+
+	gzipped := append([]byte{0x1f, 0x8b}, bytes.Repeat([]byte("x"), MaxArtifactSize-2)...)
+	tests := []struct {
+		name string
+		body []byte
+		want int
+	}{
+		{name: "playground.log", body: []byte("log"), want: http.StatusOK},
+		{name: "playground.log", body: bytes.Repeat([]byte("x"), MaxArtifactSize), want: http.StatusOK},
+		{name: "playground.log", body: bytes.Repeat([]byte("x"), MaxArtifactSize+1), want: http.StatusRequestEntityTooLarge},
+		{name: "component-logs.tar.gz", body: gzipped, want: http.StatusOK},
+		{name: "component-logs.tar.gz", body: []byte("plain"), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		got = 0
+		w := serve(engine, httptest.NewRequest("POST", "/artifacts/"+tt.name, bytes.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("POST %s with %d bytes = %d %s, want %d", tt.name, len(tt.body), w.Code, w.Body.String(), tt.want)
+			continue
+		}
+		if tt.want == http.StatusOK && got != len(tt.body) {
+			t.Errorf("POST %s read %d bytes, want %d", tt.name, got, len(tt.body))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	// apiKeyContextKey stores the authenticated *auth.Key in the gin context
+	apiKeyContextKey = "api_key"
+	// signerContextKey stores the name of the runner that signed the body
+	signerContextKey = "signer"
+	// signatureContextKey stores the *checker.ReportSignature of the body
+	signatureContextKey = "signature"
+)
+
+// apiKeyAuth returns a middleware factory requiring an API key granting a
+// scope. With auth disabled every request passes.
+func apiKeyAuth(db *database.DB, disabled bool) func(scope string) gin.HandlerFunc {
+	if disabled {
+		logger.Warn("API key authentication is disabled, anyone can write results")
+		return func(scope string) gin.HandlerFunc {
+			return func(c *gin.Context) { c.Next() }
+		}
+	}
+
+	return func(scope string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			token := bearerToken(c.Request)
+			if token == "" {
+				c.Error(NewError(http.StatusUnauthorized, "Missing API key"))
+				c.Abort()
+				return
+			}
+
+			key, err := db.GetAPIKeyByHash(c.Request.Context(), auth.Hash(token))
+			if errors.Is(err, database.ErrNotFound) || (err == nil && key.Revoked()) {
+				c.Error(NewError(http.StatusUnauthorized, "Invalid API key"))
+				c.Abort()
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to look up API key:", err)
+				c.Error(NewError(http.StatusInternalServerError, "Failed to authenticate"))
+				c.Abort()
+				return
+			}
+			if !key.Allows(scope) {
+				c.Error(NewError(http.StatusForbidden, "API key lacks scope "+scope))
+				c.Abort()
+				return
+			}
+
+			if err := db.TouchAPIKey(c.Request.Context(), key.ID); err != nil {
+				logger.Warn("Failed to record API key use:", err)
+			}
+			c.Set(apiKeyContextKey, key)
+			c.Next()
+		}
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// allowsPlatform reports whether the request's API key may write results of
+// platform, always true with auth disabled
+func allowsPlatform(c *gin.Context, platform string) bool {
+	value, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return true
+	}
+	return value.(*auth.Key).AllowsPlatform(platform)
+}
+
+// verifySignature requires the body to be signed with the secret of the
+// request's API key within window of now. A replay inside the window resends
+// the same run, which SaveCheckResult stores only once. With auth disabled
+// there is no key and nothing is checked.
+func verifySignature(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(apiKeyContextKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		key := value.(*auth.Key)
+		if key.Secret == "" {
+			c.Error(NewError(http.StatusUnauthorized, "API key has no signing secret, issue a new one"))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(NewError(http.StatusBadRequest, "Failed to read request body"))
+			c.Abort()
+			return
+		}
+
+		timestamp := c.GetHeader(auth.TimestampHeader)
+		signature := c.GetHeader(auth.SignatureHeader)
+		canonical, err := auth.Verify(key.Secret, timestamp, signature, body, time.Now(), window)
+		if errors.Is(err, auth.ErrInvalidPayload) {
+			c.Error(NewError(http.StatusBadRequest, "Invalid request body: "+err.Error()))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			logger.Warn("Rejected report signature of "+key.Name+":", err)
+			c.Error(NewError(http.StatusUnauthorized, "Invalid report signature: "+err.Error()))
+			c.Abort()
+			return
+		}
+		signedAt, _ := auth.ParseTimestamp(timestamp)
+
+		// the handler decodes exactly what was signed
+		c.Request.Body = io.NopCloser(bytes.NewReader(canonical))
+		c.Set(signatureContextKey, &checker.ReportSignature{
+			KeyID:     key.ID,
+			Value:     signature,
+			Timestamp: signedAt,
+			Payload:   canonical,
+		})
+		c.Set(signerContextKey, key.Name)
+		c.Next()
+	}
+}
+
+// signer returns the runner that signed the request and its signature, empty
+// with auth disabled
+func signer(c *gin.Context) (string, *checker.ReportSignature) {
+	value, ok := c.Get(signatureContextKey)
+	if !ok {
+		return "", nil
+	}
+	return c.GetString(signerContextKey), value.(*checker.ReportSignature)
+}
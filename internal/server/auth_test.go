@@ -0,0 +1,158 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "Bearer tck_0123", want: "tck_0123"},
+		{header: "Bearer  tck_0123 ", want: "tck_0123"},
+		{header: "bearer tck_0123", want: ""},
+		{header: "Basic dXNlcjpwYXNz", want: ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/status", nil)
+		req.Header.Set("Authorization", tt.header)
+		if got := bearerToken(req); got != tt.want {
+			t.Errorf("bearerToken(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyAuthWithoutKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		disabled bool
+		header   string
+		want     int
+	}{
+		{name: "disabled", disabled: true, want: http.StatusOK},
+		{name: "missing key", want: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic dXNlcjpwYXNz", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		engine := newTestEngine()
+		// a missing key is refused before the database is asked
+		engine.POST("/status", apiKeyAuth(nil, tt.disabled)(auth.ScopeReport), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest("POST", "/status", strings.NewReader("{}"))
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		if w := serve(engine, req); w.Code != tt.want {
+			t.Errorf("%s: POST /status = %d %s, want %d", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestAllowsPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      *auth.Key
+		platform string
+		want     bool
+	}{
+		{name: "auth disabled", platform: "linux-amd64", want: true},
+		{name: "allowed", key: &auth.Key{Platforms: []string{"linux-amd64"}}, platform: "linux-amd64", want: true},
+		{name: "any platform", key: &auth.Key{Platforms: []string{auth.AllPlatforms}}, platform: "darwin-arm64", want: true},
+		{name: "other platform", key: &auth.Key{Platforms: []string{"linux-amd64"}}, platform: "darwin-arm64"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if tt.key != nil {
+			c.Set(apiKeyContextKey, tt.key)
+		}
+		if got := allowsPlatform(c, tt.platform); got != tt.want {
+			t.Errorf("%s: allowsPlatform(%s) = %v, want %v", tt.name, tt.platform, got, tt.want)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := &auth.Key{ID: 7, Name: "runner-1", Secret: "tcs_secret"}
+	body := `{"status": "success", "platform": "linux-amd64"}`
+	canonical := `{"platform":"linux-amd64","status":"success"}`
+	now := time.Now()
+	tests := []struct {
+		name     string
+		key      *auth.Key
+		body     string
+		signedAt time.Time
+		secret   string
+		want     int
+		wantBody string
+	}{
+		{name: "auth disabled", body: body, want: http.StatusOK, wantBody: body},
+		{name: "valid", key: key, body: body, signedAt: now, secret: key.Secret, want: http.StatusOK, wantBody: canonical},
+		{name: "key without secret", key: &auth.Key{Name: "old-runner"}, body: body, signedAt: now, secret: key.Secret, want: http.StatusUnauthorized},
+		{name: "replayed", key: key, body: body, signedAt: now.Add(-time.Hour), secret: key.Secret, want: http.StatusUnauthorized},
+		{name: "other secret", key: key, body: body, signedAt: now, secret: "tcs_other", want: http.StatusUnauthorized},
+		{name: "unsigned", key: key, body: body, want: http.StatusUnauthorized},
+		{name: "not json", key: key, body: `status=success`, signedAt: now, secret: key.Secret, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestEngine()
+			authenticate := func(c *gin.Context) {
+				if tt.key != nil {
+					c.Set(apiKeyContextKey, tt.key)
+				}
+			}
+			var gotSigner string
+			var gotSignature *checker.ReportSignature
+			engine.POST("/status", authenticate, verifySignature(5*time.Minute), func(c *gin.Context) {
+				gotSigner, gotSignature = signer(c)
+				data, _ := io.ReadAll(c.Request.Body)
+				c.String(http.StatusOK, "%s", data)
+			})
+
+			req := httptest.NewRequest("POST", "/status", strings.NewReader(tt.body))
+			if !tt.signedAt.IsZero() {
+				// the JSON check comes after the signature headers are read
+				signature := "sha256=00"
+				if sig, err := auth.Sign(tt.secret, tt.signedAt, []byte(tt.body)); err == nil {
+					signature = sig
+				}
+				req.Header.Set(auth.TimestampHeader, strconv.FormatInt(tt.signedAt.Unix(), 10))
+				req.Header.Set(auth.SignatureHeader, signature)
+			}
+			w := serve(engine, req)
+			if w.Code != tt.want {
+				t.Fatalf("POST /status = %d %s, want %d", w.Code, w.Body.String(), tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("handler read %s, want %s", w.Body.String(), tt.wantBody)
+			}
+			if tt.key == nil {
+				if gotSigner != "" || gotSignature != nil {
+					t.Errorf("signer = %q, %+v without auth", gotSigner, gotSignature)
+				}
+				return
+			}
+			if gotSigner != key.Name || gotSignature == nil || gotSignature.KeyID != key.ID ||
+				gotSignature.Timestamp.Unix() != tt.signedAt.Unix() || string(gotSignature.Payload) != canonical {
+				t.Errorf("signer = %q, %+v, want %s with the canonical report", gotSigner, gotSignature, key.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/internal/github"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+var gitHashPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+const (
+	// behindTimeout keeps the endpoint well within the server's write timeout,
+	// the GitHub client does not wait for a rate limit reset past it
+	behindTimeout = 5 * time.Second
+	// minGitHubQuota is left for the updater, which must keep polling heads
+	minGitHubQuota = 100
+)
+
+// CommitsBehind tells how far a git hash from a check report lags the branch head.
+type CommitsBehind struct {
+	Component string `json:"component"`
+	Branch    string `json:"branch"`
+	GitHash   string `json:"git_hash"`
+	HeadHash  string `json:"head_hash"`
+	// Status is "identical", "behind", "ahead" or "diverged" from GitHash's point of view
+	Status        string   `json:"status"`
+	CommitsBehind *int     `json:"commits_behind"`
+	HoursBehind   *float64 `json:"hours_behind"`
+	// Source is "history" when answered from recorded head changes, "github" otherwise
+	Source string `json:"source"`
+}
+
+// GetCommitsBehind answers GET /branch-commits/behind?component=&hash=&branch=
+func (h *Handler) GetCommitsBehind(c *gin.Context) {
+	component := c.Query("component")
+	comp, ok := h.components.Get(component)
+	if !ok {
+		c.Error(NewError(http.StatusBadRequest, "Invalid component"))
+		return
+	}
+
+	hash := strings.ToLower(c.Query("hash"))
+	if !gitHashPattern.MatchString(hash) {
+		c.Error(NewError(http.StatusBadRequest, "Invalid git hash"))
+		return
+	}
+
+	branch := c.DefaultQuery("branch", comp.DefaultBranch)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), behindTimeout)
+	defer cancel()
+	head, err := h.db.GetBranchCommit(ctx, component, branch)
+	if errors.Is(err, database.ErrNotFound) {
+		c.Error(NewError(http.StatusNotFound, "No head recorded for "+component+" "+branch))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get branch commit:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch branch commit"))
+		return
+	}
+
+	result := CommitsBehind{
+		Component: component,
+		Branch:    branch,
+		GitHash:   hash,
+		HeadHash:  head.GitHash,
+	}
+
+	if strings.HasPrefix(head.GitHash, hash) {
+		zero, zeroHours := 0, 0.0
+		result.Status = "identical"
+		result.CommitsBehind = &zero
+		result.HoursBehind = &zeroHours
+		result.Source = "history"
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// a former head with counted changes since needs no GitHub request
+	distance, err := h.db.DistanceFromHead(ctx, component, branch, hash)
+	if errors.Is(err, database.ErrAmbiguousHash) {
+		c.Error(NewError(http.StatusBadRequest, "Ambiguous git hash, use a longer prefix"))
+		return
+	}
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		logger.Error("Failed to look up branch history:", err)
+	}
+	if err == nil && distance.Commits != nil {
+		hours := head.CommitTime.Sub(distance.CommitTime).Hours()
+		result.GitHash = distance.GitHash
+		result.Status = "behind"
+		result.CommitsBehind = distance.Commits
+		result.HoursBehind = &hours
+		result.Source = "history"
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	if limit := h.github.RateLimit(); quotaLow(limit, time.Now()) {
+		logger.Warn("GitHub quota is low, not comparing commits for an anonymous request")
+		githubUnavailable(c, limit)
+		return
+	}
+	cmp, err := h.github.Compare(ctx, comp.Repo, hash, head.GitHash)
+	if errors.Is(err, github.ErrRateLimited) {
+		githubUnavailable(c, h.github.RateLimit())
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to compare commits:", err)
+		c.Error(NewError(http.StatusBadGateway, "Failed to compare commits on GitHub"))
+		return
+	}
+
+	// compare reports the head's side, swap it to the hash's side
+	switch cmp.Status {
+	case "ahead":
+		result.Status = "behind"
+	case "behind":
+		result.Status = "ahead"
+	default:
+		result.Status = cmp.Status
+	}
+	result.GitHash = cmp.Base.SHA
+	result.CommitsBehind = &cmp.AheadBy
+	hours := head.CommitTime.Sub(cmp.Base.Time).Hours()
+	result.HoursBehind = &hours
+	result.Source = "github"
+
+	c.JSON(http.StatusOK, result)
+}
+
+// quotaLow reports whether the GitHub quota is too low to spend on this
+// endpoint before it resets.
+func quotaLow(limit github.RateLimit, now time.Time) bool {
+	if !limit.Known || limit.Remaining >= minGitHubQuota {
+		return false
+	}
+	return limit.Reset.IsZero() || now.Before(limit.Reset)
+}
+
+func githubUnavailable(c *gin.Context, limit github.RateLimit) {
+	if wait := time.Until(limit.Reset); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	}
+	c.Error(NewError(http.StatusServiceUnavailable, "GitHub quota is low, try again later"))
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/github"
+)
+
+func TestGitHashPattern(t *testing.T) {
+	tests := []struct {
+		hash  string
+		valid bool
+	}{
+		{hash: "1feea49", valid: true},
+		{hash: "1feea49553ec8fd2e7a8c2e2c4f3b6d0a9e1f2c3", valid: true},
+		{hash: "1feea4"},
+		{hash: "1feea49553ec8fd2e7a8c2e2c4f3b6d0a9e1f2c3a"},
+		{hash: "1FEEA49"},
+		{hash: "master"},
+		{hash: "1feea49..HEAD"},
+	}
+	for _, tt := range tests {
+		if got := gitHashPattern.MatchString(tt.hash); got != tt.valid {
+			t.Errorf("gitHashPattern.MatchString(%q) = %v, want %v", tt.hash, got, tt.valid)
+		}
+	}
+}
+
+func TestGetCommitsBehindValidation(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{components: component.Default()}
+	engine.GET("/branch-commits/behind", h.GetCommitsBehind)
+
+	tests := []struct {
+		query   string
+		message string
+	}{
+		{query: "hash=1feea49", message: "Invalid component"},
+		{query: "component=ticdc&hash=1feea49", message: "Invalid component"},
+		{query: "component=tidb", message: "Invalid git hash"},
+		{query: "component=tidb&hash=master", message: "Invalid git hash"},
+		{query: "component=tidb&hash=1feea49..HEAD", message: "Invalid git hash"},
+	}
+	for _, tt := range tests {
+		w := serve(engine, httptest.NewRequest("GET", "/branch-commits/behind?"+tt.query, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("GET ?%s = %d %s, want 400 %q", tt.query, w.Code, w.Body.String(), tt.message)
+		}
+	}
+}
+
+func TestQuotaLow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		limit github.RateLimit
+		want  bool
+	}{
+		{name: "unknown", limit: github.RateLimit{}},
+		{name: "plenty", limit: github.RateLimit{Remaining: 4000, Reset: now.Add(time.Hour), Known: true}},
+		{name: "low", limit: github.RateLimit{Remaining: minGitHubQuota - 1, Reset: now.Add(time.Hour), Known: true}, want: true},
+		{name: "exhausted without reset", limit: github.RateLimit{Known: true}, want: true},
+		{name: "low but reset since", limit: github.RateLimit{Remaining: 0, Reset: now.Add(-time.Minute), Known: true}},
+	}
+	for _, tt := range tests {
+		if got := quotaLow(tt.limit, now); got != tt.want {
+			t.Errorf("quotaLow(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
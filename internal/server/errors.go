@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+const (
+	defaultErrorDays  = 7
+	defaultErrorLimit = 100
+	maxErrorLimit     = 1000
+)
+
+// classifyErrors fills in the category of errors reported by checkers that
+// predate classification.
+func classifyErrors(report *checker.CheckReport) {
+	for i := range report.Errors {
+		if report.Errors[i].Category == "" {
+			report.Errors[i].Category = checker.Classify(report.Errors[i].Stage, report.Errors[i].Error)
+		}
+	}
+}
+
+// parseErrorQuery reads the category, stage, platform, days and limit filters
+func parseErrorQuery(c *gin.Context) (database.ErrorQuery, bool) {
+	q := database.ErrorQuery{
+		Category: c.Query("category"),
+		Stage:    c.Query("stage"),
+		Platform: c.Query("platform"),
+		Days:     defaultErrorDays,
+		Limit:    defaultErrorLimit,
+	}
+
+	if q.Category != "" && !isCategory(q.Category) {
+		c.Error(NewError(http.StatusBadRequest, "Invalid category"))
+		return q, false
+	}
+	if q.Platform != "" && !ValidPlatforms[q.Platform] {
+		c.Error(NewError(http.StatusBadRequest, "Invalid platform"))
+		return q, false
+	}
+	if d := c.Query("days"); d != "" {
+		val, err := strconv.Atoi(d)
+		if err != nil || val <= 0 {
+			c.Error(NewError(http.StatusBadRequest, "Invalid days parameter"))
+			return q, false
+		}
+		q.Days = val
+	}
+	if l := c.Query("limit"); l != "" {
+		val, err := strconv.Atoi(l)
+		if err != nil || val <= 0 || val > maxErrorLimit {
+			c.Error(NewError(http.StatusBadRequest, "Invalid limit parameter"))
+			return q, false
+		}
+		q.Limit = val
+	}
+	return q, true
+}
+
+func isCategory(category string) bool {
+	for _, c := range checker.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// GetErrors answers GET /errors?category=&stage=&platform=&days=&limit=
+func (h *Handler) GetErrors(c *gin.Context) {
+	q, ok := parseErrorQuery(c)
+	if !ok {
+		return
+	}
+
+	records, err := h.db.GetErrors(c.Request.Context(), q)
+	if err != nil {
+		logger.Error("Failed to get errors:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch errors"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":   q.Days,
+		"total":  len(records),
+		"errors": records,
+	})
+}
+
+// GetErrorSummary answers GET /errors/summary with the error count of every
+// category, taking the same filters as GetErrors
+func (h *Handler) GetErrorSummary(c *gin.Context) {
+	q, ok := parseErrorQuery(c)
+	if !ok {
+		return
+	}
+
+	counts, err := h.db.GetErrorSummary(c.Request.Context(), q)
+	if err != nil {
+		logger.Error("Failed to get error summary:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch error summary"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":       q.Days,
+		"categories": counts,
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+)
+
+func TestClassifyErrors(t *testing.T) {
+	report := &checker.CheckReport{Errors: []checker.Error{
+		{Stage: "download", Error: "component tiflash doesn't exist"},
+		{Stage: "download", Error: "component tiflash doesn't exist", Category: checker.CategoryNetwork},
+		{Stage: "github", Error: "something odd"},
+	}}
+	classifyErrors(report)
+
+	want := []string{checker.CategoryPackageMissing, checker.CategoryNetwork, checker.CategoryUnknown}
+	for i, e := range report.Errors {
+		if e.Category != want[i] {
+			t.Errorf("error %d category = %s, want %s", i, e.Category, want[i])
+		}
+	}
+}
+
+func TestParseErrorQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    database.ErrorQuery
+		message string
+	}{
+		{query: "", want: database.ErrorQuery{Days: defaultErrorDays, Limit: defaultErrorLimit}},
+		{
+			query: "category=network&stage=download&platform=linux-arm64&days=30&limit=1000",
+			want:  database.ErrorQuery{Category: "network", Stage: "download", Platform: "linux-arm64", Days: 30, Limit: 1000},
+		},
+		{query: "category=flaky", message: "Invalid category"},
+		{query: "platform=windows-amd64", message: "Invalid platform"},
+		{query: "days=0", message: "Invalid days parameter"},
+		{query: "days=week", message: "Invalid days parameter"},
+		{query: "limit=1001", message: "Invalid limit parameter"},
+		{query: "limit=-1", message: "Invalid limit parameter"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/errors?"+tt.query, nil)
+		got, ok := parseErrorQuery(c)
+		if tt.message != "" {
+			if ok || len(c.Errors) != 1 || !strings.Contains(c.Errors[0].Error(), tt.message) {
+				t.Errorf("parseErrorQuery(%q) = %v %v, want %q", tt.query, ok, c.Errors, tt.message)
+			}
+			continue
+		}
+		if !ok || got != tt.want {
+			t.Errorf("parseErrorQuery(%q) = %+v %v, want %+v", tt.query, got, ok, tt.want)
+		}
+	}
+}
+
+func TestGetErrorsValidation(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{}
+	engine.GET("/errors", h.GetErrors)
+	engine.GET("/errors/summary", h.GetErrorSummary)
+
+	for _, path := range []string{"/errors?category=flaky", "/errors/summary?days=-3"} {
+		w := serve(engine, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d %s, want 400", path, w.Code, w.Body.String())
+		}
+	}
+}
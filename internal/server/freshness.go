@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/freshness"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+// GetFreshness evaluates the latest result of every platform, or of the one
+// given by ?platform=, against the branch heads. Heads of all branches are
+// loaded, the evaluator picks each component's default branch.
+func (h *Handler) GetFreshness(c *gin.Context) {
+	platform := c.Query("platform")
+	if platform != "" && !ValidPlatforms[platform] {
+		c.Error(NewError(http.StatusBadRequest, "Invalid platform"))
+		return
+	}
+
+	reports, err := h.db.GetLatestResults(c.Request.Context())
+	if err != nil {
+		logger.Error("Failed to get latest results:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch latest results"))
+		return
+	}
+
+	heads, err := h.db.GetBranchCommits(c.Request.Context(), "", h.components.Names())
+	if err != nil {
+		logger.Error("Failed to get branch commits:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch branch commits"))
+		return
+	}
+
+	results := []freshness.Result{}
+	stale := false
+	for i := range reports {
+		if platform != "" && reports[i].Platform != platform {
+			continue
+		}
+		if !h.freshness.Applies(&reports[i]) {
+			continue
+		}
+		result := h.freshness.Evaluate(&reports[i], heads)
+		stale = stale || result.Stale
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stale":   stale,
+		"total":   len(results),
+		"results": results,
+	})
+}
+
+// alertIfStale sends a notification when a freshly reported run is stale.
+// Errors are only logged, the report is already stored.
+func (h *Handler) alertIfStale(ctx context.Context, report *checker.CheckReport) {
+	if !h.freshness.Applies(report) {
+		return
+	}
+
+	heads, err := h.db.GetBranchCommits(ctx, "", h.components.Names())
+	if err != nil {
+		logger.Error("Failed to get branch commits for freshness check:", err)
+		return
+	}
+
+	result := h.freshness.Evaluate(report, heads)
+	if !result.Stale {
+		return
+	}
+
+	var lags []notify.ComponentLag
+	for _, comp := range result.Components {
+		if !comp.Stale {
+			continue
+		}
+		lags = append(lags, notify.ComponentLag{
+			Component: comp.Component,
+			Branch:    comp.Branch,
+			GitHash:   comp.GitHash,
+			HeadHash:  comp.HeadHash,
+			LagHours:  *comp.LagHours,
+		})
+	}
+
+	label := report.Platform
+	if report.Mirror != "" {
+		label += " (" + report.Mirror + ")"
+	}
+	logger.Warn("Stale nightly reported for", label)
+	if err := h.notifier.SendStaleNotification(label, result.ThresholdHours, lags, runDetail(report)); err != nil {
+		logger.Error("Failed to send stale notification:", err)
+	}
+}
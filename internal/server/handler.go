@@ -6,16 +6,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/freshness"
+	"github.com/purelind/check-tiup-nightly/internal/github"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 	"github.com/purelind/check-tiup-nightly/internal/database"
 )
 
 type Handler struct {
-	db *database.DB
+	db         *database.DB
+	components *component.Registry
+	github     *github.Client
+	freshness  *freshness.Evaluator
+	notifier   *notify.Notifier
 }
 
-func NewHandler(db *database.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *database.DB, components *component.Registry, gh *github.Client, evaluator *freshness.Evaluator, notifier *notify.Notifier) *Handler {
+	return &Handler{
+		db:         db,
+		components: components,
+		github:     gh,
+		freshness:  evaluator,
+		notifier:   notifier,
+	}
 }
 
 // Error custom error type
@@ -44,14 +58,34 @@ func (h *Handler) ReportStatus(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.SaveCheckResult(c.Request.Context(), &report); err != nil {
+	if !allowsPlatform(c, report.Platform) {
+		c.Error(NewError(http.StatusForbidden, "API key not allowed for platform " + report.Platform))
+		return
+	}
+
+	// whatever the body claims, only the verified signer counts
+	report.SignedBy, report.Signature = signer(c)
+	classifyErrors(&report)
+
+	id, created, err := h.db.SaveCheckResult(c.Request.Context(), &report)
+	if err != nil {
 		logger.Error("Failed to save check result:", err)
 		c.Error(NewError(http.StatusInternalServerError, "Failed to save check result"))
 		return
 	}
 
+	// a resent report was already handled when it first arrived
+	if created {
+		h.alertIfStale(c.Request.Context(), &report)
+	} else {
+		logger.Info("Duplicate report for run", report.RunID, "already stored as", id)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
+		"status":    "success",
+		"id":        id,
+		"run_id":    report.RunID,
+		"duplicate": !created,
 	})
 }
 
@@ -105,6 +139,8 @@ func (h *Handler) GetPlatformResults(c *gin.Context) {
 		params.Days = 0
 	}
 
+	filterTarget(c, &params)
+
 	// add debug log
 	logger.Info("Query params:", params)
 
@@ -145,6 +181,7 @@ func (h *Handler) GetPlatformHistory(c *gin.Context) {
 		Platform: platform,
 		Days:     days,
 	}
+	filterTarget(c, &params)
 
 	results, err := h.db.GetPlatformHistory(c.Request.Context(), params)
 	if err != nil {
@@ -161,6 +198,17 @@ func (h *Handler) GetPlatformHistory(c *gin.Context) {
 	})
 }
 
+// filterTarget narrows params to the target of the mirror and channel query
+// parameters; an empty mirror selects the default one
+func filterTarget(c *gin.Context, params *database.QueryParams) {
+	if mirror, ok := c.GetQuery("mirror"); ok {
+		params.Mirror = &mirror
+	}
+	if channel, ok := c.GetQuery("channel"); ok {
+		params.Channel = &channel
+	}
+}
+
 func (h *Handler) UpdateBranchCommit(c *gin.Context) {
 	var info checker.BranchCommitInfo
 	if err := c.ShouldBindJSON(&info); err != nil {
@@ -168,7 +216,7 @@ func (h *Handler) UpdateBranchCommit(c *gin.Context) {
 		return
 	}
 	
-	if !isValidComponent(info.Component) {
+	if !h.components.IsValid(info.Component) {
 		c.Error(NewError(http.StatusBadRequest, "Invalid component"))
 		return
 	}
@@ -178,7 +226,7 @@ func (h *Handler) UpdateBranchCommit(c *gin.Context) {
 		return
 	}
 	
-	if err := h.db.UpdateBranchCommit(c.Request.Context(), &info); err != nil {
+	if _, err := h.db.UpdateBranchCommit(c.Request.Context(), &info); err != nil {
 		logger.Error("Failed to update branch commit:", err)
 		c.Error(NewError(http.StatusInternalServerError, "Failed to update branch commit"))
 		return
@@ -190,7 +238,7 @@ func (h *Handler) UpdateBranchCommit(c *gin.Context) {
 func (h *Handler) GetBranchCommits(c *gin.Context) {
 	branch := c.Query("branch")
 	
-	results, err := h.db.GetBranchCommits(c.Request.Context(), branch)
+	results, err := h.db.GetBranchCommits(c.Request.Context(), branch, h.components.Names())
 	if err != nil {
 		logger.Error("Failed to get branch commits:", err)
 		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch branch commits"))
@@ -202,13 +250,36 @@ func (h *Handler) GetBranchCommits(c *gin.Context) {
 	})
 }
 
-var validComponents = map[string]bool{
-	"tidb":    true,
-	"tikv":    true,
-	"pd":      true,
-	"tiflash": true,
-}
+// GetBranchCommitHistory lists the recorded head changes of one branch
+func (h *Handler) GetBranchCommitHistory(c *gin.Context) {
+	component := c.Query("component")
+	if !h.components.IsValid(component) {
+		c.Error(NewError(http.StatusBadRequest, "Invalid component"))
+		return
+	}
+
+	branch := c.Query("branch")
+	if branch == "" {
+		comp, _ := h.components.Get(component)
+		branch = comp.DefaultBranch
+	}
 
-func isValidComponent(component string) bool {
-	return validComponents[component]
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.Error(NewError(http.StatusBadRequest, "Invalid limit parameter. Must be between 1 and 500"))
+		return
+	}
+
+	changes, err := h.db.GetBranchCommitHistory(c.Request.Context(), component, branch, limit)
+	if err != nil {
+		logger.Error("Failed to get branch commit history:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch branch commit history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"component": component,
+		"branch":    branch,
+		"results":   changes,
+	})
 }
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+)
+
+func TestGetBranchCommitHistoryValidation(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{components: component.Default()}
+	engine.GET("/branch-commits/history", h.GetBranchCommitHistory)
+
+	tests := []struct {
+		query   string
+		message string
+	}{
+		{query: "", message: "Invalid component"},
+		{query: "component=ticdc", message: "Invalid component"},
+		{query: "component=tidb&limit=0", message: "Invalid limit parameter"},
+		{query: "component=tidb&limit=501", message: "Invalid limit parameter"},
+		{query: "component=tidb&branch=master&limit=ten", message: "Invalid limit parameter"},
+	}
+	for _, tt := range tests {
+		w := serve(engine, httptest.NewRequest("GET", "/branch-commits/history?"+tt.query, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("GET ?%s = %d %s, want 400 %q", tt.query, w.Code, w.Body.String(), tt.message)
+		}
+	}
+}
+
+func TestFilterTarget(t *testing.T) {
+	tests := []struct {
+		query   string
+		mirror  *string
+		channel *string
+	}{
+		{query: ""},
+		{query: "channel=nightly", channel: ptr("nightly")},
+		// an empty mirror selects the default one, it is not the same as no filter
+		{query: "mirror=&channel=v8.5.0", mirror: ptr(""), channel: ptr("v8.5.0")},
+		{query: "mirror=https://tiup.example.com", mirror: ptr("https://tiup.example.com")},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/results?"+tt.query, nil)
+		var params database.QueryParams
+		filterTarget(c, &params)
+		if show(params.Mirror) != show(tt.mirror) || show(params.Channel) != show(tt.channel) {
+			t.Errorf("filterTarget(%q) = mirror %v, channel %v, want %v, %v", tt.query, show(params.Mirror), show(params.Channel), show(tt.mirror), show(tt.channel))
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func show(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%q", *s)
+}
+
+func TestReportStatusPlatform(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{}
+	key := &auth.Key{Name: "runner-1", Platforms: []string{"linux-amd64"}, Scopes: []string{auth.ScopeReport}}
+	engine.POST("/status", func(c *gin.Context) { c.Set(apiKeyContextKey, key) }, h.ReportStatus)
+
+	// refused before anything is stored
+	w := serve(engine, httptest.NewRequest("POST", "/status", strings.NewReader(`{"platform": "darwin-arm64", "status": "success"}`)))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "not allowed for platform darwin-arm64") {
+		t.Errorf("POST /status = %d %s, want 403", w.Code, w.Body.String())
+	}
+}
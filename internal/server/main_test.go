@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "server-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(filepath.Join(dir, "test.log")); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestEngine returns an engine with the error handling of the server
+func newTestEngine() *gin.Engine {
+	engine := gin.New()
+	engine.Use(ErrorHandler())
+	return engine
+}
+
+func serve(engine *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
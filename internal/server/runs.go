@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+var runIDPattern = regexp.MustCompile(`^[0-9a-fA-F-]{1,64}$`)
+
+// GetRun answers GET /runs/:id with the check result of a run ID
+func (h *Handler) GetRun(c *gin.Context) {
+	runID := c.Param("id")
+	if !runIDPattern.MatchString(runID) {
+		c.Error(NewError(http.StatusBadRequest, "Invalid run id"))
+		return
+	}
+
+	report, err := h.db.GetRun(c.Request.Context(), runID)
+	if errors.Is(err, database.ErrNotFound) {
+		c.Error(NewError(http.StatusNotFound, "Run not found"))
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to get run:", err)
+		c.Error(NewError(http.StatusInternalServerError, "Failed to fetch run"))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// runDetail identifies the run of a report in notifications
+func runDetail(report *checker.CheckReport) notify.RunDetail {
+	run := notify.RunDetail{ID: report.RunID}
+	if report.Run != nil {
+		run.Hostname = report.Run.Hostname
+		run.Trigger = report.Run.Trigger
+		run.CIJobURL = report.Run.CIJobURL
+	}
+	return run
+}
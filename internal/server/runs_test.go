@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
+)
+
+func TestGetRunValidation(t *testing.T) {
+	engine := newTestEngine()
+	h := &Handler{}
+	engine.GET("/runs/:id", h.GetRun)
+
+	for _, id := range []string{"not-a-uuid", "0b5e6c1a;drop", strings.Repeat("a", 65)} {
+		w := serve(engine, httptest.NewRequest("GET", "/runs/"+id, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid run id") {
+			t.Errorf("GET /runs/%s = %d %s, want 400", id, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRunDetail(t *testing.T) {
+	tests := []struct {
+		name   string
+		report checker.CheckReport
+		want   notify.RunDetail
+	}{
+		{name: "old checker", report: checker.CheckReport{}, want: notify.RunDetail{}},
+		{name: "run id only", report: checker.CheckReport{RunID: "run-1"}, want: notify.RunDetail{ID: "run-1"}},
+		{
+			name: "run info",
+			report: checker.CheckReport{RunID: "run-1", Run: &checker.RunInfo{
+				Hostname: "runner-1", Trigger: "cron", CIJobURL: "https://ci.example.com/job/1", Version: "v1.2.0",
+			}},
+			want: notify.RunDetail{ID: "run-1", Hostname: "runner-1", Trigger: "cron", CIJobURL: "https://ci.example.com/job/1"},
+		},
+	}
+	for _, tt := range tests {
+		if got := runDetail(&tt.report); got != tt.want {
+			t.Errorf("runDetail(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
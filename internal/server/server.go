@@ -7,7 +7,13 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/purelind/check-tiup-nightly/internal/auth"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/config"
 	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/internal/freshness"
+	"github.com/purelind/check-tiup-nightly/internal/github"
+	"github.com/purelind/check-tiup-nightly/internal/notify"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 )
 
@@ -17,7 +23,7 @@ type Server struct {
 	db     *database.DB
 }
 
-func New(db *database.DB, port int) *Server {
+func New(db *database.DB, cfg *config.Config, components *component.Registry, gh *github.Client) *Server {
 	gin.SetMode(gin.ReleaseMode)
 
 	engine := gin.New()
@@ -26,21 +32,33 @@ func New(db *database.DB, port int) *Server {
 	engine.Use(RequestLogger())
 	engine.Use(ErrorHandler())
 
-	h := NewHandler(db)
+	h := NewHandler(db, components, gh, freshness.NewEvaluator(cfg.FreshnessThreshold, components), notify.NewNotifier())
+
+	// writes need an API key, reads are public
+	requireKey := apiKeyAuth(db, cfg.Server.AuthDisabled)
 
 	// register routes
 	api := engine.Group("/api/v1")
 	{
-		api.POST("/status", h.ReportStatus)
+		api.POST("/status", requireKey(auth.ScopeReport), verifySignature(cfg.Server.SignatureWindow), h.ReportStatus)
 		api.GET("/results/latest", h.GetLatestResults)
 		api.GET("/platforms/:platform/results", h.GetPlatformResults)
 		api.GET("/results/platforms/:platform/history", h.GetPlatformHistory)
-		api.POST("/branch-commits", h.UpdateBranchCommit)
+		api.POST("/branch-commits", requireKey(auth.ScopeCommits), h.UpdateBranchCommit)
 		api.GET("/branch-commits", h.GetBranchCommits)
+		api.GET("/branch-commits/history", h.GetBranchCommitHistory)
+		api.GET("/branch-commits/behind", h.GetCommitsBehind)
+		api.GET("/freshness", h.GetFreshness)
+		api.GET("/errors", h.GetErrors)
+		api.GET("/errors/summary", h.GetErrorSummary)
+		api.GET("/runs/:id", h.GetRun)
+		api.GET("/results/:id/artifacts", h.ListArtifacts)
+		api.POST("/results/:id/artifacts/:name", requireKey(auth.ScopeReport), h.UploadArtifact)
+		api.GET("/results/:id/artifacts/:name", h.GetArtifact)
 	}
 
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      engine,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
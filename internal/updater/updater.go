@@ -2,42 +2,220 @@ package service
 
 import (
 	"context"
-	
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/config"
 	"github.com/purelind/check-tiup-nightly/internal/database"
+	"github.com/purelind/check-tiup-nightly/internal/github"
 	"github.com/purelind/check-tiup-nightly/pkg/logger"
 )
 
 type Updater struct {
-	db *database.DB
+	db         *database.DB
+	github     *github.Client
+	components *component.Registry
+
+	// branches tracked for every component besides its own, and for single
+	// components by name
+	branches          []string
+	componentBranches map[string][]string
+	releasePattern    *regexp.Regexp
+	releaseLimit      int
+
+	mu sync.Mutex
+	// missing holds the configured "component:branch" already warned about
+	missing map[string]bool
 }
 
-func NewUpdater(db *database.DB) *Updater {
-	return &Updater{db: db}
+func NewUpdater(db *database.DB, gh *github.Client, components *component.Registry, cfg *config.Config) *Updater {
+	u := &Updater{
+		db:                db,
+		github:            gh,
+		components:        components,
+		componentBranches: make(map[string][]string),
+		releaseLimit:      cfg.ReleaseBranchLimit,
+		missing:           make(map[string]bool),
+	}
+	for _, entry := range cfg.TrackedBranches {
+		// ':' can't be part of a branch name
+		name, branch, scoped := strings.Cut(entry, ":")
+		if !scoped {
+			u.branches = append(u.branches, entry)
+			continue
+		}
+		if _, ok := components.Get(name); !ok {
+			logger.Warn("Ignoring tracked branch", entry, "of unknown component", name)
+			continue
+		}
+		u.componentBranches[name] = append(u.componentBranches[name], branch)
+	}
+	if u.releaseLimit > 0 {
+		pattern, err := regexp.Compile(cfg.ReleaseBranchPattern)
+		if err != nil {
+			logger.Error("Invalid release branch pattern, discovery disabled:", err)
+			u.releaseLimit = 0
+		}
+		u.releasePattern = pattern
+	}
+	return u
 }
 
 func (u *Updater) UpdateAllComponentsCommits(ctx context.Context) error {
-	components := []string{"tidb", "tikv", "pd", "tiflash"}
-
-	for _, component := range components {
-		if err := u.UpdateComponentCommit(ctx, component); err != nil {
-			logger.Error("Failed to update commit info for", component, ":", err)
-			continue
+	for _, comp := range u.components.All() {
+		for _, branch := range u.trackedBranches(ctx, comp) {
+			err := u.UpdateComponentCommit(ctx, comp, branch)
+			if errors.Is(err, github.ErrNotFound) && branch != comp.DefaultBranch {
+				u.warnMissing(comp, branch)
+				continue
+			}
+			if err != nil {
+				logger.Error("Failed to update commit info for", comp.Name, branch, ":", err)
+				continue
+			}
 		}
 	}
 	return nil
 }
 
-func (u *Updater) UpdateComponentCommit(ctx context.Context, component string) error {
-	info, err := checker.FetchLatestCommitInfo(ctx, component, "master")
+func (u *Updater) UpdateComponentCommit(ctx context.Context, comp component.Component, branch string) error {
+	info, err := checker.FetchLatestCommitInfo(ctx, u.github, comp, branch)
 	if err != nil {
 		return err
 	}
 
-	if err := u.db.UpdateBranchCommit(ctx, info); err != nil {
+	change, err := u.db.UpdateBranchCommit(ctx, info)
+	if err != nil {
 		return err
 	}
+	if change == nil {
+		logger.Debug("Branch head unchanged for", comp.Name, info.Branch, ":", info.GitHash)
+		return nil
+	}
 
-	logger.Info("Updated commit info for", component, info.Branch, ":", info.GitHash)
+	logger.Info("Branch head changed for", comp.Name, info.Branch, ":", info.GitHash)
+	if change.PreviousHash != "" {
+		u.recordCommitsAdded(ctx, comp, change)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// recordCommitsAdded counts the commits between the previous and the new head,
+// so that the distance of an older head can later be computed from history.
+// A force-pushed branch has no count.
+func (u *Updater) recordCommitsAdded(ctx context.Context, comp component.Component, change *checker.BranchHeadChange) {
+	cmp, err := u.github.Compare(ctx, comp.Repo, change.PreviousHash, change.GitHash)
+	if err != nil {
+		logger.Error("Failed to compare", comp.Repo, change.PreviousHash, change.GitHash, ":", err)
+		return
+	}
+	if cmp.Status != "ahead" {
+		logger.Warn("Branch head of", comp.Name, change.Branch, "did not move forward:", cmp.Status)
+		return
+	}
+	if err := u.db.SetCommitsAdded(ctx, change.ID, cmp.AheadBy); err != nil {
+		logger.Error("Failed to record commits added for", comp.Name, change.Branch, ":", err)
+	}
+}
+
+// warnMissing warns once per process about a configured branch a component's
+// repository does not have, e.g. a release branch only some components cut.
+// It reports whether it warned.
+func (u *Updater) warnMissing(comp component.Component, branch string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := comp.Name + ":" + branch
+	if u.missing[key] {
+		logger.Debug("Branch", branch, "still missing in", comp.Repo)
+		return false
+	}
+	u.missing[key] = true
+	logger.Warn(fmt.Sprintf("Branch %s does not exist in %s, scope it to the components that have it with TRACKED_BRANCHES=component:%s",
+		branch, comp.Repo, branch))
+	return true
+}
+
+// trackedBranches returns the default branch, the configured branches and
+// the newest release branches found on GitHub, without duplicates.
+func (u *Updater) trackedBranches(ctx context.Context, comp component.Component) []string {
+	candidates := []string{comp.DefaultBranch}
+	candidates = append(candidates, comp.Branches...)
+	candidates = append(candidates, u.branches...)
+	candidates = append(candidates, u.componentBranches[comp.Name]...)
+
+	if u.releaseLimit > 0 {
+		releases, err := u.discoverReleaseBranches(ctx, comp)
+		if err != nil {
+			logger.Error("Failed to discover release branches of", comp.Repo, ":", err)
+		}
+		candidates = append(candidates, releases...)
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	branches := make([]string, 0, len(candidates))
+	for _, b := range candidates {
+		if b == "" || seen[b] {
+			continue
+		}
+		seen[b] = true
+		branches = append(branches, b)
+	}
+	return branches
+}
+
+// discoverReleaseBranches lists the branches matching the release pattern,
+// newest version first, at most releaseLimit of them.
+func (u *Updater) discoverReleaseBranches(ctx context.Context, comp component.Component) ([]string, error) {
+	all, err := u.github.ListBranches(ctx, comp.Repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %v", err)
+	}
+
+	var releases []string
+	for _, b := range all {
+		if u.releasePattern.MatchString(b.Name) {
+			releases = append(releases, b.Name)
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return compareVersions(releases[i], releases[j]) > 0
+	})
+
+	if len(releases) > u.releaseLimit {
+		releases = releases[:u.releaseLimit]
+	}
+	return releases, nil
+}
+
+var numberPattern = regexp.MustCompile(`\d+`)
+
+// compareVersions compares the numbers embedded in two branch names, so that
+// release-10.0 sorts after release-9.1.
+func compareVersions(a, b string) int {
+	na := numberPattern.FindAllString(a, -1)
+	nb := numberPattern.FindAllString(b, -1)
+	for i := 0; i < len(na) && i < len(nb); i++ {
+		x, _ := strconv.Atoi(na[i])
+		y, _ := strconv.Atoi(nb[i])
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case len(na) > len(nb):
+		return 1
+	case len(na) < len(nb):
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/purelind/check-tiup-nightly/internal/checker"
+	"github.com/purelind/check-tiup-nightly/internal/component"
+	"github.com/purelind/check-tiup-nightly/internal/config"
+	"github.com/purelind/check-tiup-nightly/internal/github"
+	"github.com/purelind/check-tiup-nightly/internal/github/githubfake"
+	"github.com/purelind/check-tiup-nightly/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "updater-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := logger.Init(filepath.Join(dir, "test.log")); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestTrackedBranches(t *testing.T) {
+	fake := githubfake.NewServer()
+	defer fake.Close()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, branch := range []string{"master", "release-7.5", "release-8.1", "release-8.5", "release-10.0", "feature-x", "release-8.5-hotfix"} {
+		fake.SetCommit("pingcap/tidb", branch, string(rune('a'+i))+"000000000000000000000000000000000000000", at)
+	}
+	gh := github.NewClient(github.Options{BaseURL: fake.URL})
+
+	tidb := component.Component{Name: "tidb", Repo: "pingcap/tidb", DefaultBranch: "master", Branches: []string{"feature-x"}}
+	tests := []struct {
+		name string
+		comp component.Component
+		cfg  config.Config
+		want []string
+	}{
+		{
+			name: "newest releases",
+			comp: tidb,
+			cfg:  config.Config{ReleaseBranchPattern: `^release-\d+\.\d+$`, ReleaseBranchLimit: 2},
+			want: []string{"master", "feature-x", "release-10.0", "release-8.5"},
+		},
+		{
+			name: "discovery disabled",
+			comp: tidb,
+			cfg:  config.Config{TrackedBranches: []string{"release-7.5"}},
+			want: []string{"master", "feature-x", "release-7.5"},
+		},
+		{
+			name: "duplicates dropped",
+			comp: tidb,
+			cfg:  config.Config{TrackedBranches: []string{"master", "release-10.0", ""}, ReleaseBranchPattern: `^release-\d+\.\d+$`, ReleaseBranchLimit: 1},
+			want: []string{"master", "feature-x", "release-10.0"},
+		},
+		{
+			name: "invalid pattern disables discovery",
+			comp: tidb,
+			cfg:  config.Config{ReleaseBranchPattern: `release-(`, ReleaseBranchLimit: 2},
+			want: []string{"master", "feature-x"},
+		},
+		{
+			name: "branches scoped to components",
+			comp: tidb,
+			cfg:  config.Config{TrackedBranches: []string{"tidb:release-7.5", "tikv:release-8.1", "pd-x:release-8.5"}},
+			want: []string{"master", "feature-x", "release-7.5"},
+		},
+		{
+			name: "unknown repo keeps configured branches",
+			comp: component.Component{Name: "tikv", Repo: "tikv/tikv", DefaultBranch: "master"},
+			cfg:  config.Config{TrackedBranches: []string{"release-8.5"}, ReleaseBranchPattern: `^release-\d+\.\d+$`, ReleaseBranchLimit: 2},
+			want: []string{"master", "release-8.5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewUpdater(nil, gh, component.Default(), &tt.cfg)
+			got := u.trackedBranches(context.Background(), tt.comp)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trackedBranches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarnMissing(t *testing.T) {
+	u := NewUpdater(nil, nil, component.Default(), &config.Config{})
+	tidb, _ := component.Default().Get("tidb")
+	pd, _ := component.Default().Get("pd")
+	tests := []struct {
+		comp   component.Component
+		branch string
+		want   bool
+	}{
+		{comp: tidb, branch: "release-9.0", want: true},
+		{comp: tidb, branch: "release-9.0"},
+		{comp: pd, branch: "release-9.0", want: true},
+		{comp: tidb, branch: "release-9.1", want: true},
+	}
+	for _, tt := range tests {
+		if got := u.warnMissing(tt.comp, tt.branch); got != tt.want {
+			t.Errorf("warnMissing(%s, %s) = %v, want %v", tt.comp.Name, tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestFetchLatestCommitInfo(t *testing.T) {
+	fake := githubfake.NewServer()
+	defer fake.Close()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	fake.SetCommit("tikv/pd", "release-8.5", hash, at)
+	gh := github.NewClient(github.Options{BaseURL: fake.URL})
+
+	comp, _ := component.Default().Get("pd")
+	info, err := checker.FetchLatestCommitInfo(context.Background(), gh, comp, "release-8.5")
+	if err != nil {
+		t.Fatalf("FetchLatestCommitInfo: %v", err)
+	}
+	want := checker.BranchCommitInfo{Component: "pd", Branch: "release-8.5", GitHash: hash, CommitTime: at}
+	if info.Component != want.Component || info.Branch != want.Branch || info.GitHash != want.GitHash || !info.CommitTime.Equal(want.CommitTime) {
+		t.Errorf("FetchLatestCommitInfo = %+v, want %+v", *info, want)
+	}
+
+	// the updater tells a missing branch from other failures
+	if _, err := checker.FetchLatestCommitInfo(context.Background(), gh, comp, "release-1.0"); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("FetchLatestCommitInfo of a missing branch = %v, want %v", err, github.ErrNotFound)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"release-8.5", "release-8.5", 0},
+		{"release-10.0", "release-9.1", 1},
+		{"release-8.1", "release-8.5", -1},
+		{"release-8.5.1", "release-8.5", 1},
+		{"release-8", "release-8.0", -1},
+		{"release-x", "release-y", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -9,10 +9,15 @@ export async function GET(
   const platform = (await params).platform;
   const searchParams = request.nextUrl.searchParams;
   const days = searchParams.get('days') || '1';
+  const query = new URLSearchParams({ days });
+  for (const key of ['mirror', 'channel']) {
+    const value = searchParams.get(key);
+    if (value !== null) query.set(key, value);
+  }
 
   try {
     const response = await fetch(
-      `${API_BASE_URL}/api/v1/platforms/${platform}/results?days=${days}`
+      `${API_BASE_URL}/api/v1/platforms/${platform}/results?${query}`
     );
 
     if (!response.ok) {
@@ -0,0 +1,37 @@
+import { NextRequest, NextResponse } from 'next/server';
+
+const API_BASE_URL = process.env.API_BASE_URL || 'http://localhost:5050';
+
+export async function GET(
+  request: NextRequest,
+  { params }: { params: Promise<{ id: string; name: string }> }
+) {
+  const { id, name } = await params;
+
+  try {
+    const response = await fetch(
+      `${API_BASE_URL}/api/v1/results/${encodeURIComponent(id)}/artifacts/${encodeURIComponent(name)}`
+    );
+
+    if (!response.ok) {
+      return NextResponse.json(
+        { error: `Failed to fetch artifact: ${response.status}` },
+        { status: response.status }
+      );
+    }
+
+    // pass the file through as is, the server sets the download headers
+    const headers = new Headers();
+    for (const key of ['content-type', 'content-disposition', 'content-length']) {
+      const value = response.headers.get(key);
+      if (value !== null) headers.set(key, value);
+    }
+    return new NextResponse(response.body, { headers });
+  } catch (error) {
+    console.error('Artifact API error:', error);
+    return NextResponse.json(
+      { error: 'Failed to fetch artifact' },
+      { status: 500 }
+    );
+  }
+}
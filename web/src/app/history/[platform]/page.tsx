@@ -5,6 +5,12 @@ import { useParams, useSearchParams } from 'next/navigation';
 import { CheckResult } from '@/types';
 import Link from 'next/link';
 
+function formatSize(bytes: number) {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KiB`;
+  return `${(bytes / (1024 * 1024)).toFixed(1)} MiB`;
+}
+
 export default function PlatformHistory() {
   const params = useParams();
   const searchParams = useSearchParams();
@@ -13,12 +19,21 @@ export default function PlatformHistory() {
   
   const platform = params.platform as string;
   const days = searchParams.get('days') || '1';
+  // mirror and channel pick one target, all of the platform's if absent
+  const mirror = searchParams.get('mirror');
+  const channel = searchParams.get('channel');
+  const targetQuery = (days: string) => {
+    const query = new URLSearchParams({ days });
+    if (mirror !== null) query.set('mirror', mirror);
+    if (channel !== null) query.set('channel', channel);
+    return query.toString();
+  };
 
   useEffect(() => {
     const fetchHistory = async () => {
       setLoading(true);
       try {
-        const response = await fetch(`/api/history/${platform}?days=${days}`)
+        const response = await fetch(`/api/history/${platform}?${targetQuery(days)}`)
         if (!response.ok) {
           throw new Error('Failed to fetch history');
         }
@@ -32,7 +47,7 @@ export default function PlatformHistory() {
     };
 
     fetchHistory();
-  }, [platform, days]);
+  }, [platform, days, mirror, channel]);
 
   if (loading) {
     return (
@@ -55,6 +70,11 @@ export default function PlatformHistory() {
           <h1 className="text-3xl font-bold text-gray-900 mt-4">
             {decodeURIComponent(platform)} Check History
           </h1>
+          {(mirror !== null || channel !== null) && (
+            <p className="mt-2 text-gray-600">
+              {channel || 'default channel'} @ {mirror || 'default mirror'}
+            </p>
+          )}
           
           {/* Time Range Selector */}
           <div className="mt-4 flex gap-4">
@@ -65,7 +85,7 @@ export default function PlatformHistory() {
             ].map(({ label, value }) => (
               <Link
                 key={value}
-                href={`/history/${platform}?days=${value}`}
+                href={`/history/${platform}?${targetQuery(value)}`}
                 className={`px-4 py-2 rounded-full ${
                   days === value 
                     ? 'bg-blue-600 text-white' 
@@ -89,12 +109,12 @@ export default function PlatformHistory() {
                 <div className="flex items-center justify-between mb-4">
                   <div className="flex items-center">
                     <span className={`w-3 h-3 rounded-full mr-2 ${
-                      result.status === 'success' ? 'bg-green-500' : 'bg-red-500'
+                      result.status === 'success' ? 'bg-green-500' : result.status === 'flaky' ? 'bg-yellow-500' : result.status === 'environment_error' ? 'bg-gray-400' : 'bg-red-500'
                     }`}></span>
                     <span className={`text-sm font-medium ${
-                      result.status === 'success' ? 'text-green-800' : 'text-red-800'
+                      result.status === 'success' ? 'text-green-800' : result.status === 'flaky' ? 'text-yellow-800' : result.status === 'environment_error' ? 'text-gray-800' : 'text-red-800'
                     }`}>
-                      {result.status === 'success' ? 'Normal' : 'Abnormal'}
+                      {result.status === 'success' ? 'Normal' : result.status === 'flaky' ? 'Flaky' : result.status === 'environment_error' ? 'Environment' : 'Abnormal'}
                     </span>
                   </div>
                   <span className="text-sm text-gray-600">
@@ -110,12 +130,12 @@ export default function PlatformHistory() {
                   }</p>
 
                   {/* Add error details section */}
-                  {result.status === 'failed' && result.errors && result.errors.length > 0 && (
+                  {result.status !== 'success' && result.errors && result.errors.length > 0 && (
                     <div className="mt-4 p-4 bg-red-50 rounded-lg">
                       <p className="font-medium text-red-800 mb-2">Errors:</p>
                       {result.errors.map((error, index) => (
                         <div key={index} className="mb-2 last:mb-0">
-                          <p className="text-red-700 font-medium">Stage: {error.stage}</p>
+                          <p className="text-red-700 font-medium">Stage: {error.stage}{error.category && ` (${error.category})`}{error.attempt ? `, retried attempt ${error.attempt}` : ''}</p>
                           <p className="text-red-600 whitespace-pre-wrap">{error.error}</p>
                           <p className="text-red-500 text-xs">{new Date(error.timestamp).toLocaleString()}</p>
                         </div>
@@ -123,6 +143,26 @@ export default function PlatformHistory() {
                     </div>
                   )}
 
+                  {/* Playground output and component logs uploaded with the report */}
+                  {result.artifacts && result.artifacts.length > 0 && (
+                    <div className="mt-4">
+                      <p className="font-medium text-gray-700 mb-2">Artifacts:</p>
+                      <ul className="space-y-1">
+                        {result.artifacts.map((artifact) => (
+                          <li key={artifact.name}>
+                            <a
+                              href={`/api/results/${result.id}/artifacts/${encodeURIComponent(artifact.name)}`}
+                              className="text-blue-600 hover:underline"
+                            >
+                              {artifact.name}
+                            </a>
+                            <span className="text-gray-500 text-xs ml-2">{formatSize(artifact.size)}</span>
+                          </li>
+                        ))}
+                      </ul>
+                    </div>
+                  )}
+
                   {/* Component Information Display */}
                   {result.version.components && Object.keys(result.version.components).length > 0 && (
                     <div className="mt-4">
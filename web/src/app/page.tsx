@@ -56,6 +56,10 @@ export default function HomePage() {
     return null;
   };
 
+  // an environment error says nothing about the nightly, it must not fail the banner
+  const available = results.every(r => r.status !== 'failed');
+  const environmentErrors = results.filter(r => r.status === 'environment_error');
+
   if (loading) {
     return (
       <div className="min-h-screen flex items-center justify-center">
@@ -70,39 +74,58 @@ export default function HomePage() {
         <div className="mb-8 text-center">
           <h1 className="text-3xl font-bold text-gray-900">TiUP Nightly Status</h1>
           <div className={`mt-6 p-4 rounded-lg ${
-            results.every(r => r.status === 'success') ? 'bg-green-500' : 'bg-red-500'
+            available ? 'bg-green-500' : 'bg-red-500'
           }`}>
             <div className="flex items-center">
               <svg className="w-6 h-6 text-white mr-3" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                {results.every(r => r.status === 'success') ? (
+                {available ? (
                   <path strokeLinecap="round" strokeLinejoin="round" strokeWidth="2" d="M5 13l4 4L19 7" />
                 ) : (
                   <path strokeLinecap="round" strokeLinejoin="round" strokeWidth="2" d="M12 9v2m0 4h.01m-6.938 4h13.856c1.54 0 2.502-1.667 1.732-3L13.732 4c-.77-1.333-2.694-1.333-3.464 0L3.34 16c-.77 1.333.192 3 1.732 3z" />
                 )}
               </svg>
               <span className="text-white text-lg font-medium">
-                {results.every(r => r.status === 'success') ? 'All Platforms Available' : 'Some Platforms Not Available'}
+                {available ? 'All Platforms Available' : 'Some Platforms Not Available'}
               </span>
             </div>
           </div>
+          {environmentErrors.length > 0 && (
+            <div className="mt-3 p-3 rounded-lg bg-gray-100 text-gray-700 text-sm">
+              Not verified because of checker environment problems: {
+                environmentErrors.map(r => `${r.platform} (${r.channel || 'default channel'})`).join(', ')
+              }
+            </div>
+          )}
         </div>
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           {results.map((result) => (
-            <div key={result.platform} className="bg-white rounded-lg shadow p-6">
+            <div key={`${result.platform}|${result.mirror ?? ''}|${result.channel ?? ''}`} className="bg-white rounded-lg shadow p-6">
               <div className="flex items-center justify-between mb-4">
-                <Link 
-                  href={`/history/${encodeURIComponent(result.platform)}`}
-                  className="text-lg font-semibold text-gray-900 hover:text-blue-600 hover:underline"
-                >
-                  {result.platform}
-                </Link>
+                <div>
+                  <Link 
+                    href={`/history/${encodeURIComponent(result.platform)}?${new URLSearchParams({
+                      mirror: result.mirror ?? '',
+                      channel: result.channel ?? '',
+                    })}`}
+                    className="text-lg font-semibold text-gray-900 hover:text-blue-600 hover:underline"
+                  >
+                    {result.platform}
+                  </Link>
+                  <p className="text-xs text-gray-500">
+                    {result.channel || 'default channel'} @ {result.mirror || 'default mirror'}
+                  </p>
+                </div>
                 <span className={`px-3 py-1 rounded-full text-sm ${
                   result.status === 'success' 
                     ? 'bg-green-100 text-green-800' 
+                    : result.status === 'flaky'
+                    ? 'bg-yellow-100 text-yellow-800'
+                    : result.status === 'environment_error'
+                    ? 'bg-gray-100 text-gray-800'
                     : 'bg-red-100 text-red-800'
                 }`}>
-                  {result.status === 'success' ? 'Normal' : 'Abnormal'}
+                  {result.status === 'success' ? 'Normal' : result.status === 'flaky' ? 'Flaky' : result.status === 'environment_error' ? 'Environment' : 'Abnormal'}
                 </span>
               </div>
               
@@ -114,12 +137,12 @@ export default function HomePage() {
                     : 'Unknown'
                 }</p>
                 {/* Add error details section */}
-                {result.status === 'failed' && result.errors && result.errors.length > 0 && (
+                {result.status !== 'success' && result.errors && result.errors.length > 0 && (
                     <div className="mt-4 p-4 bg-red-50 rounded-lg">
                       <p className="font-medium text-red-800 mb-2">Errors:</p>
                       {result.errors.map((error, index) => (
                         <div key={index} className="mb-2 last:mb-0">
-                          <p className="text-red-700 font-medium">Stage: {error.stage}</p>
+                          <p className="text-red-700 font-medium">Stage: {error.stage}{error.category && ` (${error.category})`}{error.attempt ? `, retried attempt ${error.attempt}` : ''}</p>
                           <p className="text-red-600 whitespace-pre-wrap">{error.error}</p>
                           <p className="text-red-500 text-xs">{new Date(error.timestamp).toLocaleString()}</p>
                         </div>
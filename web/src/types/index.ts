@@ -8,6 +8,9 @@ export interface ComponentInfo {
 export interface ErrorDetail {
   stage: string;
   error: string;
+  category?: string;
+  // set on errors of a retried attempt
+  attempt?: number;
   timestamp: string;
 }
 
@@ -17,15 +20,76 @@ export interface VersionInfo {
   components?: Record<string, ComponentInfo>;
 }
 
+export interface StageResult {
+  name: string;
+  status: 'passed' | 'failed' | 'skipped';
+  start: string;
+  end: string;
+  duration: number; // nanoseconds
+  attempts: number;
+  error?: string;
+}
+
+export interface ComponentInstall {
+  component: string;
+  package: string;
+  success: boolean;
+  duration: number; // nanoseconds
+  bytes: number;
+  error?: string;
+}
+
+export interface HomeUsage {
+  isolated: boolean;
+  seed_bytes: number;
+  downloaded_bytes: number;
+  disk_usage: number;
+  kept_path?: string;
+}
+
+export interface ArtifactInfo {
+  name: string;
+  content_type: string;
+  size: number;
+  created_at: string;
+}
+
+export interface RunInfo {
+  hostname?: string;
+  version?: string;
+  commit?: string;
+  build_time?: string;
+  trigger?: string;
+  ci_job_url?: string;
+}
+
+export interface ReportSignature {
+  key_id: number;
+  value: string;
+  timestamp: string;
+}
+
 export interface CheckResult {
   id: number;
+  run_id?: string;
+  run?: RunInfo;
+  signed_by?: string;
+  signature?: ReportSignature;
   platform: string;
-  status: 'success' | 'failed';
+  // environment_error: the checker host was at fault, not the nightly build
+  // flaky: passed only after retrying a stage
+  status: 'success' | 'failed' | 'environment_error' | 'flaky';
   timestamp: string;
   os: string;
   arch: string;
+  mirror?: string;
+  channel?: string;
   errors?: ErrorDetail[];
+  stages?: StageResult[];
+  installs?: ComponentInstall[];
+  home?: HomeUsage;
   version: VersionInfo;
+  artifacts?: ArtifactInfo[]; // download from /api/v1/results/{id}/artifacts/{name}
 }
 
 export interface BranchCommit {